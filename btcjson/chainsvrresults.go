@@ -978,3 +978,46 @@ type GetTxSpendingPrevOutResult struct {
 	// spending this output (omitted if unspent).
 	SpendingTxid string `json:"spendingtxid,omitempty"`
 }
+
+// ScanTxOutSetResult models the data returned from the scantxoutset command
+// with the start action.
+type ScanTxOutSetResult struct {
+	// Success indicates whether the scan was completed.
+	Success bool `json:"success"`
+
+	// TxOuts is the number of unspent transaction outputs scanned.
+	TxOuts int64 `json:"txouts"`
+
+	// Height is the height of the block at which the scan was done.
+	Height int64 `json:"height"`
+
+	// BestBlock is the hash of the block at the tip of the chain when the
+	// scan was done.
+	BestBlock string `json:"bestblock"`
+
+	// Unspents are the unspent outputs matching the scanned descriptors.
+	Unspents []ScanTxOutSetUnspent `json:"unspents"`
+
+	// TotalAmount is the total amount of all the found unspent outputs in
+	// BTC.
+	TotalAmount float64 `json:"total_amount"`
+}
+
+// ScanTxOutSetUnspent models an unspent output found by the scantxoutset
+// command.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Coinbase     bool    `json:"coinbase"`
+	Height       int64   `json:"height"`
+}
+
+// ScanTxOutSetStatusResult models the data returned from the scantxoutset
+// command with the status action while a scan is in progress.
+type ScanTxOutSetStatusResult struct {
+	// Progress is the approximate percentage of the scan completed.
+	Progress float64 `json:"progress"`
+}
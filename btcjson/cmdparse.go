@@ -37,6 +37,16 @@ func makeParams(rt reflect.Type, rv reflect.Value) []interface{} {
 // must be a registered type.  All commands provided by this package are
 // registered by default.
 func MarshalCmd(rpcVersion RPCVersion, id interface{}, cmd interface{}) ([]byte, error) {
+	return MarshalCmdWith(rpcVersion, id, cmd, json.Marshal)
+}
+
+// MarshalCmdWith is identical to MarshalCmd except the request and each of its
+// parameters are encoded with the provided marshal function instead of the
+// standard encoding/json package.  This allows callers to plug in encoders
+// which, for example, preserve the precision of large numbers.
+func MarshalCmdWith(rpcVersion RPCVersion, id interface{}, cmd interface{},
+	marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+
 	// Look up the cmd type and error out if not registered.
 	rt := reflect.TypeOf(cmd)
 	registerLock.RLock()
@@ -60,11 +70,11 @@ func MarshalCmd(rpcVersion RPCVersion, id interface{}, cmd interface{}) ([]byte,
 	params := makeParams(rt.Elem(), rv.Elem())
 
 	// Generate and marshal the final JSON-RPC request.
-	rawCmd, err := NewRequest(rpcVersion, id, method, params)
+	rawCmd, err := newRequest(rpcVersion, id, method, params, marshal)
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(rawCmd)
+	return marshal(rawCmd)
 }
 
 // checkNumParams ensures the supplied number of params is at least the minimum
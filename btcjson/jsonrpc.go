@@ -47,6 +47,11 @@ type RPCErrorCode int
 type RPCError struct {
 	Code    RPCErrorCode `json:"code,omitempty"`
 	Message string       `json:"message,omitempty"`
+
+	// Data holds the raw JSON of the optional structured data about the
+	// error provided by JSON-RPC 2.0 servers, if any.  It is a pointer so
+	// RPCError values remain comparable.
+	Data *json.RawMessage `json:"data,omitempty"`
 }
 
 // Guarantee RPCError satisfies the builtin error interface.
@@ -156,6 +161,14 @@ func (request *Request) UnmarshalJSON(b []byte) error {
 // MarshalCmd function with that command to generate the marshalled JSON-RPC
 // request.
 func NewRequest(rpcVersion RPCVersion, id interface{}, method string, params []interface{}) (*Request, error) {
+	return newRequest(rpcVersion, id, method, params, json.Marshal)
+}
+
+// newRequest returns a new JSON-RPC request object in the same manner as
+// NewRequest while using the provided function to marshal the parameters.
+func newRequest(rpcVersion RPCVersion, id interface{}, method string,
+	params []interface{}, marshal func(interface{}) ([]byte, error)) (*Request, error) {
+
 	// default to JSON-RPC 1.0 if RPC type is not specified
 	if rpcVersion == "" {
 		rpcVersion = RpcVersion1
@@ -172,7 +185,7 @@ func NewRequest(rpcVersion RPCVersion, id interface{}, method string, params []i
 
 	rawParams := make([]json.RawMessage, 0, len(params))
 	for _, param := range params {
-		marshalledParam, err := json.Marshal(param)
+		marshalledParam, err := marshal(param)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,236 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Activity describes a transaction affecting an address watched with
+// WatchAddress.
+type Activity struct {
+	// Address is the watched address.
+	Address string
+
+	// Tx is the transaction which paid to or spent from the address.
+	Tx *btcutil.Tx
+
+	// Block holds the details of the block the transaction was mined in.
+	// It is nil for unconfirmed transactions.
+	Block *btcjson.BlockDetails
+
+	// Received is true when the transaction pays to the address and false
+	// when it spends an output which previously paid to it.
+	Received bool
+}
+
+// addressWatcher tracks an address watched with WatchAddress along with the
+// outputs paying to it which have been seen, so that transactions spending
+// them can be attributed to the address.
+type addressWatcher struct {
+	addr string
+	fn   func(Activity)
+
+	mtx       sync.Mutex
+	outpoints map[wire.OutPoint]struct{}
+}
+
+// addAddressWatcher registers the passed watcher and returns a function which
+// unregisters it.
+func (c *Client) addAddressWatcher(w *addressWatcher) (remove func()) {
+	c.addrWatchersMtx.Lock()
+	defer c.addrWatchersMtx.Unlock()
+
+	if c.addrWatcherMap == nil {
+		c.addrWatcherMap = make(map[uint64]*addressWatcher)
+	}
+	id := c.nextAddrWatcherID
+	c.nextAddrWatcherID++
+	c.addrWatcherMap[id] = w
+
+	return func() {
+		c.addrWatchersMtx.Lock()
+		delete(c.addrWatcherMap, id)
+		c.addrWatchersMtx.Unlock()
+	}
+}
+
+// addressWatchers returns the currently registered address watchers.
+func (c *Client) addressWatchers() []*addressWatcher {
+	c.addrWatchersMtx.Lock()
+	defer c.addrWatchersMtx.Unlock()
+
+	watchers := make([]*addressWatcher, 0, len(c.addrWatcherMap))
+	for _, w := range c.addrWatcherMap {
+		watchers = append(watchers, w)
+	}
+	return watchers
+}
+
+// notifyAddressWatchers delivers a recvtx or redeemingtx notification to the
+// watchers of the addresses it affects.  Received outputs are also added to
+// the notification state as spent notifications, which the server registers
+// automatically upon receipt, so they are re-established on reconnect.
+func (c *Client) notifyAddressWatchers(watchers []*addressWatcher,
+	tx *btcutil.Tx, block *btcjson.BlockDetails, received bool) {
+
+	for _, w := range watchers {
+		if received {
+			c.watchReceived(w, tx, block)
+		} else {
+			c.watchRedeemed(w, tx, block)
+		}
+	}
+}
+
+// watchReceived delivers the passed transaction to the watcher when any of its
+// outputs pay to the watched address.
+func (c *Client) watchReceived(w *addressWatcher, tx *btcutil.Tx,
+	block *btcjson.BlockDetails) {
+
+	var found bool
+	for i, txOut := range tx.MsgTx().TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, c.chainParams,
+		)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.EncodeAddress() != w.addr {
+				continue
+			}
+
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			w.mtx.Lock()
+			w.outpoints[op] = struct{}{}
+			w.mtx.Unlock()
+
+			c.ntfnStateLock.Lock()
+			c.ntfnState.notifySpent[newOutPointFromWire(&op)] =
+				struct{}{}
+			c.ntfnStateLock.Unlock()
+
+			found = true
+			break
+		}
+	}
+	if found {
+		w.fn(Activity{
+			Address:  w.addr,
+			Tx:       tx,
+			Block:    block,
+			Received: true,
+		})
+	}
+}
+
+// watchRedeemed delivers the passed transaction to the watcher when any of its
+// inputs spend an output previously paying to the watched address.
+func (c *Client) watchRedeemed(w *addressWatcher, tx *btcutil.Tx,
+	block *btcjson.BlockDetails) {
+
+	var found bool
+	w.mtx.Lock()
+	for _, txIn := range tx.MsgTx().TxIn {
+		if _, ok := w.outpoints[txIn.PreviousOutPoint]; ok {
+			found = true
+			break
+		}
+	}
+	w.mtx.Unlock()
+
+	if found {
+		w.fn(Activity{
+			Address: w.addr,
+			Tx:      tx,
+			Block:   block,
+		})
+	}
+}
+
+// WatchAddress delivers both the historical and the live activity of the
+// passed address to fn.  It registers for notifications of transactions paying
+// to the address, then rescans the chain from sinceHeight through the current
+// best block, delivering the transactions found to fn as the rescan progresses,
+// and returns once the rescan has completed.  The watch then remains active,
+// delivering new transactions as they are seen in the mempool and in connected
+// blocks, until ctx is done or the client is shut down.  A transaction may be
+// delivered more than once, for example when it is seen in the mempool and
+// again once mined.
+//
+// Transactions which spend outputs previously paying to the address are also
+// delivered, with Received set to false.  The registrations, including those
+// for the outputs, are re-established when the client reconnects, so the watch
+// survives disconnects, although activity while disconnected is not delivered.
+//
+// As with the notification handlers, fn must not directly call any blocking
+// calls on the client instance.
+//
+// NOTE: This is a btcd extension and requires a websocket connection and
+// non-nil notification handlers.
+func (c *Client) WatchAddress(ctx context.Context, addr string,
+	sinceHeight int32, fn func(Activity)) error {
+
+	if c.config.HTTPPostMode {
+		return ErrWebsocketsRequired
+	}
+	if c.ntfnHandlers == nil {
+		return errors.New("notification handlers are required to watch " +
+			"an address")
+	}
+	address, err := btcutil.DecodeAddress(addr, c.chainParams)
+	if err != nil {
+		return err
+	}
+
+	remove := c.addAddressWatcher(&addressWatcher{
+		addr:      address.EncodeAddress(),
+		fn:        fn,
+		outpoints: make(map[wire.OutPoint]struct{}),
+	})
+	watch := func() error {
+		err := c.NotifyReceived([]btcutil.Address{address})
+		if err != nil {
+			return err
+		}
+
+		startHash, err := c.GetBlockHash(int64(sinceHeight))
+		if err != nil {
+			return err
+		}
+		endHash, err := c.GetBestBlockHash()
+		if err != nil {
+			return err
+		}
+
+		rescan := c.RescanEndBlockAsync(
+			startHash, []btcutil.Address{address}, nil, endHash,
+		)
+		select {
+		case resp := <-rescan:
+			return resp.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := watch(); err != nil {
+		remove()
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.shutdown:
+		}
+		remove()
+	}()
+
+	return nil
+}
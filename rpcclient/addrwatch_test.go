@@ -0,0 +1,167 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWatchAddress ensures WatchAddress registers for notifications, rescans
+// from the requested height, and delivers both the historical and the live
+// activity of the watched address, including spends of its outputs.
+func TestWatchAddress(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+	newAddr := func(b byte) btcutil.Address {
+		addr, err := btcutil.NewAddressPubKeyHash(
+			bytes.Repeat([]byte{b}, 20), params,
+		)
+		require.NoError(t, err)
+		return addr
+	}
+	watched, other := newAddr(1), newAddr(2)
+
+	payTo := func(addr btcutil.Address) *wire.MsgTx {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		return tx
+	}
+	received := payTo(watched)
+	unrelated := payTo(other)
+	spend := payTo(other)
+	spend.TxIn[0].PreviousOutPoint = wire.OutPoint{
+		Hash: received.TxHash(),
+	}
+	txHex := func(tx *wire.MsgTx) string {
+		var buf bytes.Buffer
+		require.NoError(t, tx.Serialize(&buf))
+		return hex.EncodeToString(buf.Bytes())
+	}
+
+	block := &btcjson.BlockDetails{
+		Height: 5,
+		Hash:   chainhash.Hash{5}.String(),
+	}
+	methods := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			notify := func(method string, params ...interface{}) {
+				err := conn.WriteJSON(map[string]interface{}{
+					"jsonrpc": "1.0",
+					"method":  method,
+					"params":  params,
+					"id":      nil,
+				})
+				require.NoError(t, err)
+			}
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				methods <- req.Method
+
+				var result interface{}
+				switch req.Method {
+				case "getblockhash", "getbestblockhash":
+					result = chainhash.Hash{}.String()
+
+				case "rescan":
+					notify("recvtx", txHex(unrelated), block)
+					notify("recvtx", txHex(received), block)
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": result,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+				if req.Method == "rescan" {
+					notify("redeemingtx", txHex(spend))
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		Params:               params.Name,
+	}, &NotificationHandlers{})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	activity := make(chan Activity, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = client.WatchAddress(ctx, watched.String(), 5, func(a Activity) {
+		activity <- a
+	})
+	require.NoError(t, err)
+
+	for _, method := range []string{
+		"notifyreceived", "getblockhash", "getbestblockhash", "rescan",
+	} {
+		require.Equal(t, method, <-methods)
+	}
+
+	// The historical receive is delivered by the rescan.
+	a := <-activity
+	require.Equal(t, watched.String(), a.Address)
+	require.Equal(t, received.TxHash(), *a.Tx.Hash())
+	require.Equal(t, block, a.Block)
+	require.True(t, a.Received)
+
+	// The live spend of the received output follows.
+	select {
+	case a = <-activity:
+	case <-time.After(time.Second):
+		t.Fatal("spend not delivered")
+	}
+	require.Equal(t, spend.TxHash(), *a.Tx.Hash())
+	require.Nil(t, a.Block)
+	require.False(t, a.Received)
+
+	// The output is re-registered on reconnect.
+	client.ntfnStateLock.Lock()
+	_, ok := client.ntfnState.notifySpent[newOutPointFromWire(
+		&wire.OutPoint{Hash: received.TxHash()},
+	)]
+	client.ntfnStateLock.Unlock()
+	require.True(t, ok)
+
+	// The watch is removed once the context is done.
+	cancel()
+	require.Eventually(t, func() bool {
+		return len(client.addressWatchers()) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -1,6 +1,10 @@
 package rpcclient
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // BackendVersion defines an interface to handle the version of the backend
 // used by the client.
@@ -206,3 +210,104 @@ func parseBtcdVersion(version int32) BtcdVersion {
 		return BtcdPost2401
 	}
 }
+
+// ErrWrongBackend is returned when the backend the client is connected to is
+// not of the type required by the RequireBackend connection option.
+var ErrWrongBackend = errors.New("connected to the wrong type of backend")
+
+// ErrWrongNetwork is returned when the server the client is connected to is
+// not running the network of the chain parameters of the client and the
+// VerifyNetwork connection option is set.
+var ErrWrongNetwork = errors.New("connected to the wrong network")
+
+// BackendType identifies the implementation of the backend used by the
+// client.
+type BackendType uint8
+
+const (
+	// BackendAny matches any backend.
+	BackendAny BackendType = iota
+
+	// BackendBtcd identifies a btcd backend.
+	BackendBtcd
+
+	// BackendBitcoind identifies a bitcoind backend.
+	BackendBitcoind
+)
+
+// String returns the BackendType as a human-readable string.
+func (t BackendType) String() string {
+	switch t {
+	case BackendAny:
+		return "any"
+	case BackendBtcd:
+		return "btcd"
+	case BackendBitcoind:
+		return "bitcoind"
+	default:
+		return "unknown"
+	}
+}
+
+// backendTypeOf returns the type of the backend with the passed version.
+func backendTypeOf(version BackendVersion) BackendType {
+	switch version.(type) {
+	case BtcdVersion, *BtcdVersion:
+		return BackendBtcd
+	case BitcoindVersion, *BitcoindVersion:
+		return BackendBitcoind
+	default:
+		return BackendAny
+	}
+}
+
+// checkBackend returns ErrWrongBackend when the backend the client is
+// connected to is not of the type required by the RequireBackend connection
+// option.
+func (c *Client) checkBackend() error {
+	if c.config.RequireBackend == BackendAny {
+		return nil
+	}
+
+	version, err := c.BackendVersion()
+	if err != nil {
+		return err
+	}
+	if backendTypeOf(version) != c.config.RequireBackend {
+		return fmt.Errorf("%w: required %v, connected to %v",
+			ErrWrongBackend, c.config.RequireBackend, version)
+	}
+	return nil
+}
+
+// supportedNotifications maps each type of backend to the notification
+// registration commands it supports.  bitcoind provides notifications over
+// ZMQ rather than websockets, so it supports none of them.
+var supportedNotifications = map[BackendType][]string{
+	BackendBtcd: {
+		"loadtxfilter",
+		"notifyblocks",
+		"notifynewtransactions",
+		"notifyreceived",
+		"notifyspent",
+	},
+	BackendBitcoind: nil,
+}
+
+// SupportedNotifications returns the notification registration commands, such
+// as notifyblocks, which are supported by the backend the client is currently
+// connected to.  This allows applications to avoid registering for
+// notifications which would never be delivered.  None are supported in HTTP
+// POST mode, since notifications require a websocket connection.
+func (c *Client) SupportedNotifications() ([]string, error) {
+	if c.config.HTTPPostMode {
+		return nil, nil
+	}
+
+	version, err := c.BackendVersion()
+	if err != nil {
+		return nil, err
+	}
+	methods := supportedNotifications[backendTypeOf(version)]
+	return append([]string(nil), methods...), nil
+}
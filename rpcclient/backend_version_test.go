@@ -1,6 +1,10 @@
 package rpcclient
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -146,3 +150,62 @@ func TestVersionSupports(t *testing.T) {
 	require.False(BtcdPre2401.SupportGetTxSpendingPrevOut())
 	require.True(BtcdPost2401.SupportGetTxSpendingPrevOut())
 }
+
+// TestRequireBackend ensures New fails when connected to a backend of another
+// type than the one required.
+func TestRequireBackend(t *testing.T) {
+	t.Parallel()
+
+	// Respond to getinfo, so the backend is detected as btcd.
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":{"version":240100},"error":null,`+
+				`"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	newClient := func(backend BackendType) (*Client, error) {
+		return New(&ConnConfig{
+			Host:           strings.TrimPrefix(server.URL, "http://"),
+			User:           "user",
+			Pass:           "pass",
+			DisableTLS:     true,
+			HTTPPostMode:   true,
+			RequireBackend: backend,
+		}, nil)
+	}
+
+	client, err := newClient(BackendBtcd)
+	require.NoError(t, err)
+	client.Shutdown()
+
+	_, err = newClient(BackendBitcoind)
+	require.ErrorIs(t, err, ErrWrongBackend)
+}
+
+// TestSupportedNotifications ensures the notification registrations reported
+// as supported depend on the type of backend and the connection mode.
+func TestSupportedNotifications(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(version BackendVersion, postMode bool) *Client {
+		return &Client{
+			config:         &ConnConfig{HTTPPostMode: postMode},
+			backendVersion: version,
+		}
+	}
+
+	methods, err := newClient(BtcdPost2401, false).SupportedNotifications()
+	require.NoError(t, err)
+	require.Contains(t, methods, "notifyblocks")
+	require.Contains(t, methods, "notifyspent")
+
+	methods, err = newClient(BitcoindPost25, false).SupportedNotifications()
+	require.NoError(t, err)
+	require.Empty(t, methods)
+
+	methods, err = newClient(BtcdPost2401, true).SupportedNotifications()
+	require.NoError(t, err)
+	require.Empty(t, methods)
+}
@@ -0,0 +1,384 @@
+package rpcclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// TypedBulkResult is the result of a single command sent as part of a batch,
+// decoded into the Go type expected for that command.
+type TypedBulkResult struct {
+	// ID is the JSON-RPC id of the command within the batch.
+	ID uint64
+
+	// Method is the JSON-RPC method of the command.
+	Method string
+
+	// Result is a pointer to the decoded result, for example *int64 for
+	// getblockcount or *btcjson.GetBlockVerboseResult for a verbose
+	// getblock.  Results of commands without a known result type are
+	// returned as *json.RawMessage.  It is nil when Err is set.
+	Result interface{}
+
+	// Err is the error returned by the server for the command, or the
+	// error encountered while decoding its result.
+	Err error
+}
+
+// verbosityLevel converts the verbosity or verbose parameter of a command to
+// its integer level, returning def when it is not set.
+func verbosityLevel(v interface{}, def int) int {
+	switch v := v.(type) {
+	case *int:
+		if v != nil {
+			return *v
+		}
+	case int:
+		return v
+	case *bool:
+		if v != nil && *v {
+			return 1
+		} else if v != nil {
+			return 0
+		}
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return def
+}
+
+// batchResultTarget returns a pointer to a new value of the Go type expected
+// as the result of the passed command.  Commands without a known result type
+// are decoded as a raw JSON message.
+func batchResultTarget(cmd interface{}) interface{} {
+	switch cmd := cmd.(type) {
+	case *btcjson.GetBestBlockHashCmd, *btcjson.GetBlockHashCmd,
+		*btcjson.SendRawTransactionCmd:
+
+		return new(string)
+
+	case *btcjson.GetBlockCountCmd, *btcjson.GetConnectionCountCmd:
+		return new(int64)
+
+	case *btcjson.GetDifficultyCmd:
+		return new(float64)
+
+	case *btcjson.GetBlockCmd:
+		switch verbosityLevel(cmd.Verbosity, 1) {
+		case 0:
+			return new(string)
+		case 1:
+			return new(btcjson.GetBlockVerboseResult)
+		default:
+			return new(btcjson.GetBlockVerboseTxResult)
+		}
+
+	case *btcjson.GetBlockHeaderCmd:
+		if verbosityLevel(cmd.Verbose, 1) == 0 {
+			return new(string)
+		}
+		return new(btcjson.GetBlockHeaderVerboseResult)
+
+	case *btcjson.GetRawTransactionCmd:
+		if verbosityLevel(cmd.Verbose, 0) == 0 {
+			return new(string)
+		}
+		return new(btcjson.TxRawResult)
+
+	case *btcjson.GetRawMempoolCmd:
+		if verbosityLevel(cmd.Verbose, 0) == 0 {
+			return new([]string)
+		}
+		return new(map[string]btcjson.GetRawMempoolVerboseResult)
+
+	case *btcjson.GetBlockChainInfoCmd:
+		return new(btcjson.GetBlockChainInfoResult)
+
+	case *btcjson.GetChainTipsCmd:
+		return new([]btcjson.GetChainTipsResult)
+
+	case *btcjson.GetMempoolEntryCmd:
+		return new(btcjson.GetMempoolEntryResult)
+
+	case *btcjson.GetNetworkInfoCmd:
+		return new(btcjson.GetNetworkInfoResult)
+
+	case *btcjson.GetPeerInfoCmd:
+		return new([]btcjson.GetPeerInfoResult)
+
+	case *btcjson.GetInfoCmd:
+		return new(btcjson.InfoChainResult)
+
+	case *btcjson.GetTxOutCmd:
+		return new(btcjson.GetTxOutResult)
+
+	case *btcjson.EstimateSmartFeeCmd:
+		return new(btcjson.EstimateSmartFeeResult)
+
+	default:
+		return new(json.RawMessage)
+	}
+}
+
+// SendTyped sends the queued batch of commands in the same manner as Send and
+// returns the result of each command, in the order the commands were queued,
+// decoded into the Go type expected for the command.  This removes the need to
+// manually decode the results of homogeneous batches.
+//
+// NOTE: The results are consumed from the futures returned when the commands
+// were queued, so those futures must not be used when calling SendTyped.
+func (c *Client) SendTyped() ([]TypedBulkResult, error) {
+	// Take a copy of the queued requests so their responses can be
+	// collected once the batch has been sent.
+	c.batchLock.Lock()
+	requests := make([]*jsonRequest, 0, c.batchList.Len())
+	for e := c.batchList.Front(); e != nil; e = e.Next() {
+		requests = append(requests, e.Value.(*jsonRequest))
+	}
+	c.batchLock.Unlock()
+
+	if err := c.Send(); err != nil {
+		return nil, err
+	}
+
+	results := make([]TypedBulkResult, 0, len(requests))
+	for _, request := range requests {
+		result := TypedBulkResult{
+			ID:     request.id,
+			Method: request.method,
+		}
+
+		var resp *Response
+		select {
+		case resp = <-request.responseChan:
+		default:
+			result.Err = c.connErr(ErrNoResponse)
+			results = append(results, result)
+			continue
+		}
+		if resp.err != nil {
+			result.Err = resp.err
+			results = append(results, result)
+			continue
+		}
+
+		target := batchResultTarget(request.cmd)
+		if err := c.unmarshalJSON(resp.result, target); err != nil {
+			result.Err = err
+		} else {
+			result.Result = target
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// SendBatch marshals the passed commands into a single JSON-RPC 2.0 batch
+// request, sends it to the server, and returns the result of each command in
+// the same order as the passed commands.  Unlike the batch client created with
+// NewBatch, SendBatch does not rely on any state shared between calls, so it
+// is safe for concurrent use and may be used with any client running in HTTP
+// POST mode.
+//
+// Errors returned by the server for individual commands are reported in the
+// Error field of the corresponding result rather than as the returned error.
+func (c *Client) SendBatch(cmds []interface{}) ([]IndividualBulkResult, error) {
+	if !c.config.HTTPPostMode {
+		return nil, errors.New("http post mode is required to send " +
+			"batch requests")
+	}
+	if len(cmds) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	// Marshal each of the commands with its own id and join them into a
+	// single JSON array.
+	ids := make([]uint64, 0, len(cmds))
+	methods := make(map[uint64]string, len(cmds))
+	marshalledCmds := make([][]byte, 0, len(cmds))
+	for _, cmd := range cmds {
+		method, err := btcjson.CmdMethod(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkReadOnly(method); err != nil {
+			return nil, err
+		}
+
+		id := c.NextID()
+		marshalledJSON, err := c.marshalCmdRequest(
+			btcjson.RpcVersion2, id, method, cmd,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		methods[id] = method
+		marshalledCmds = append(marshalledCmds, marshalledJSON)
+	}
+	marshalledRequest := append([]byte("["),
+		bytes.Join(marshalledCmds, []byte(","))...)
+	marshalledRequest = append(marshalledRequest, ']')
+
+	start := time.Now()
+	responseChan := make(chan *Response, 1)
+	c.sendPostRequest(&jsonRequest{
+		id:             c.NextID(),
+		marshalledJSON: marshalledRequest,
+		responseChan:   responseChan,
+		batch:          true,
+	})
+	res, err := ReceiveFuture(responseChan)
+	var batchResp BulkResult
+	if err == nil {
+		batchResp, err = decodeBulkResult(res)
+	}
+	c.notifyBatchSent(len(cmds), len(marshalledRequest), start, err)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validateBatchResults(res, methods); err != nil {
+		return nil, err
+	}
+
+	// Return the results in the order of the passed commands.
+	results := make([]IndividualBulkResult, 0, len(ids))
+	for _, id := range ids {
+		result, ok := batchResp[id]
+		if !ok {
+			return nil, c.connErr(fmt.Errorf("%w: id %d",
+				ErrNoResponse, id))
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// errNotBatchClient is returned when saving or loading the queued batch of a
+// client which was not created with NewBatch.
+var errNotBatchClient = errors.New("a batch client created with NewBatch " +
+	"is required")
+
+// savedBatchRequest is the serialized form of a queued batch request written
+// by SaveBatch.
+type savedBatchRequest struct {
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method"`
+	Request json.RawMessage `json:"request"`
+}
+
+// SaveBatch writes the commands queued on the batch client which have not been
+// sent yet to w, so they can be loaded into a new client with LoadBatch, for
+// example to resume a long-running bulk job after a restart.  The queued
+// commands are left in place.
+func (c *Client) SaveBatch(w io.Writer) error {
+	if !c.batch {
+		return errNotBatchClient
+	}
+
+	c.batchLock.Lock()
+	saved := make([]savedBatchRequest, 0, c.batchList.Len())
+	for e := c.batchList.Front(); e != nil; e = e.Next() {
+		request := e.Value.(*jsonRequest)
+		saved = append(saved, savedBatchRequest{
+			ID:      request.id,
+			Method:  request.method,
+			Request: request.marshalledJSON,
+		})
+	}
+	c.batchLock.Unlock()
+
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// LoadBatch queues the commands written by SaveBatch from r on the batch
+// client, which should be a new client on which no commands have been queued
+// yet, so the next call to Send or SendTyped issues them.  Since the futures
+// of the original commands are lost, their results should be retrieved with
+// SendTyped, which matches them by id and method.  The id counter of the
+// client is advanced past the loaded ids so they are not reused.
+func (c *Client) LoadBatch(r io.Reader) error {
+	if !c.batch {
+		return errNotBatchClient
+	}
+
+	var saved []savedBatchRequest
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+
+	for _, s := range saved {
+		// Recreate the command when the method is registered so the
+		// results are decoded into their expected type by SendTyped.
+		var cmd interface{}
+		var req btcjson.Request
+		if err := json.Unmarshal(s.Request, &req); err == nil {
+			cmd, _ = btcjson.UnmarshalCmd(&req)
+		}
+
+		for {
+			id := atomic.LoadUint64(&c.id)
+			if id >= s.ID || atomic.CompareAndSwapUint64(&c.id, id,
+				s.ID) {
+
+				break
+			}
+		}
+
+		err := c.addRequest(&jsonRequest{
+			id:             s.ID,
+			method:         s.Method,
+			cmd:            cmd,
+			marshalledJSON: s.Request,
+			responseChan:   make(chan *Response, 1),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateBatchResults checks the raw result of each successful command in the
+// passed batch response with the ValidateResponse hook, if any.  methods maps
+// the id of each command to its method.
+func (c *Client) validateBatchResults(res []byte,
+	methods map[uint64]string) error {
+
+	if c.config.ValidateResponse == nil {
+		return nil
+	}
+
+	var results []struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *btcjson.RPCError `json:"error"`
+		ID     responseID        `json:"id"`
+	}
+	if err := c.unmarshalJSON(res, &results); err != nil {
+		return err
+	}
+	for _, result := range results {
+		method, ok := methods[uint64(result.ID)]
+		if !ok || result.Error != nil {
+			continue
+		}
+		err := c.validateResponse(method, result.Result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
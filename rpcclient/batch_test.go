@@ -0,0 +1,414 @@
+package rpcclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchServer returns a test HTTP server which replies to each request of a
+// JSON-RPC batch with the result returned by the passed function for its
+// method.
+func newBatchServer(t *testing.T,
+	result func(method string) interface{}) *httptest.Server {
+
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			var requests []btcjson.Request
+			require.NoError(t, json.Unmarshal(body, &requests))
+
+			responses := make([]IndividualBulkResult, 0, len(requests))
+			for _, req := range requests {
+				responses = append(responses, IndividualBulkResult{
+					Result: result(req.Method),
+					Id:     uint64(req.ID.(float64)),
+				})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(responses))
+		},
+	))
+}
+
+// newTestBatchClient returns a batch client connected to the passed test
+// server.
+func newTestBatchClient(t *testing.T, server *httptest.Server) *Client {
+	client, err := NewBatch(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Shutdown)
+
+	return client
+}
+
+// TestSendTyped ensures the results of a batch are decoded into the types
+// expected for each command and returned in the order they were queued.
+func TestSendTyped(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, func(method string) interface{} {
+		switch method {
+		case "getblockcount":
+			return 100
+		case "getblockhash":
+			return "00000000000000000000"
+		case "getblock":
+			return map[string]interface{}{"hash": "abc", "height": 7}
+		default:
+			return []int{1, 2, 3}
+		}
+	})
+	defer server.Close()
+
+	client := newTestBatchClient(t, server)
+	client.GetBlockCountAsync()
+	client.GetBlockHashAsync(1)
+	client.SendCmd(&btcjson.GetBlockCmd{Hash: "abc", Verbosity: btcjson.Int(1)})
+	client.SendCmd(btcjson.NewGetDifficultyCmd())
+
+	results, err := client.SendTyped()
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.Equal(t, "getblockcount", results[0].Method)
+	require.Equal(t, int64(100), *results[0].Result.(*int64))
+
+	require.Equal(t, "00000000000000000000",
+		*results[1].Result.(*string))
+
+	block := results[2].Result.(*btcjson.GetBlockVerboseResult)
+	require.Equal(t, int64(7), block.Height)
+
+	// The difficulty result is not a float, so decoding must fail.
+	require.Error(t, results[3].Err)
+	require.Nil(t, results[3].Result)
+}
+
+// TestSendBatch ensures a one-shot batch returns the results in the order of
+// the passed commands without modifying the client's batch state.
+func TestSendBatch(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, func(method string) interface{} {
+		return method
+	})
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	results, err := client.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+		btcjson.NewGetBestBlockHashCmd(),
+		btcjson.NewGetDifficultyCmd(),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, "getblockcount", results[0].Result)
+	require.Equal(t, "getbestblockhash", results[1].Result)
+	require.Equal(t, "getdifficulty", results[2].Result)
+	require.False(t, client.batch)
+
+	_, err = client.SendBatch(nil)
+	require.ErrorIs(t, err, ErrEmptyBatch)
+}
+
+// TestSendBatchValidation ensures the commands of a one-shot batch and their
+// results are checked with the validation hooks.
+func TestSendBatchValidation(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := newBatchServer(t, func(method string) interface{} {
+		atomic.AddInt32(&requests, 1)
+		return method
+	})
+	defer server.Close()
+
+	errInvalid := errors.New("invalid")
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		ValidateRequest: func(method string, _ []byte) error {
+			if method == "getdifficulty" {
+				return errInvalid
+			}
+			return nil
+		},
+		ValidateResponse: func(method string, result []byte) error {
+			require.Equal(t, `"`+method+`"`, string(result))
+			if method == "getbestblockhash" {
+				return errInvalid
+			}
+			return nil
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// A rejected command prevents the batch from being sent.
+	_, err = client.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+		btcjson.NewGetDifficultyCmd(),
+	})
+	require.ErrorIs(t, err, errInvalid)
+	require.Zero(t, atomic.LoadInt32(&requests))
+
+	// A rejected result is reported to the caller.
+	_, err = client.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+		btcjson.NewGetBestBlockHashCmd(),
+	})
+	require.ErrorIs(t, err, errInvalid)
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	results, err := client.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "getblockcount", results[0].Result)
+}
+
+// TestOnBatchSent ensures the OnBatchSent callback is invoked with the size
+// of each batch sent.
+func TestOnBatchSent(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, func(method string) interface{} {
+		return method
+	})
+	defer server.Close()
+
+	type batchSent struct {
+		count int
+		bytes int
+		err   error
+	}
+	sent := make(chan batchSent, 2)
+	client, err := NewBatch(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		OnBatchSent: func(count, bytes int, _ time.Duration, err error) {
+			sent <- batchSent{count, bytes, err}
+		},
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	client.GetBlockCountAsync()
+	client.GetBestBlockHashAsync()
+	require.NoError(t, client.Send())
+	batch := <-sent
+	require.Equal(t, 2, batch.count)
+	require.Positive(t, batch.bytes)
+	require.NoError(t, batch.err)
+
+	_, err = client.SendBatch([]interface{}{btcjson.NewGetBlockCountCmd()})
+	require.NoError(t, err)
+	batch = <-sent
+	require.Equal(t, 1, batch.count)
+	require.Positive(t, batch.bytes)
+	require.NoError(t, batch.err)
+}
+
+// TestSendPartialResponse ensures requests which are missing from a truncated
+// batch response receive ErrNoResponse instead of blocking forever.
+func TestSendPartialResponse(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var requests []btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&requests)
+			require.NoError(t, err)
+
+			// Only reply to the first request.
+			responses := []IndividualBulkResult{{
+				Result: 100,
+				Id:     uint64(requests[0].ID.(float64)),
+			}}
+			require.NoError(t, json.NewEncoder(w).Encode(responses))
+		},
+	))
+	defer server.Close()
+
+	client := newTestBatchClient(t, server)
+	client.config.ConnName = "batch"
+	countFuture := client.GetBlockCountAsync()
+	hashFuture := client.GetBestBlockHashAsync()
+	require.NoError(t, client.Send())
+
+	count, err := countFuture.Receive()
+	require.NoError(t, err)
+	require.Equal(t, int64(100), count)
+
+	_, err = hashFuture.Receive()
+	require.ErrorIs(t, err, ErrNoResponse)
+	require.True(t, strings.HasPrefix(err.Error(), "batch: "))
+}
+
+// TestSendCompressedBatch ensures a gzip-encoded batch response is decompressed
+// before the results of the batch are decoded.
+func TestSendCompressedBatch(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+
+			var requests []btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&requests)
+			require.NoError(t, err)
+
+			responses := make([]IndividualBulkResult, 0, len(requests))
+			for _, req := range requests {
+				responses = append(responses, IndividualBulkResult{
+					Result: req.Method,
+					Id:     uint64(req.ID.(float64)),
+				})
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			require.NoError(t, json.NewEncoder(gz).Encode(responses))
+			require.NoError(t, gz.Close())
+		},
+	))
+	defer server.Close()
+
+	client, err := NewBatch(&ConnConfig{
+		Host:               strings.TrimPrefix(server.URL, "http://"),
+		User:               "user",
+		Pass:               "pass",
+		DisableTLS:         true,
+		HTTPPostMode:       true,
+		RequestCompression: true,
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	hashFuture := client.GetBestBlockHashAsync()
+	countFuture := client.GetBlockCountAsync()
+	require.NoError(t, client.Send())
+
+	res, err := ReceiveFuture(hashFuture)
+	require.NoError(t, err)
+	require.Equal(t, `"getbestblockhash"`, string(res))
+	res, err = ReceiveFuture(countFuture)
+	require.NoError(t, err)
+	require.Equal(t, `"getblockcount"`, string(res))
+}
+
+// TestSaveLoadBatch ensures the queued commands of a batch client can be saved
+// and loaded into a new client which then issues them.
+func TestSaveLoadBatch(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, func(method string) interface{} {
+		switch method {
+		case "getblockcount":
+			return 100
+		default:
+			return "00000000000000000000"
+		}
+	})
+	defer server.Close()
+
+	client := newTestBatchClient(t, server)
+	client.GetBlockCountAsync()
+	client.GetBlockHashAsync(1)
+
+	var saved bytes.Buffer
+	require.NoError(t, client.SaveBatch(&saved))
+
+	resumed := newTestBatchClient(t, server)
+	require.NoError(t, resumed.LoadBatch(&saved))
+	require.Greater(t, resumed.NextID(), client.PeekID())
+
+	results, err := resumed.SendTyped()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	require.Equal(t, "getblockcount", results[0].Method)
+	require.Equal(t, int64(100), *results[0].Result.(*int64))
+	require.Equal(t, "getblockhash", results[1].Method)
+	require.Equal(t, "00000000000000000000",
+		*results[1].Result.(*string))
+
+	// Clients which are not batch clients are rejected.
+	nonBatch := &Client{config: &ConnConfig{}}
+	require.ErrorIs(t, nonBatch.SaveBatch(&saved), errNotBatchClient)
+	require.ErrorIs(t, nonBatch.LoadBatch(&saved), errNotBatchClient)
+}
+
+// TestSendContext ensures a batch sent with SendContext is abandoned once the
+// context is done and the context error is delivered to the batched commands.
+func TestSendContext(t *testing.T) {
+	t.Parallel()
+
+	// Never reply, so the batch is only completed by the context.  The
+	// body must be read for the server to notice the client going away.
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = ioutil.ReadAll(r.Body)
+			<-r.Context().Done()
+		},
+	))
+	defer server.Close()
+
+	client := newTestBatchClient(t, server)
+	countFuture := client.GetBlockCountAsync()
+	hashFuture := client.GetBestBlockHashAsync()
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+	err := client.SendContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = countFuture.Receive()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = hashFuture.Receive()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	client.batchLock.Lock()
+	require.Zero(t, client.batchList.Len())
+	client.batchLock.Unlock()
+
+	// A done context is reported without sending anything.
+	client.GetBlockCountAsync()
+	require.ErrorIs(t, client.SendContext(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,167 @@
+package rpcclient
+
+import (
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// BlockResult describes a block as returned by the verbose forms of getblock,
+// normalized so that it does not depend on the backend which returned it.
+type BlockResult struct {
+	Hash          string
+	Confirmations int64
+	Size          int32
+	StrippedSize  int32
+	Weight        int32
+	Height        int64
+	Version       int32
+	VersionHex    string
+	MerkleRoot    string
+	Time          int64
+	Nonce         uint32
+	Bits          string
+	Difficulty    float64
+	PreviousHash  string
+	NextHash      string
+
+	// TxIDs holds the ids of the transactions in the block.  It is always
+	// set.
+	TxIDs []string
+
+	// Txs holds the details of the transactions in the block.  It is only
+	// set when the transactions were requested.
+	Txs []btcjson.TxRawResult
+}
+
+// rawBlockResult models the fields of the verbose getblock result of both btcd
+// and bitcoind.  The transactions are held in tx as ids, or as objects with
+// bitcoind, and in rawtx as objects with btcd.
+type rawBlockResult struct {
+	Hash          string                `json:"hash"`
+	Confirmations int64                 `json:"confirmations"`
+	Size          int32                 `json:"size"`
+	StrippedSize  int32                 `json:"strippedsize"`
+	Weight        int32                 `json:"weight"`
+	Height        int64                 `json:"height"`
+	Version       int32                 `json:"version"`
+	VersionHex    string                `json:"versionHex"`
+	MerkleRoot    string                `json:"merkleroot"`
+	Time          int64                 `json:"time"`
+	Nonce         uint32                `json:"nonce"`
+	Bits          string                `json:"bits"`
+	Difficulty    float64               `json:"difficulty"`
+	PreviousHash  string                `json:"previousblockhash"`
+	NextHash      string                `json:"nextblockhash"`
+	Tx            json.RawMessage       `json:"tx"`
+	RawTx         []btcjson.TxRawResult `json:"rawtx"`
+}
+
+// decodeBlockResult decodes the passed verbose getblock result returned by the
+// passed type of backend.  withTxs specifies whether the result includes the
+// details of the transactions, as requested with verbosity 2.
+func decodeBlockResult(res []byte, backend BackendType,
+	withTxs bool) (*BlockResult, error) {
+
+	var raw rawBlockResult
+	if err := json.Unmarshal(res, &raw); err != nil {
+		return nil, err
+	}
+
+	block := &BlockResult{
+		Hash:          raw.Hash,
+		Confirmations: raw.Confirmations,
+		Size:          raw.Size,
+		StrippedSize:  raw.StrippedSize,
+		Weight:        raw.Weight,
+		Height:        raw.Height,
+		Version:       raw.Version,
+		VersionHex:    raw.VersionHex,
+		MerkleRoot:    raw.MerkleRoot,
+		Time:          raw.Time,
+		Nonce:         raw.Nonce,
+		Bits:          raw.Bits,
+		Difficulty:    raw.Difficulty,
+		PreviousHash:  raw.PreviousHash,
+		NextHash:      raw.NextHash,
+	}
+
+	switch {
+	// Without the transaction details, both backends return the ids in
+	// tx.
+	case !withTxs:
+		if len(raw.Tx) > 0 {
+			err := json.Unmarshal(raw.Tx, &block.TxIDs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return block, nil
+
+	// btcd returns the transaction details in rawtx.
+	case backend == BackendBtcd:
+		block.Txs = raw.RawTx
+
+	// bitcoind returns the transaction details in tx in place of the ids.
+	default:
+		if len(raw.Tx) > 0 {
+			err := json.Unmarshal(raw.Tx, &block.Txs)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	block.TxIDs = make([]string, 0, len(block.Txs))
+	for _, tx := range block.Txs {
+		block.TxIDs = append(block.TxIDs, tx.Txid)
+	}
+	return block, nil
+}
+
+// DecodeBlockResult decodes the passed verbose getblock result, such as one
+// returned by RawRequest, into a BlockResult according to the backend the
+// client is connected to, since btcd and bitcoind return the details of the
+// transactions in different fields.  withTxs specifies whether the result
+// includes the details of the transactions, as requested with verbosity 2.
+func (c *Client) DecodeBlockResult(res json.RawMessage,
+	withTxs bool) (*BlockResult, error) {
+
+	version, err := c.BackendVersion()
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlockResult(res, backendTypeOf(version), withTxs)
+}
+
+// GetBlockResult returns information about a block given its hash, along with
+// the details of its transactions when withTxs is set, in the same form
+// regardless of the backend the client is connected to.
+//
+// See GetBlockVerbose and GetBlockVerboseTx for the results as returned by the
+// server.
+func (c *Client) GetBlockResult(blockHash *chainhash.Hash,
+	withTxs bool) (*BlockResult, error) {
+
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+	verbosity := 1
+	if withTxs {
+		verbosity = 2
+	}
+
+	cmd := &btcjson.GetBlockCmd{
+		Hash:      hash,
+		Verbosity: verbosity,
+	}
+	res, err := c.waitForGetBlockRes(
+		c.SendCmd(cmd), hash, verbosity, withTxs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c.DecodeBlockResult(res, withTxs)
+}
@@ -0,0 +1,120 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeBlockResult ensures the verbose getblock results of btcd and
+// bitcoind are decoded into the same BlockResult.
+func TestDecodeBlockResult(t *testing.T) {
+	t.Parallel()
+
+	const (
+		header = `"hash":"00aa","height":100,"weight":4000,` +
+			`"previousblockhash":"00bb"`
+		txIDs = `["01","02"]`
+		txs   = `[{"txid":"01","locktime":1},{"txid":"02","locktime":2}]`
+	)
+	tests := []struct {
+		name    string
+		backend BackendType
+		withTxs bool
+		result  string
+	}{{
+		name:    "btcd ids",
+		backend: BackendBtcd,
+		result:  `{` + header + `,"tx":` + txIDs + `}`,
+	}, {
+		name:    "btcd txs",
+		backend: BackendBtcd,
+		withTxs: true,
+		result:  `{` + header + `,"rawtx":` + txs + `}`,
+	}, {
+		name:    "bitcoind ids",
+		backend: BackendBitcoind,
+		result:  `{` + header + `,"tx":` + txIDs + `}`,
+	}, {
+		name:    "bitcoind txs",
+		backend: BackendBitcoind,
+		withTxs: true,
+		result:  `{` + header + `,"tx":` + txs + `}`,
+	}}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			block, err := decodeBlockResult(
+				[]byte(test.result), test.backend, test.withTxs,
+			)
+			require.NoError(t, err)
+			require.Equal(t, "00aa", block.Hash)
+			require.EqualValues(t, 100, block.Height)
+			require.EqualValues(t, 4000, block.Weight)
+			require.Equal(t, "00bb", block.PreviousHash)
+			require.Equal(t, []string{"01", "02"}, block.TxIDs)
+
+			if !test.withTxs {
+				require.Nil(t, block.Txs)
+				return
+			}
+			require.Len(t, block.Txs, 2)
+			require.EqualValues(t, 2, block.Txs[1].LockTime)
+		})
+	}
+}
+
+// TestGetBlockResult ensures GetBlockResult requests the verbosity matching
+// whether the transaction details are requested.
+func TestGetBlockResult(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var verbosity int
+			err = json.Unmarshal(req.Params[1], &verbosity)
+			require.NoError(t, err)
+
+			tx := `"01"`
+			if verbosity == 2 {
+				tx = `{"txid":"01"}`
+			}
+			fmt.Fprintf(w, `{"result":{"height":7,"tx":[%s]},`+
+				`"error":null,"id":%v}`, tx, req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+	client.backendVersion = BitcoindPost25
+
+	for _, withTxs := range []bool{false, true} {
+		block, err := client.GetBlockResult(&chainhash.Hash{}, withTxs)
+		require.NoError(t, err)
+		require.EqualValues(t, 7, block.Height)
+		require.Equal(t, []string{"01"}, block.TxIDs)
+		require.Equal(t, withTxs, block.Txs != nil)
+	}
+}
@@ -0,0 +1,141 @@
+package rpcclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// callOptions holds the per-call settings applied by CallOption functions.
+type callOptions struct {
+	ctx     context.Context
+	timeout time.Duration
+	tries   int
+	headers http.Header
+}
+
+// CallOption is a functional option which overrides the default policy of the
+// client for a single call made with SendCmdWithOptions.
+type CallOption func(*callOptions)
+
+// WithContext abandons the call once the passed context is done, delivering
+// the context's error as the response.  In HTTP POST mode this also aborts
+// the in-flight HTTP request and any remaining retries, while in websocket
+// mode the request is no longer tracked, so a reply which arrives later is
+// ignored.
+func WithContext(ctx context.Context) CallOption {
+	return func(o *callOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithTimeout abandons the call, in the same manner as WithContext, once the
+// passed duration has elapsed since it was issued.
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetries sets the maximum number of times the call is retried after its
+// first attempt.  In HTTP POST mode, it limits the retries after network
+// errors, overriding RetryableMethods.  In websocket mode, it limits the
+// number of times the request is resent after reconnects, and the call fails
+// with ErrClientDisconnect once the limit is reached.
+func WithRetries(retries int) CallOption {
+	return func(o *callOptions) {
+		o.tries = retries + 1
+		if o.tries < 1 {
+			o.tries = 1
+		}
+	}
+}
+
+// WithoutResend disables retrying the call, which is the same as
+// WithRetries(0).  It is useful for requests with side effects, which must not
+// be repeated.
+func WithoutResend() CallOption {
+	return WithRetries(0)
+}
+
+// WithHeaders sets additional HTTP headers sent with the call, which take
+// precedence over those set by the client.  Websocket requests do not carry
+// headers of their own, so it has no effect in websocket mode.
+func WithHeaders(headers http.Header) CallOption {
+	return func(o *callOptions) {
+		o.headers = headers
+	}
+}
+
+// SendCmdWithOptions sends the passed command to the associated server in the
+// same manner as SendCmd, with the default policy of the client overridden by
+// the passed options for this call only.
+//
+// NOTE: SendCmdWithOptions should not be used with a batch client, since the
+// commands of a batch are sent together.
+func (c *Client) SendCmdWithOptions(cmd interface{},
+	opts ...CallOption) chan *Response {
+
+	var options callOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	jReq, err := c.newCmdRequest(cmd)
+	if err != nil {
+		return newFutureError(err)
+	}
+	jReq.tries = options.tries
+	jReq.headers = options.headers
+
+	ctx := options.ctx
+	if ctx == nil && options.timeout > 0 {
+		ctx = context.Background()
+	}
+	cancel := func() {}
+	if ctx != nil && options.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+	}
+	if ctx != nil {
+		jReq.ctx = ctx
+		jReq.requestID, _ = RequestIDFromContext(ctx)
+	}
+
+	var sent time.Time
+	if c.metrics != nil {
+		sent = c.metrics.begin()
+	}
+	c.sendRequest(jReq)
+
+	responseChan := make(chan *Response, 1)
+	go func() {
+		defer cancel()
+
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+
+		var resp *Response
+		select {
+		case resp = <-jReq.responseChan:
+
+		case <-done:
+			// Stop tracking the request so a late reply is ignored,
+			// unless the reply has already been delivered.
+			removed := c.config.HTTPPostMode ||
+				c.removeRequest(jReq.id) != nil
+			if removed {
+				resp = &Response{err: c.connErr(ctx.Err())}
+			} else {
+				resp = <-jReq.responseChan
+			}
+		}
+		if c.metrics != nil {
+			c.metrics.end(sent, resp.err)
+		}
+		responseChan <- resp
+	}()
+
+	return responseChan
+}
@@ -0,0 +1,121 @@
+package rpcclient
+
+import (
+	"container/list"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSendCmdWithOptionsPost ensures the call options are honored in HTTP POST
+// mode.
+func TestSendCmdWithOptionsPost(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = ioutil.ReadAll(r.Body)
+			if r.Header.Get("X-Test") == "" {
+				<-r.Context().Done()
+				return
+			}
+			_, _ = w.Write([]byte(`{"result":1,"error":null,"id":1}`))
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	cmd := btcjson.NewGetBlockCountCmd()
+	count, err := FutureGetBlockCountResult(client.SendCmdWithOptions(
+		cmd, WithHeaders(http.Header{"X-Test": {"1"}}),
+	)).Receive()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	// The server never replies without the header.
+	_, err = ReceiveFuture(client.SendCmdWithOptions(
+		cmd, WithTimeout(50*time.Millisecond),
+	))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Nothing listens on the host of this client, so every attempt fails.
+	clock := &testClock{}
+	client, err = New(&ConnConfig{
+		Host:         "127.0.0.1:1",
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		Clock:        clock,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	_, err = ReceiveFuture(client.SendCmdWithOptions(cmd, WithRetries(2)))
+	require.Error(t, err)
+	_, err = ReceiveFuture(client.SendCmdWithOptions(cmd, WithoutResend()))
+	require.Error(t, err)
+
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	require.Len(t, clock.waits, 2)
+}
+
+// TestSendCmdWithOptionsWebsocket ensures the call options are honored in
+// websocket mode.
+func TestSendCmdWithOptionsWebsocket(t *testing.T) {
+	t.Parallel()
+
+	connEstablished := make(chan struct{})
+	close(connEstablished)
+	client := &Client{
+		config:          &ConnConfig{},
+		requestMap:      make(map[uint64]*list.Element),
+		requestList:     list.New(),
+		ntfnState:       newNotificationState(),
+		sendChan:        make(chan []byte, 10),
+		connEstablished: connEstablished,
+		disconnect:      make(chan struct{}),
+		shutdown:        make(chan struct{}),
+	}
+	cmd := btcjson.NewGetBlockCountCmd()
+
+	// A cancelled call is no longer tracked.
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := client.SendCmdWithOptions(cmd, WithContext(ctx))
+	cancel()
+	_, err := ReceiveFuture(resp)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, client.requestList.Len())
+
+	// Requests are resent after reconnects only as often as allowed.
+	noResend := client.SendCmdWithOptions(cmd, WithoutResend())
+	oneRetry := client.SendCmdWithOptions(cmd, WithRetries(1))
+	require.Len(t, client.sendChan, 3)
+
+	client.resendRequests()
+	_, err = ReceiveFuture(noResend)
+	require.ErrorIs(t, err, ErrClientDisconnect)
+	require.Len(t, client.sendChan, 4)
+
+	client.resendRequests()
+	_, err = ReceiveFuture(oneRetry)
+	require.ErrorIs(t, err, ErrClientDisconnect)
+	require.Len(t, client.sendChan, 4)
+}
@@ -7,6 +7,7 @@ package rpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"github.com/btcsuite/btcd/btcjson"
@@ -51,6 +52,30 @@ func (c *Client) GetBestBlockHash() (*chainhash.Hash, error) {
 	return c.GetBestBlockHashAsync().Receive()
 }
 
+// BestBlock returns the hash and height of the best block in the longest block
+// chain using a single request, so both describe the same block.  The RPC is
+// chosen based on the backend version: getbestblock for btcd, and
+// getblockchaininfo otherwise.
+func (c *Client) BestBlock() (*chainhash.Hash, int32, error) {
+	version, err := c.BackendVersion()
+	if err != nil {
+		return nil, 0, err
+	}
+	if backendTypeOf(version) == BackendBtcd {
+		return c.GetBestBlock()
+	}
+
+	info, err := c.GetBlockChainInfo()
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err := chainhash.NewHashFromStr(info.BestBlockHash)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, info.Blocks, nil
+}
+
 // legacyGetBlockRequest constructs and sends a legacy getblock request which
 // contains two separate bools to denote verbosity, in contract to a single int
 // parameter.
@@ -857,6 +882,105 @@ func (c *Client) GetRawMempoolVerbose() (map[string]btcjson.GetRawMempoolVerbose
 	return c.GetRawMempoolVerboseAsync().Receive()
 }
 
+// walkMempoolBatchSize is the number of getmempoolentry requests WalkMempool
+// keeps in flight at once.
+const walkMempoolBatchSize = 100
+
+// WalkMempool invokes fn with the hash and details of each transaction in the
+// memory pool, stopping early and returning the error when fn returns one or
+// the context is done.
+//
+// When connected to bitcoind, the hashes are retrieved first and the details
+// of each transaction are then requested in batches, so the full verbose
+// mempool is never held in memory at once.  Transactions which leave the
+// mempool in the meantime are skipped.  Since btcd does not implement
+// getmempoolentry, the verbose mempool is retrieved with a single call instead,
+// and only the fields it provides are set in the entries passed to fn.
+func (c *Client) WalkMempool(ctx context.Context,
+	fn func(txid string, entry *btcjson.GetMempoolEntryResult) error) error {
+
+	version, err := c.BackendVersion()
+	if err != nil {
+		return err
+	}
+	if _, ok := version.(BtcdVersion); ok {
+		return c.walkVerboseMempool(ctx, fn)
+	}
+
+	txHashes, err := c.GetRawMempool()
+	if err != nil {
+		return err
+	}
+	for len(txHashes) > 0 {
+		batch := txHashes
+		if len(batch) > walkMempoolBatchSize {
+			batch = batch[:walkMempoolBatchSize]
+		}
+		txHashes = txHashes[len(batch):]
+
+		futures := make([]FutureGetMempoolEntryResult, 0, len(batch))
+		for _, txHash := range batch {
+			futures = append(
+				futures, c.GetMempoolEntryAsync(txHash.String()),
+			)
+		}
+
+		for i, future := range futures {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			entry, err := future.Receive()
+			if rpcErr, ok := err.(*btcjson.RPCError); ok &&
+				rpcErr.Code == btcjson.ErrRPCInvalidAddressOrKey {
+
+				// The transaction is no longer in the mempool.
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(batch[i].String(), entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkVerboseMempool invokes fn for each transaction of the verbose mempool as
+// described by WalkMempool.
+func (c *Client) walkVerboseMempool(ctx context.Context,
+	fn func(txid string, entry *btcjson.GetMempoolEntryResult) error) error {
+
+	mempool, err := c.GetRawMempoolVerbose()
+	if err != nil {
+		return err
+	}
+	for txid, tx := range mempool {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := &btcjson.GetMempoolEntryResult{
+			VSize:   tx.Vsize,
+			Size:    tx.Size,
+			Weight:  int64(tx.Weight),
+			Fee:     tx.Fee,
+			Time:    tx.Time,
+			Height:  tx.Height,
+			Fees:    btcjson.MempoolFees{Base: tx.Fee},
+			Depends: tx.Depends,
+		}
+		if err := fn(txid, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // FutureEstimateFeeResult is a future promise to deliver the result of a
 // EstimateFeeAsync RPC invocation (or an applicable error).
 type FutureEstimateFeeResult chan *Response
@@ -929,6 +1053,46 @@ func (c *Client) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartF
 	return c.EstimateSmartFeeAsync(confTarget, mode).Receive()
 }
 
+// EstimateSmartFeeCompat estimates the fee rate needed for a transaction to
+// confirm within confTarget blocks in the same manner as EstimateSmartFee,
+// regardless of whether the backend supports the estimatesmartfee RPC.
+//
+// The estimatesmartfee RPC is used for bitcoind backends.  btcd backends only
+// implement the estimatefee RPC, which is used instead, in which case the mode
+// is ignored since btcd does not distinguish between estimation modes.  When
+// no estimate is available, the returned result has a nil FeeRate and
+// describes why in its Errors field, as bitcoind does.
+func (c *Client) EstimateSmartFeeCompat(confTarget int64,
+	mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult,
+	error) {
+
+	version, err := c.BackendVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := version.(BtcdVersion); !ok {
+		return c.EstimateSmartFee(confTarget, mode)
+	}
+
+	feeRate, err := c.EstimateFee(confTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	// btcd reports the rate in BTC/kB, the same unit used by
+	// estimatesmartfee, but has no way of reporting that an estimate is
+	// unavailable other than a non-positive rate.
+	result := &btcjson.EstimateSmartFeeResult{Blocks: confTarget}
+	if feeRate <= 0 {
+		result.Errors = []string{"Insufficient data or no feerate found"}
+		return result, nil
+	}
+	result.FeeRate = &feeRate
+
+	return result, nil
+}
+
 // FutureVerifyChainResult is a future promise to deliver the result of a
 // VerifyChainAsync, VerifyChainLevelAsyncRPC, or VerifyChainBlocksAsync
 // invocation (or an applicable error).
@@ -1,7 +1,10 @@
 package rpcclient
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,7 +12,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
 )
 
 var upgrader = websocket.Upgrader{}
@@ -294,3 +299,207 @@ func makeUpgradeOnConnect(ch chan string) func(http.ResponseWriter, *http.Reques
 		}
 	}
 }
+
+// TestEstimateSmartFeeCompat ensures the fee estimate is requested with the
+// RPC supported by the backend and normalized into the estimatesmartfee
+// result.
+func TestEstimateSmartFeeCompat(t *testing.T) {
+	t.Parallel()
+
+	btcdFeeRate := "0.0002"
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			result := `{"feerate":0.0001,"blocks":6}`
+			if req.Method == "estimatefee" {
+				result = btcdFeeRate
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	newClient := func(version BackendVersion) *Client {
+		client, err := New(&ConnConfig{
+			Host:         strings.TrimPrefix(server.URL, "http://"),
+			User:         "user",
+			Pass:         "pass",
+			DisableTLS:   true,
+			HTTPPostMode: true,
+		}, nil)
+		require.NoError(t, err)
+		t.Cleanup(client.Shutdown)
+		client.backendVersion = version
+		return client
+	}
+
+	mode := &btcjson.EstimateModeEconomical
+	result, err := newClient(BitcoindPost25).EstimateSmartFeeCompat(6, mode)
+	require.NoError(t, err)
+	require.Equal(t, 0.0001, *result.FeeRate)
+	require.Equal(t, int64(6), result.Blocks)
+
+	btcdClient := newClient(BtcdPost2401)
+	result, err = btcdClient.EstimateSmartFeeCompat(3, mode)
+	require.NoError(t, err)
+	require.Equal(t, 0.0002, *result.FeeRate)
+	require.Equal(t, int64(3), result.Blocks)
+
+	// A non-positive rate from btcd means no estimate is available.
+	btcdFeeRate = "-1"
+	result, err = btcdClient.EstimateSmartFeeCompat(3, mode)
+	require.NoError(t, err)
+	require.Nil(t, result.FeeRate)
+	require.NotEmpty(t, result.Errors)
+}
+
+// TestBestBlock ensures the best block is requested with the RPC supported by
+// the backend.
+func TestBestBlock(t *testing.T) {
+	t.Parallel()
+
+	btcdHash := strings.Repeat("11", 32)
+	bitcoindHash := strings.Repeat("22", 32)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var result string
+			switch req.Method {
+			case "getbestblock":
+				result = fmt.Sprintf(`{"hash":"%s","height":10}`,
+					btcdHash)
+			case "getblockchaininfo":
+				result = fmt.Sprintf(`{"bestblockhash":"%s",`+
+					`"blocks":20}`, bitcoindHash)
+			default:
+				result = "null"
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	newClient := func(version BackendVersion) *Client {
+		client, err := New(&ConnConfig{
+			Host:         strings.TrimPrefix(server.URL, "http://"),
+			User:         "user",
+			Pass:         "pass",
+			DisableTLS:   true,
+			HTTPPostMode: true,
+		}, nil)
+		require.NoError(t, err)
+		t.Cleanup(client.Shutdown)
+		client.backendVersion = version
+		return client
+	}
+
+	hash, height, err := newClient(BtcdPost2401).BestBlock()
+	require.NoError(t, err)
+	require.Equal(t, btcdHash, hash.String())
+	require.EqualValues(t, 10, height)
+
+	hash, height, err = newClient(BitcoindPost25).BestBlock()
+	require.NoError(t, err)
+	require.Equal(t, bitcoindHash, hash.String())
+	require.EqualValues(t, 20, height)
+}
+
+// TestWalkMempool ensures WalkMempool invokes the callback for each mempool
+// transaction with both bitcoind and btcd backends.
+func TestWalkMempool(t *testing.T) {
+	t.Parallel()
+
+	txids := []string{
+		strings.Repeat("01", 32),
+		strings.Repeat("02", 32),
+		strings.Repeat("03", 32),
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var result string
+			switch {
+			case req.Method == "getmempoolentry" &&
+				strings.Contains(string(req.Params[0]), txids[1]):
+
+				// The second transaction left the mempool.
+				fmt.Fprintf(w, `{"result":null,"error":{"code":-5,`+
+					`"message":"not in mempool"},"id":%v}`,
+					req.ID)
+				return
+
+			case req.Method == "getmempoolentry":
+				result = `{"vsize":100,"fees":{"base":0.0001}}`
+
+			case string(req.Params[0]) == "true":
+				result = fmt.Sprintf(`{"%s":{"vsize":200,`+
+					`"fee":0.0002}}`, txids[0])
+
+			default:
+				txidsJSON, err := json.Marshal(txids)
+				require.NoError(t, err)
+				result = string(txidsJSON)
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	newClient := func(version BackendVersion) *Client {
+		client, err := New(&ConnConfig{
+			Host:         strings.TrimPrefix(server.URL, "http://"),
+			User:         "user",
+			Pass:         "pass",
+			DisableTLS:   true,
+			HTTPPostMode: true,
+		}, nil)
+		require.NoError(t, err)
+		t.Cleanup(client.Shutdown)
+		client.backendVersion = version
+		return client
+	}
+
+	entries := make(map[string]*btcjson.GetMempoolEntryResult)
+	walkFn := func(txid string, entry *btcjson.GetMempoolEntryResult) error {
+		entries[txid] = entry
+		return nil
+	}
+
+	ctx := context.Background()
+	err := newClient(BitcoindPost25).WalkMempool(ctx, walkFn)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, int32(100), entries[txids[0]].VSize)
+	require.Equal(t, 0.0001, entries[txids[2]].Fees.Base)
+
+	entries = make(map[string]*btcjson.GetMempoolEntryResult)
+	err = newClient(BtcdPost2401).WalkMempool(ctx, walkFn)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, int32(200), entries[txids[0]].VSize)
+	require.Equal(t, 0.0002, entries[txids[0]].Fees.Base)
+
+	// An error returned by the callback stops the walk.
+	errStop := errors.New("stop")
+	calls := 0
+	err = newClient(BitcoindPost25).WalkMempool(ctx,
+		func(string, *btcjson.GetMempoolEntryResult) error {
+			calls++
+			return errStop
+		},
+	)
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1, calls)
+}
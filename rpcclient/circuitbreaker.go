@@ -0,0 +1,141 @@
+package rpcclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests made while the client's circuit
+// breaker is open due to too many consecutive failures talking to the
+// backend.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	// defaultCircuitBreakerCooldown is the amount of time the circuit
+	// breaker stays open before a probe request is allowed through when
+	// the cooldown is not set in the connection configuration.
+	defaultCircuitBreakerCooldown = time.Second * 30
+)
+
+// CircuitState describes the state of a client's circuit breaker.
+type CircuitState uint8
+
+const (
+	// CircuitClosed indicates requests are sent to the backend normally.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen indicates the failure threshold has been reached and
+	// requests are failed immediately with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen indicates the cooldown has elapsed and a single
+	// probe request has been allowed through to test the backend.
+	CircuitHalfOpen
+)
+
+// String returns the CircuitState as a human-readable string.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker tracks consecutive failures talking to the backend and
+// short-circuits requests once a threshold has been reached.
+type circuitBreaker struct {
+	mtx       sync.Mutex
+	threshold uint32
+	cooldown  time.Duration
+	failures  uint32
+	state     CircuitState
+	openedAt  time.Time
+}
+
+// newCircuitBreaker returns a circuit breaker that opens after threshold
+// consecutive failures and allows a probe request after cooldown.
+func newCircuitBreaker(threshold uint32, cooldown time.Duration) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow returns ErrCircuitOpen if a request should not be sent to the backend.
+// Once the cooldown has elapsed a single probe request is allowed and the
+// cooldown is rearmed, so another probe is allowed later should the first one
+// never report back.
+func (b *circuitBreaker) allow() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == CircuitClosed {
+		return nil
+	}
+
+	if time.Since(b.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+
+	log.Debugf("Circuit breaker cooldown elapsed, allowing probe request")
+	b.state = CircuitHalfOpen
+	b.openedAt = time.Now()
+	return nil
+}
+
+// recordSuccess closes the circuit and resets the failure count.
+func (b *circuitBreaker) recordSuccess() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state != CircuitClosed {
+		log.Infof("Circuit breaker closed after successful request")
+	}
+	b.failures = 0
+	b.state = CircuitClosed
+}
+
+// recordFailure counts a failed request and opens the circuit once the
+// threshold is reached or a probe request fails.
+func (b *circuitBreaker) recordFailure() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			log.Warnf("Circuit breaker opened after %d consecutive "+
+				"failures", b.failures)
+		}
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// currentState returns the current state of the circuit breaker.
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.state
+}
+
+// CircuitBreakerState returns the current state of the client's circuit
+// breaker.  CircuitClosed is always returned when the circuit breaker is not
+// enabled via the CircuitBreakerThreshold connection option.
+func (c *Client) CircuitBreakerState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.currentState()
+}
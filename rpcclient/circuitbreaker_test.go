@@ -0,0 +1,51 @@
+package rpcclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCircuitBreaker ensures the circuit breaker opens after the configured
+// number of consecutive failures, allows a single probe once the cooldown has
+// elapsed, and closes again after a successful request.
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	b := newCircuitBreaker(3, 50*time.Millisecond)
+
+	// Failures below the threshold leave the circuit closed.
+	b.recordFailure()
+	b.recordFailure()
+	require.NoError(t, b.allow())
+	require.Equal(t, CircuitClosed, b.currentState())
+
+	// A success resets the consecutive failure count.
+	b.recordSuccess()
+	b.recordFailure()
+	b.recordFailure()
+	require.Equal(t, CircuitClosed, b.currentState())
+
+	// Reaching the threshold opens the circuit.
+	b.recordFailure()
+	require.Equal(t, CircuitOpen, b.currentState())
+	require.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// Once the cooldown elapses, a single probe is allowed through.
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, b.allow())
+	require.Equal(t, CircuitHalfOpen, b.currentState())
+	require.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// A failed probe reopens the circuit.
+	b.recordFailure()
+	require.Equal(t, CircuitOpen, b.currentState())
+
+	// A successful probe closes it.
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, b.allow())
+	b.recordSuccess()
+	require.Equal(t, CircuitClosed, b.currentState())
+	require.NoError(t, b.allow())
+}
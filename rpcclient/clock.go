@@ -0,0 +1,52 @@
+package rpcclient
+
+import "time"
+
+// Clock provides the current time and timers to the client, which allows
+// replacing the real clock in tests of time-dependent behavior such as
+// backoffs and caching.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel on which the current time is sent once the
+	// passed duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+
+	// Sleep pauses the calling goroutine for the passed duration.
+	Sleep(d time.Duration)
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+// Now returns the current time.
+//
+// This is part of the Clock interface.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns a channel on which the current time is sent once the passed
+// duration has elapsed.
+//
+// This is part of the Clock interface.
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Sleep pauses the calling goroutine for the passed duration.
+//
+// This is part of the Clock interface.
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// clock returns the Clock from the connection configuration, or the real clock
+// when none is set.
+func (config *ConnConfig) clock() Clock {
+	if config.Clock != nil {
+		return config.Clock
+	}
+	return realClock{}
+}
@@ -0,0 +1,167 @@
+package rpcclient
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a Clock whose time only advances when requested and whose
+// timers fire immediately, recording the durations waited for.
+type testClock struct {
+	mtx   sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+// Now returns the current time of the test clock.
+func (c *testClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// After records the passed duration and returns a channel which has already
+// fired.
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.Sleep(d)
+	fired := make(chan time.Time, 1)
+	fired <- c.Now()
+	return fired
+}
+
+// Sleep records the passed duration and returns immediately.
+func (c *testClock) Sleep(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.waits = append(c.waits, d)
+}
+
+// advance moves the current time of the test clock forward.
+func (c *testClock) advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestClockCookieCache ensures the cookie cache expires according to the
+// configured clock.
+func TestClockCookieCache(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".cookie")
+	writeCookie := func(cookie string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(cookie), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	writeCookie("user:first", modTime)
+
+	clock := &testClock{now: time.Now()}
+	config := &ConnConfig{CookiePath: path, Clock: clock}
+	_, pass, err := config.getAuth()
+	require.NoError(t, err)
+	require.Equal(t, "first", pass)
+
+	// The cached cookie is used until the cache duration has elapsed.
+	writeCookie("user:second", modTime.Add(time.Minute))
+	clock.advance(defaultCookieCacheDuration - time.Second)
+	_, pass, err = config.getAuth()
+	require.NoError(t, err)
+	require.Equal(t, "first", pass)
+
+	clock.advance(time.Second)
+	_, pass, err = config.getAuth()
+	require.NoError(t, err)
+	require.Equal(t, "second", pass)
+}
+
+// TestClockPendingRequests ensures the ages of the pending requests are
+// measured with the configured clock.
+func TestClockPendingRequests(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{now: time.Now()}
+	client := &Client{
+		config:      &ConnConfig{Clock: clock},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		shutdown:    make(chan struct{}),
+	}
+	for id := uint64(1); id <= 2; id++ {
+		require.NoError(t, client.addRequest(&jsonRequest{
+			id:           id,
+			method:       "getblockcount",
+			responseChan: make(chan *Response, 1),
+		}))
+		clock.advance(time.Minute)
+	}
+
+	pending := client.PendingRequests()
+	require.Len(t, pending, 2)
+	require.Equal(t, 2*time.Minute, pending[0].Age)
+	require.Equal(t, time.Minute, pending[1].Age)
+}
+
+// TestClockPostRetries ensures the backoffs between HTTP POST retries are
+// waited for using the configured clock.
+func TestClockPostRetries(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on the host, so every attempt fails.
+	clock := &testClock{}
+	client, err := New(&ConnConfig{
+		Host:         "127.0.0.1:1",
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		Clock:        clock,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	_, err = client.GetBlockCount()
+	require.Error(t, err)
+
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	require.Len(t, clock.waits, 9)
+	for i, wait := range clock.waits {
+		require.Equal(t, requestRetryInterval*time.Duration(i+1), wait)
+	}
+}
+
+// TestClockConnectBackoff ensures the backoffs between failed attempts of
+// Connect are waited for using the configured clock.
+func TestClockConnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on the host, so every attempt fails.
+	clock := &testClock{}
+	client, err := New(&ConnConfig{
+		Host:                "127.0.0.1:1",
+		User:                "user",
+		Pass:                "pass",
+		DisableTLS:          true,
+		DisableConnectOnNew: true,
+		Clock:               clock,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Error(t, client.Connect(3))
+
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	require.Equal(t, []time.Duration{
+		DefaultReconnectBackoff(1), DefaultReconnectBackoff(2),
+		DefaultReconnectBackoff(3),
+	}, clock.waits)
+}
@@ -0,0 +1,97 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// confirmationPollInterval is the interval at which the confirmations
+	// of a transaction waited for with WaitForConfirmations are polled.
+	confirmationPollInterval = 10 * time.Second
+)
+
+// WaitForConfirmations blocks until the passed wallet transaction has at least
+// n confirmations or the context is done, in which case the context's error is
+// returned.  The confirmations are queried with gettransaction each time a
+// block is connected, when the client receives block notifications, and
+// otherwise at a regular interval.  Each time the number of confirmations
+// changes, it is passed to fn, which may be nil.
+//
+// Should the transaction be removed from the main chain by a reorg, the number
+// of confirmations drops, and a transaction which conflicts with one in the
+// main chain, which gettransaction reports with negative confirmations, is
+// counted as having none.  In both cases the wait continues until the
+// transaction is confirmed again.
+//
+// NOTE: Block notifications are only used with a websocket connection and
+// non-nil notification handlers, in which case they are registered for if
+// they have not been already.
+func (c *Client) WaitForConfirmations(ctx context.Context,
+	txid *chainhash.Hash, n int64, fn func(confs int64)) error {
+
+	if txid == nil {
+		return errors.New("transaction hash must be specified")
+	}
+
+	// Check the confirmations again whenever a block is connected, in
+	// addition to the regular polling which covers missed notifications.
+	blockConnected := make(chan struct{}, 1)
+	if !c.config.HTTPPostMode && c.ntfnHandlers != nil {
+		remove := c.AddBlockHandler(func(int32, *wire.BlockHeader,
+			[]*btcutil.Tx) {
+
+			select {
+			case blockConnected <- struct{}{}:
+			default:
+			}
+		})
+		defer remove()
+
+		c.ntfnStateLock.Lock()
+		notifyBlocks := c.ntfnState.notifyBlocks
+		c.ntfnStateLock.Unlock()
+		if !notifyBlocks {
+			if err := c.NotifyBlocks(); err != nil {
+				return err
+			}
+		}
+	}
+
+	clock := c.config.clock()
+	cmd := btcjson.NewGetTransactionCmd(txid.String(), nil)
+	confs := int64(-1)
+	for {
+		var tx btcjson.GetTransactionResult
+		if err := c.CallContext(ctx, cmd, &tx); err != nil {
+			return err
+		}
+
+		current := tx.Confirmations
+		if current < 0 {
+			current = 0
+		}
+		if current != confs {
+			confs = current
+			if fn != nil {
+				fn(confs)
+			}
+		}
+		if confs >= n {
+			return nil
+		}
+
+		select {
+		case <-blockConnected:
+		case <-clock.After(confirmationPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
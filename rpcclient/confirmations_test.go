@@ -0,0 +1,150 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWaitForConfirmations ensures the confirmations of a transaction are
+// checked as blocks are connected, that reorgs and conflicts reset the count,
+// and that the wait ends once the requested confirmations are reached.
+func TestWaitForConfirmations(t *testing.T) {
+	t.Parallel()
+
+	var header bytes.Buffer
+	require.NoError(t, (&wire.BlockHeader{}).Serialize(&header))
+	headerHex := hex.EncodeToString(header.Bytes())
+
+	// Report the confirmations in turn, connecting a block after each
+	// report so the next one is requested.
+	confirmations := []int64{0, 1, -1, 2}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+
+				var result interface{}
+				if req.Method == "gettransaction" {
+					result = map[string]interface{}{
+						"confirmations": confirmations[0],
+					}
+					confirmations = confirmations[1:]
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": result,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+				if req.Method != "gettransaction" {
+					continue
+				}
+
+				err = conn.WriteJSON(map[string]interface{}{
+					"jsonrpc": "1.0",
+					"method":  "filteredblockconnected",
+					"params": []interface{}{
+						1, headerHex, []string{},
+					},
+					"id": nil,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, &NotificationHandlers{})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var seen []int64
+	err = client.WaitForConfirmations(ctx, &chainhash.Hash{}, 2,
+		func(confs int64) {
+			seen = append(seen, confs)
+		})
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1, 0, 2}, seen)
+
+	err = client.WaitForConfirmations(ctx, nil, 1, nil)
+	require.Error(t, err)
+}
+
+// TestWaitForConfirmationsPoll ensures the confirmations are polled at the
+// regular interval, waited for with the configured clock, when there are no
+// block notifications.
+func TestWaitForConfirmationsPoll(t *testing.T) {
+	t.Parallel()
+
+	confirmations := []int64{0, 0, 1}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			fmt.Fprintf(w, `{"result":{"confirmations":%d},`+
+				`"error":null,"id":%v}`, confirmations[0], req.ID)
+			confirmations = confirmations[1:]
+		},
+	))
+	defer server.Close()
+
+	clock := &testClock{}
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		Clock:        clock,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	err = client.WaitForConfirmations(
+		context.Background(), &chainhash.Hash{}, 1, nil,
+	)
+	require.NoError(t, err)
+
+	clock.mtx.Lock()
+	defer clock.mtx.Unlock()
+	require.Equal(t, []time.Duration{
+		confirmationPollInterval, confirmationPollInterval,
+	}, clock.waits)
+}
@@ -25,8 +25,12 @@ func readCookieFile(path string) (username, password string, err error) {
 	if err != nil {
 		return
 	}
-	s := scanner.Text()
+	return parseCookie(scanner.Text())
+}
 
+// parseCookie parses the username and password from the contents of a cookie
+// in the form username:password.
+func parseCookie(s string) (username, password string, err error) {
 	parts := strings.SplitN(s, ":", 2)
 	if len(parts) != 2 {
 		err = fmt.Errorf("malformed cookie file")
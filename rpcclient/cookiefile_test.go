@@ -0,0 +1,68 @@
+package rpcclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCookieEnvVar ensures the cookie is read from the configured environment
+// variable.
+func TestCookieEnvVar(t *testing.T) {
+	config := &ConnConfig{CookieEnvVar: "RPCCLIENT_TEST_COOKIE"}
+
+	_, _, err := config.getAuth()
+	require.ErrorContains(t, err, "is not set")
+
+	t.Setenv("RPCCLIENT_TEST_COOKIE", "__cookie__:secret\n")
+	user, pass, err := config.getAuth()
+	require.NoError(t, err)
+	require.Equal(t, "__cookie__", user)
+	require.Equal(t, "secret", pass)
+
+	t.Setenv("RPCCLIENT_TEST_COOKIE", "malformed")
+	_, _, err = config.getAuth()
+	require.ErrorContains(t, err, "malformed cookie")
+}
+
+// TestCookieCacheDuration ensures the credentials read from a cookie file are
+// reused for the configured cache duration, and the file is checked on every
+// request when caching is disabled.
+func TestCookieCacheDuration(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".cookie")
+	writeCookie := func(cookie string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(cookie), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	tests := []struct {
+		name          string
+		cacheDuration time.Duration
+		wantPass      string
+	}{
+		{"default", 0, "first"},
+		{"disabled", -1, "second"},
+	}
+	for _, test := range tests {
+		modTime := time.Now().Add(-time.Hour)
+		writeCookie("user:first", modTime)
+
+		config := &ConnConfig{
+			CookiePath:          path,
+			CookieCacheDuration: test.cacheDuration,
+		}
+		_, pass, err := config.getAuth()
+		require.NoError(t, err, test.name)
+		require.Equal(t, "first", pass, test.name)
+
+		writeCookie("user:second", modTime.Add(time.Minute))
+		_, pass, err = config.getAuth()
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.wantPass, pass, test.name)
+	}
+}
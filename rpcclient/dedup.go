@@ -0,0 +1,68 @@
+package rpcclient
+
+import (
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// inflightRequest tracks the callers waiting on a request which is shared by
+// identical requests made while it is in flight.
+type inflightRequest struct {
+	waiters []chan *Response
+}
+
+// sendRequestDeduplicated sends the passed request unless an identical request
+// is already in flight, in which case the caller shares its response instead.
+// Requests of the methods in MutatingMethods are always sent.  It returns the
+// channel on which the response will be delivered.
+func (c *Client) sendRequestDeduplicated(jReq *jsonRequest) chan *Response {
+	if _, ok := MutatingMethods[jReq.method]; ok {
+		c.sendRequest(jReq)
+		return jReq.responseChan
+	}
+
+	// Requests are identical when they only differ by id, so the key is
+	// the request marshalled with a fixed id.
+	key, err := btcjson.MarshalCmdWith(
+		btcjson.RpcVersion1, 0, jReq.cmd, c.marshalJSON,
+	)
+	if err != nil {
+		c.sendRequest(jReq)
+		return jReq.responseChan
+	}
+
+	responseChan := make(chan *Response, 1)
+
+	c.inflightMtx.Lock()
+	if req, ok := c.inflight[string(key)]; ok {
+		req.waiters = append(req.waiters, responseChan)
+		c.inflightMtx.Unlock()
+
+		log.Tracef("Sharing in-flight %s request", jReq.method)
+		return responseChan
+	}
+	if c.inflight == nil {
+		c.inflight = make(map[string]*inflightRequest)
+	}
+	req := &inflightRequest{waiters: []chan *Response{responseChan}}
+	c.inflight[string(key)] = req
+	c.inflightMtx.Unlock()
+
+	c.sendRequest(jReq)
+
+	// Deliver the response to every caller which made the request while it
+	// was in flight.
+	go func() {
+		resp := <-jReq.responseChan
+
+		c.inflightMtx.Lock()
+		delete(c.inflight, string(key))
+		waiters := req.waiters
+		c.inflightMtx.Unlock()
+
+		for _, waiter := range waiters {
+			waiter <- resp
+		}
+	}()
+
+	return responseChan
+}
@@ -0,0 +1,103 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeduplicateRequests ensures identical requests made while one is in
+// flight share its response, while requests with different params or of
+// mutating methods are each sent.
+func TestDeduplicateRequests(t *testing.T) {
+	t.Parallel()
+
+	var mtx sync.Mutex
+	requests := make(map[string]int)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			mtx.Lock()
+			requests[req.Method]++
+			mtx.Unlock()
+
+			// Hold the first request until the others have been
+			// made.
+			<-release
+
+			result := `"00"`
+			if req.Method == "getblockcount" {
+				result = "100"
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                strings.TrimPrefix(server.URL, "http://"),
+		User:                "user",
+		Pass:                "pass",
+		DisableTLS:          true,
+		HTTPPostMode:        true,
+		DeduplicateRequests: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	var counts []FutureGetBlockCountResult
+	for i := 0; i < 3; i++ {
+		counts = append(counts, client.GetBlockCountAsync())
+	}
+	hash1 := client.GetBlockHashAsync(1)
+	hash2 := client.GetBlockHashAsync(2)
+	send1 := client.SendCmd(btcjson.NewSendRawTransactionCmd("00", nil))
+	send2 := client.SendCmd(btcjson.NewSendRawTransactionCmd("00", nil))
+	close(release)
+
+	for _, future := range counts {
+		count, err := future.Receive()
+		require.NoError(t, err)
+		require.Equal(t, int64(100), count)
+	}
+	for _, future := range []FutureGetBlockHashResult{hash1, hash2} {
+		_, err := future.Receive()
+		require.NoError(t, err)
+	}
+	for _, future := range []chan *Response{send1, send2} {
+		_, err := ReceiveFuture(future)
+		require.NoError(t, err)
+	}
+
+	sent := func() map[string]int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		sent := make(map[string]int, len(requests))
+		for method, count := range requests {
+			sent[method] = count
+		}
+		return sent
+	}
+	require.Equal(t, map[string]int{
+		"getblockcount":      1,
+		"getblockhash":       2,
+		"sendrawtransaction": 2,
+	}, sent())
+
+	// Once the response has been delivered, the request is sent again.
+	_, err = client.GetBlockCount()
+	require.NoError(t, err)
+	require.Equal(t, 2, sent()["getblockcount"])
+}
@@ -0,0 +1,65 @@
+package rpcclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// ErrDescriptorsUnsupported is returned by DeriveAddressRange when the backend
+// does not support output descriptors, as is the case for btcd.
+var ErrDescriptorsUnsupported = errors.New("backend does not support output " +
+	"descriptors")
+
+// DeriveAddressRange derives the addresses corresponding to the passed output
+// descriptor.  The descriptor is first analysed with getdescriptorinfo, which
+// validates it and, when it carries a checksum, ensures the checksum matches,
+// while one is added otherwise.  For ranged descriptors, the addresses at the
+// indexes from start through end, inclusive, are derived, while the range is
+// ignored otherwise.  An error wrapping ErrDescriptorsUnsupported is returned
+// when the backend lacks descriptor support.
+func (c *Client) DeriveAddressRange(descriptor string, start,
+	end int) (btcjson.DeriveAddressesResult, error) {
+
+	info, err := c.GetDescriptorInfo(descriptor)
+	if err != nil {
+		return nil, descriptorErr(err)
+	}
+
+	if i := strings.LastIndex(descriptor, "#"); i >= 0 {
+		if checksum := descriptor[i+1:]; checksum != info.Checksum {
+			return nil, fmt.Errorf("descriptor checksum %s does "+
+				"not match expected checksum %s", checksum,
+				info.Checksum)
+		}
+	} else {
+		descriptor += "#" + info.Checksum
+	}
+
+	var descriptorRange *btcjson.DescriptorRange
+	if info.IsRange {
+		descriptorRange = &btcjson.DescriptorRange{
+			Value: []int{start, end},
+		}
+	}
+	addrs, err := c.DeriveAddresses(descriptor, descriptorRange)
+	if err != nil {
+		return nil, descriptorErr(err)
+	}
+	return *addrs, nil
+}
+
+// descriptorErr wraps the passed error from a descriptor RPC with
+// ErrDescriptorsUnsupported when it indicates the backend does not know the
+// method.
+func descriptorErr(err error) error {
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) &&
+		rpcErr.Code == btcjson.ErrRPCMethodNotFound.Code {
+
+		return fmt.Errorf("%w: %v", ErrDescriptorsUnsupported, err)
+	}
+	return err
+}
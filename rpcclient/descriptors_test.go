@@ -0,0 +1,97 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeriveAddressRange ensures the descriptor checksum is validated or added
+// before deriving addresses, and that backends without descriptor support are
+// reported as such.
+func TestDeriveAddressRange(t *testing.T) {
+	t.Parallel()
+
+	const (
+		descriptor = "wpkh(xpub/0/*)"
+		checksum   = "abcd1234"
+	)
+	supported := true
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			if !supported {
+				fmt.Fprintf(w, `{"result":null,"error":{"code":`+
+					`-32601,"message":"Method not found"},`+
+					`"id":%v}`, req.ID)
+				return
+			}
+
+			var result interface{}
+			switch req.Method {
+			case "getdescriptorinfo":
+				result = map[string]interface{}{
+					"checksum": checksum,
+					"isrange":  true,
+				}
+			case "deriveaddresses":
+				var desc string
+				var rng []int
+				require.NoError(t, json.Unmarshal(
+					req.Params[0], &desc,
+				))
+				require.NoError(t, json.Unmarshal(
+					req.Params[1], &rng,
+				))
+				require.Equal(t, descriptor+"#"+checksum, desc)
+				result = []string{
+					fmt.Sprintf("addr%d", rng[0]),
+					fmt.Sprintf("addr%d", rng[1]),
+				}
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(
+				map[string]interface{}{
+					"result": result,
+					"error":  nil,
+					"id":     req.ID,
+				},
+			))
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// The checksum is added when missing.
+	addrs, err := client.DeriveAddressRange(descriptor, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, btcjson.DeriveAddressesResult{"addr2", "addr3"}, addrs)
+
+	addrs, err = client.DeriveAddressRange(descriptor+"#"+checksum, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, btcjson.DeriveAddressesResult{"addr0", "addr1"}, addrs)
+
+	_, err = client.DeriveAddressRange(descriptor+"#00000000", 0, 1)
+	require.ErrorContains(t, err, "does not match")
+
+	supported = false
+	_, err = client.DeriveAddressRange(descriptor, 0, 1)
+	require.ErrorIs(t, err, ErrDescriptorsUnsupported)
+}
@@ -0,0 +1,117 @@
+package rpcclient
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultErrorHistorySize is the number of the most recent errors
+	// recorded for retrieval with RecentErrors when the size is not set in
+	// the connection configuration.
+	defaultErrorHistorySize = 16
+)
+
+// ErrorSource describes where an error recorded in the error history of a
+// client originated.
+type ErrorSource uint8
+
+const (
+	// ErrorSourceTransport indicates an error sending a request to or
+	// receiving a message from the server.
+	ErrorSourceTransport ErrorSource = iota
+
+	// ErrorSourceRPC indicates an error returned by the server which was
+	// not delivered to any caller.
+	ErrorSourceRPC
+
+	// ErrorSourceReconnect indicates an error re-establishing the
+	// connection to the server or its notification state.
+	ErrorSourceReconnect
+)
+
+// String returns the ErrorSource as a human-readable string.
+func (s ErrorSource) String() string {
+	switch s {
+	case ErrorSourceTransport:
+		return "transport"
+	case ErrorSourceRPC:
+		return "rpc"
+	case ErrorSourceReconnect:
+		return "reconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// TimestampedError is an error recorded in the error history of a client.
+type TimestampedError struct {
+	// Err is the recorded error.
+	Err error
+
+	// Source is where the error originated.
+	Source ErrorSource
+
+	// Time is the time the error was recorded.
+	Time time.Time
+}
+
+// errorHistory is a bounded ring buffer of the most recent errors encountered
+// by a client.
+type errorHistory struct {
+	mtx    sync.Mutex
+	errs   []TimestampedError
+	next   int
+	filled bool
+}
+
+// newErrorHistory returns an error history which records up to size errors.
+func newErrorHistory(size int) *errorHistory {
+	return &errorHistory{
+		errs: make([]TimestampedError, size),
+	}
+}
+
+// recordError adds the passed error to the error history, replacing the oldest
+// one when it is full.  It has no effect when the history is not enabled.
+func (c *Client) recordError(source ErrorSource, err error) {
+	h := c.errHistory
+	if h == nil {
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.errs[h.next] = TimestampedError{
+		Err:    err,
+		Source: source,
+		Time:   time.Now(),
+	}
+	h.next = (h.next + 1) % len(h.errs)
+	if h.next == 0 {
+		h.filled = true
+	}
+}
+
+// RecentErrors returns the most recent errors encountered by the client which
+// were otherwise only logged, such as failed request attempts that were
+// retried, websocket read errors, and failed reconnect attempts, oldest first.
+// The number of errors kept is set by the ErrorHistorySize connection option.
+// It returns nil when the history is disabled.
+func (c *Client) RecentErrors() []TimestampedError {
+	h := c.errHistory
+	if h == nil {
+		return nil
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if !h.filled {
+		return append([]TimestampedError(nil), h.errs[:h.next]...)
+	}
+	errs := make([]TimestampedError, 0, len(h.errs))
+	errs = append(errs, h.errs[h.next:]...)
+	return append(errs, h.errs[:h.next]...)
+}
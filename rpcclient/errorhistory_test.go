@@ -0,0 +1,63 @@
+package rpcclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRecentErrors ensures the error history records the most recent errors,
+// oldest first, up to its size, and that errors which are otherwise only
+// logged are recorded.
+func TestRecentErrors(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:     &ConnConfig{},
+		errHistory: newErrorHistory(3),
+	}
+	require.Nil(t, (&Client{}).RecentErrors())
+	require.Empty(t, client.RecentErrors())
+
+	// An invalid message from the server is recorded as a transport
+	// error.
+	client.handleMessage([]byte("{"))
+	errs := client.RecentErrors()
+	require.Len(t, errs, 1)
+	require.Equal(t, ErrorSourceTransport, errs[0].Source)
+	require.Error(t, errs[0].Err)
+	require.False(t, errs[0].Time.IsZero())
+
+	// Only the most recent errors are kept once the history is full.
+	for _, msg := range []string{"a", "b", "c"} {
+		client.recordError(ErrorSourceReconnect, errors.New(msg))
+	}
+	var msgs []string
+	for _, err := range client.RecentErrors() {
+		require.Equal(t, ErrorSourceReconnect, err.Source)
+		msgs = append(msgs, err.Err.Error())
+	}
+	require.Equal(t, []string{"a", "b", "c"}, msgs)
+
+	// The history is enabled by default and may be disabled.
+	client, err := New(&ConnConfig{
+		Host:                "127.0.0.1:8334",
+		DisableTLS:          true,
+		HTTPPostMode:        true,
+		DisableConnectOnNew: true,
+	}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, client.errHistory)
+	client.Shutdown()
+
+	client, err = New(&ConnConfig{
+		Host:             "127.0.0.1:8334",
+		DisableTLS:       true,
+		HTTPPostMode:     true,
+		ErrorHistorySize: -1,
+	}, nil)
+	require.NoError(t, err)
+	require.Nil(t, client.RecentErrors())
+	client.Shutdown()
+}
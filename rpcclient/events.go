@@ -0,0 +1,163 @@
+package rpcclient
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionEventType identifies the kind of a ConnectionEvent.
+type ConnectionEventType uint8
+
+const (
+	// EventConnected indicates the initial websocket connection was
+	// established.
+	EventConnected ConnectionEventType = iota
+
+	// EventDisconnected indicates the websocket connection was closed.
+	EventDisconnected
+
+	// EventReconnecting indicates an attempt to re-establish the websocket
+	// connection is about to be made.
+	EventReconnecting
+
+	// EventReconnected indicates the websocket connection was
+	// re-established.
+	EventReconnected
+
+	// EventShutdown indicates the client was shut down.  It is the last
+	// event delivered.
+	EventShutdown
+)
+
+// String returns the ConnectionEventType as a human-readable string.
+func (t ConnectionEventType) String() string {
+	switch t {
+	case EventConnected:
+		return "connected"
+	case EventDisconnected:
+		return "disconnected"
+	case EventReconnecting:
+		return "reconnecting"
+	case EventReconnected:
+		return "reconnected"
+	case EventShutdown:
+		return "shutdown"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionEvent describes a change in the state of the connection of a
+// client as delivered by Events.
+type ConnectionEvent struct {
+	// Type is the kind of the event.
+	Type ConnectionEventType
+
+	// Err is the error which caused the connection to be lost for
+	// EventDisconnected events.  It is nil when the disconnect was
+	// requested with Disconnect or Shutdown, and for the other events.
+	Err error
+
+	// Attempt is the number of the reconnect attempt, starting from one,
+	// for EventReconnecting events.
+	Attempt int64
+
+	// Time is when the event occurred.
+	Time time.Time
+}
+
+// eventStream buffers the connection events of a client, discarding the
+// oldest events once the buffer is full so a slow or absent consumer never
+// stalls the client.
+type eventStream struct {
+	mtx    sync.Mutex
+	ch     chan ConnectionEvent
+	closed bool
+}
+
+// newEventStream returns a new event stream which buffers the passed number of
+// events.
+func newEventStream(size int) *eventStream {
+	return &eventStream{ch: make(chan ConnectionEvent, size)}
+}
+
+// emit adds the passed event to the stream, dropping the oldest buffered event
+// when the buffer is full.  Events emitted after the stream was closed are
+// ignored.
+//
+// This function is safe for concurrent access.
+func (s *eventStream) emit(ev ConnectionEvent) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.closed {
+		s.send(ev)
+	}
+}
+
+// send adds the passed event to the stream as described by emit.  It must be
+// called with the stream mutex held.
+func (s *eventStream) send(ev ConnectionEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for {
+		select {
+		case s.ch <- ev:
+			return
+		default:
+		}
+
+		// The buffer is full, so drop the oldest event to make room.
+		// The consumer may have drained the buffer in the meantime, in
+		// which case the send is simply retried.
+		select {
+		case dropped := <-s.ch:
+			log.Debugf("Dropping connection event %v", dropped.Type)
+		default:
+		}
+	}
+}
+
+// shutdown emits the shutdown event and closes the stream unless it was
+// already closed.
+//
+// This function is safe for concurrent access.
+func (s *eventStream) shutdown() {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.send(ConnectionEvent{Type: EventShutdown})
+	s.closed = true
+	close(s.ch)
+}
+
+// Events returns the stream of connection lifecycle events of the client.  The
+// same channel is returned on every call, so the events are delivered to only
+// one of several concurrent consumers.  The channel buffers the number of
+// events set by the EventBufferSize connection option, and once the buffer is
+// full the oldest events are dropped to make room for new ones, so the most
+// recent state is always available, even when the channel is read only
+// occasionally.  The channel is closed after the EventShutdown event once the
+// client is shut down.
+//
+// Events are emitted for the websocket connection only, apart from
+// EventShutdown, so in HTTP POST mode the stream only reports the shutdown.
+// It returns nil when the events are disabled with a negative EventBufferSize.
+func (c *Client) Events() <-chan ConnectionEvent {
+	if c.events == nil {
+		return nil
+	}
+	return c.events.ch
+}
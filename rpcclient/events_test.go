@@ -0,0 +1,129 @@
+package rpcclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEvents ensures the connection lifecycle events are delivered in order
+// as the client connects, loses its connection, reconnects and shuts down.
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	drop := make(chan struct{})
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// Drop the first connection once requested.
+			if atomic.AddInt32(&connections, 1) == 1 {
+				go func() {
+					<-drop
+					conn.Close()
+				}()
+			}
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": 1,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ReconnectBackoff: func(int64) time.Duration {
+			return 10 * time.Millisecond
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	events := client.Events()
+	next := func() ConnectionEvent {
+		t.Helper()
+
+		select {
+		case ev, ok := <-events:
+			require.True(t, ok, "events channel closed")
+			require.False(t, ev.Time.IsZero())
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+			return ConnectionEvent{}
+		}
+	}
+
+	require.Equal(t, EventConnected, next().Type)
+
+	// Losing the connection reports the error which caused it.
+	close(drop)
+	ev := next()
+	require.Equal(t, EventDisconnected, ev.Type)
+	require.Error(t, ev.Err)
+
+	ev = next()
+	require.Equal(t, EventReconnecting, ev.Type)
+	require.EqualValues(t, 1, ev.Attempt)
+	require.Equal(t, EventReconnected, next().Type)
+
+	// Shutting down disconnects without an error and closes the channel
+	// after the shutdown event.
+	client.Shutdown()
+	ev = next()
+	require.Equal(t, EventDisconnected, ev.Type)
+	require.NoError(t, ev.Err)
+	require.Equal(t, EventShutdown, next().Type)
+
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+// TestEventStreamDropOldest ensures the oldest events are dropped once the
+// buffer of the event stream is full.
+func TestEventStreamDropOldest(t *testing.T) {
+	t.Parallel()
+
+	s := newEventStream(2)
+	for i := int64(1); i <= 3; i++ {
+		s.emit(ConnectionEvent{Type: EventReconnecting, Attempt: i})
+	}
+	s.shutdown()
+
+	var got []ConnectionEvent
+	for ev := range s.ch {
+		got = append(got, ev)
+	}
+	require.Len(t, got, 2)
+	require.EqualValues(t, 3, got[0].Attempt)
+	require.Equal(t, EventShutdown, got[1].Type)
+
+	// Events emitted after the shutdown are ignored.
+	s.emit(ConnectionEvent{Type: EventConnected})
+}
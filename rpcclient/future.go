@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package rpcclient
+
+// Future is a future promise to deliver the result of a command sent with
+// SendCmdFuture (or an applicable error), unmarshalled into a value of type T.
+type Future[T any] struct {
+	responseChan chan *Response
+
+	// unmarshal decodes the result with the codec of the client which sent
+	// the command.
+	unmarshal func(data []byte, v interface{}) error
+}
+
+// Receive waits for the Response promised by the future and returns the result
+// unmarshalled into a value of type T.
+func (f Future[T]) Receive() (T, error) {
+	var result T
+	res, err := ReceiveFuture(f.responseChan)
+	if err != nil {
+		return result, err
+	}
+
+	err = f.unmarshal(res, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// SendCmdFuture sends the passed command to the server associated with the
+// client and returns a typed future whose Receive method unmarshals the result
+// into a value of type T, using the Codec or JSONUnmarshal function of the
+// client if set.  This simplifies writing wrappers for commands which do not
+// yet have a dedicated method, for example:
+//
+//	f := SendCmdFuture[int64](client, btcjson.NewGetBlockCountCmd())
+//	count, err := f.Receive()
+func SendCmdFuture[T any](c *Client, cmd interface{}) Future[T] {
+	return Future[T]{
+		responseChan: c.SendCmd(cmd),
+		unmarshal:    c.unmarshalJSON,
+	}
+}
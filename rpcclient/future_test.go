@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package rpcclient
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFutureReceive ensures a typed future unmarshals the result into the
+// requested type with the codec of the client and passes errors through.
+func TestFutureReceive(t *testing.T) {
+	t.Parallel()
+
+	var unmarshalled []string
+	client := &Client{config: &ConnConfig{
+		JSONUnmarshal: func(data []byte, v interface{}) error {
+			unmarshalled = append(unmarshalled, string(data))
+			return json.Unmarshal(data, v)
+		},
+	}}
+
+	f := Future[map[string]int64]{
+		responseChan: make(chan *Response, 1),
+		unmarshal:    client.unmarshalJSON,
+	}
+	f.responseChan <- &Response{result: []byte(`{"blocks":42}`)}
+	res, err := f.Receive()
+	require.NoError(t, err)
+	require.Equal(t, int64(42), res["blocks"])
+	require.Equal(t, []string{`{"blocks":42}`}, unmarshalled)
+
+	errFuture := Future[int64]{
+		responseChan: make(chan *Response, 1),
+		unmarshal:    client.unmarshalJSON,
+	}
+	errFuture.responseChan <- &Response{err: errors.New("boom")}
+	_, err = errFuture.Receive()
+	require.EqualError(t, err, "boom")
+	require.Len(t, unmarshalled, 1)
+}
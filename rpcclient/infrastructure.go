@@ -6,8 +6,10 @@ package rpcclient
 
 import (
 	"bytes"
+	"compress/gzip"
 	"container/list"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -21,6 +23,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -69,6 +72,12 @@ var (
 	ErrNotWebsocketClient = errors.New("client is not configured for " +
 		"websockets")
 
+	// ErrNotPostClient is an error to describe the condition of calling a
+	// Client method intended for a client running in HTTP POST mode when
+	// the client has been configured to use websockets instead.
+	ErrNotPostClient = errors.New("client is not configured for HTTP " +
+		"POST mode")
+
 	// ErrClientAlreadyConnected is an error to describe the condition where
 	// a new client connection cannot be established due to a websocket
 	// client having already connected to the RPC server.
@@ -77,6 +86,22 @@ var (
 
 	// ErrEmptyBatch is an error to describe that there is nothing to send.
 	ErrEmptyBatch = errors.New("batch is empty")
+
+	// ErrRequestCanceled is an error to describe the condition where an
+	// outstanding request was abandoned by a call to CancelAllRequests
+	// before a reply was received.
+	ErrRequestCanceled = errors.New("the request was canceled")
+
+	// ErrNoResponse is an error to describe the condition where the
+	// response to a batch did not include a response for one of the
+	// requests in the batch.
+	ErrNoResponse = errors.New("no response received for the request")
+
+	// ErrRequestTimeout is an error to describe the condition where an
+	// outstanding request was abandoned because no reply was received
+	// within the RequestTTL set in the connection configuration.
+	ErrRequestTimeout = errors.New("no response received before the " +
+		"request expired")
 )
 
 const (
@@ -88,6 +113,11 @@ const (
 	// channel can queue before blocking.
 	sendPostBufferSize = 100
 
+	// defaultNtfnQueueSize is the number of notifications which may be
+	// queued for the asynchronous notification handler when the queue size
+	// is not set in the connection configuration.
+	defaultNtfnQueueSize = 100
+
 	// connectionRetryInterval is the amount of time to wait in between
 	// retries when automatically reconnecting to an RPC server.
 	connectionRetryInterval = time.Second * 5
@@ -99,6 +129,34 @@ const (
 	// defaultHTTPTimeout is the default timeout for an http request, so the
 	// request does not block indefinitely.
 	defaultHTTPTimeout = time.Minute * 10
+
+	// defaultHandshakeTimeout is the default amount of time to wait for the
+	// websocket handshake to complete, so a stalled dial attempt does not
+	// block indefinitely.
+	defaultHandshakeTimeout = time.Second * 30
+
+	// defaultCookieCacheDuration is the amount of time the credentials
+	// read from a cookie file are reused before checking the file again
+	// when the duration is not set in the connection configuration.
+	defaultCookieCacheDuration = time.Second * 30
+
+	// defaultKeepAliveRPCMethod is the method of the keepalive RPC when
+	// the method is not set in the connection configuration.
+	defaultKeepAliveRPCMethod = "getblockcount"
+
+	// defaultBlockChannelSize is the number of block events buffered by
+	// the channels returned by BlockChannel when the size is not set in the
+	// connection configuration.
+	defaultBlockChannelSize = 16
+
+	// answeredIDWindow is the number of the most recently answered request
+	// ids which are remembered to detect duplicate responses.
+	answeredIDWindow = 256
+
+	// defaultEventBufferSize is the number of connection events buffered
+	// by the channel returned by Events when the size is not set in the
+	// connection configuration.
+	defaultEventBufferSize = 32
 )
 
 // jsonRequest holds information about a json request that is used to properly
@@ -109,6 +167,65 @@ type jsonRequest struct {
 	cmd            interface{}
 	marshalledJSON []byte
 	responseChan   chan *Response
+
+	// batch indicates the marshalled JSON is an array of requests, in
+	// which case the raw JSON array of responses is delivered on the
+	// response channel.
+	batch bool
+
+	// addedAt is the time the request was added to the client's pending
+	// requests.
+	addedAt time.Time
+
+	// ctx is the context of the request when it was issued with
+	// CallContext.  It is nil otherwise.
+	ctx context.Context
+
+	// requestID is the application-level correlation id attached to the
+	// context of the request with WithRequestID, if any.
+	requestID string
+
+	// tries is the maximum number of times the request is sent, including
+	// resends after reconnects in websocket mode, when it was issued with
+	// the WithRetries call option.  The default policy applies when zero.
+	tries int
+
+	// resends is the number of times the request has been resent after a
+	// reconnect.  It is protected by the request lock.
+	resends int
+
+	// headers are additional HTTP headers sent with the request in HTTP
+	// POST mode when it was issued with the WithHeaders call option.
+	headers http.Header
+}
+
+// String returns a description of the request for log messages, including its
+// correlation id when it has one.
+func (jReq *jsonRequest) String() string {
+	if jReq.requestID != "" {
+		return fmt.Sprintf("[%s] with id %d (request id %s)",
+			jReq.method, jReq.id, jReq.requestID)
+	}
+	return fmt.Sprintf("[%s] with id %d", jReq.method, jReq.id)
+}
+
+// requestIDKey is the context key for the correlation id attached to a context
+// with WithRequestID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the passed context carrying the passed
+// application-level correlation id, such as a trace id.  The id is included in
+// the client's log messages for requests issued with CallContext using the
+// returned context, which allows correlating them with the application's logs.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the correlation id attached to the passed
+// context with WithRequestID, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
 }
 
 // Client represents a Bitcoin RPC client which allows easy access to the
@@ -146,12 +263,34 @@ type Client struct {
 	backendVersionMu sync.Mutex
 	backendVersion   BackendVersion
 
+	// supportedMethods caches whether the backend the client is currently
+	// connected to supports a method.  This should be retrieved through
+	// HasMethod.
+	supportedMethodsMu sync.Mutex
+	supportedMethods   map[string]bool
+
 	// mtx is a mutex to protect access to connection related fields.
 	mtx sync.Mutex
 
 	// disconnected indicated whether or not the server is disconnected.
 	disconnected bool
 
+	// connectedAt is the time the current connection was established.
+	connectedAt time.Time
+
+	// lastCloseCode and lastCloseText describe why the websocket
+	// connection was last closed.
+	lastCloseCode int
+	lastCloseText string
+
+	// disconnectErr is the error which caused the websocket connection to
+	// be lost, if any.  It is reported by the disconnected event.
+	disconnectErr error
+
+	// postFailures is the number of consecutive failed HTTP POST
+	// requests.  It is protected by mtx.
+	postFailures uint32
+
 	// whether or not to batch requests, false unless changed by Batch()
 	batch     bool
 	batchLock sync.Mutex
@@ -166,11 +305,87 @@ type Client struct {
 	requestMap  map[uint64]*list.Element
 	requestList *list.List
 
+	// answeredIDs holds the ids of the most recently answered requests so
+	// duplicate responses can be detected.  It is only accessed by the
+	// websocket input handler and is nil unless duplicate responses are
+	// handled.
+	answeredIDs *answeredIDs
+
+	// breaker short-circuits HTTP POST requests while the backend is
+	// failing.  It is nil when the circuit breaker is disabled.
+	breaker *circuitBreaker
+
+	// retryBudget limits the aggregate number of HTTP POST retries and
+	// reconnect attempts.  It is nil when no retry budget is configured.
+	retryBudget *retryBudget
+
 	// Notifications.
 	ntfnHandlers  *NotificationHandlers
 	ntfnStateLock sync.Mutex
 	ntfnState     *notificationState
 
+	// ntfnQueue holds notifications waiting to be dispatched by
+	// ntfnHandler.  It is nil unless asynchronous notifications are
+	// enabled.
+	ntfnQueue chan *rawNotification
+
+	// ntfnHistory holds the most recently received notifications.  It is
+	// nil unless the notification history is enabled.
+	ntfnHistory *notificationHistory
+
+	// errHistory holds the most recent errors which were otherwise only
+	// logged.  It is nil when the error history is disabled.
+	errHistory *errorHistory
+
+	// ntfnPaused indicates notification delivery has been paused with
+	// PauseNotifications, and pausedNtfns holds the notifications
+	// received while paused when they are buffered.
+	ntfnPauseMtx sync.Mutex
+	ntfnPaused   bool
+	pausedNtfns  []*rawNotification
+
+	// rescanProgress is the most recent progress reported by the server
+	// for the current rescan.  It is protected by ntfnStateLock.
+	rescanProgress *RescanProgress
+
+	// blockHandlerMap holds the additional block handlers registered with
+	// AddBlockHandler keyed by their registration order.
+	blockHandlersMtx   sync.Mutex
+	blockHandlerMap    map[uint64]BlockHandler
+	nextBlockHandlerID uint64
+
+	// addrWatcherMap holds the address watchers registered with
+	// WatchAddress.
+	addrWatchersMtx   sync.Mutex
+	addrWatcherMap    map[uint64]*addressWatcher
+	nextAddrWatcherID uint64
+
+	// inflight holds the requests which are in flight keyed by their
+	// contents when requests are deduplicated.
+	inflightMtx sync.Mutex
+	inflight    map[string]*inflightRequest
+
+	// coalescedBlock is the most recent block connected notification which
+	// has not been delivered yet when block connected notifications are
+	// coalesced, in which case coalescedBlockSignal is non-nil.
+	coalescedBlockMtx    sync.Mutex
+	coalescedBlock       *coalescedBlock
+	coalescedBlockSignal chan struct{}
+
+	// metrics collects the request metrics reported by Metrics.  It is nil
+	// unless the CollectMetrics connection option is set.
+	metrics *clientMetrics
+
+	// events holds the connection events delivered by Events.
+	events *eventStream
+
+	// pingInterval is the interval at which websocket pings are sent, and
+	// pingQuit stops the goroutine sending them for the current
+	// connection.  They are protected by pingMtx.
+	pingMtx      sync.Mutex
+	pingInterval time.Duration
+	pingQuit     chan struct{}
+
 	// Networking infrastructure.
 	sendChan        chan []byte
 	sendPostChan    chan *jsonRequest
@@ -190,6 +405,26 @@ func (c *Client) NextID() uint64 {
 	return atomic.AddUint64(&c.id, 1)
 }
 
+// PeekID returns the id of the most recently sent JSON-RPC message without
+// incrementing the counter.  The next call to NextID will return this value
+// plus one.
+func (c *Client) PeekID() uint64 {
+	return atomic.LoadUint64(&c.id)
+}
+
+// SetID seeds the id counter used for JSON-RPC messages so the next call to
+// NextID returns id plus one.  This is useful for reproducible tests or for
+// resuming an id sequence across process restarts.
+//
+// NOTE: Although the counter is updated atomically, SetID should only be
+// called before the client is used to send any requests.  Lowering the
+// counter while requests are outstanding may cause a new request to reuse the
+// id of a pending one, in which case responses may be routed to the wrong
+// caller.
+func (c *Client) SetID(id uint64) {
+	atomic.StoreUint64(&c.id, id)
+}
+
 // addRequest associates the passed jsonRequest with its id.  This allows the
 // response from the remote server to be unmarshalled to the appropriate type
 // and sent to the specified channel when it is received.
@@ -210,10 +445,11 @@ func (c *Client) addRequest(jReq *jsonRequest) error {
 	// ErrClientShutdown).
 	select {
 	case <-c.shutdown:
-		return ErrClientShutdown
+		return c.connErr(ErrClientShutdown)
 	default:
 	}
 
+	jReq.addedAt = c.config.clock().Now()
 	if !c.batch {
 		element := c.requestList.PushBack(jReq)
 		c.requestMap[jReq.id] = element
@@ -227,6 +463,60 @@ func (c *Client) addRequest(jReq *jsonRequest) error {
 	return nil
 }
 
+// PendingRequest describes a request which has been sent to the server and is
+// awaiting a response.
+type PendingRequest struct {
+	// ID is the JSON-RPC id of the request.
+	ID uint64
+
+	// Method is the JSON-RPC method of the request.
+	Method string
+
+	// Age is the amount of time since the request was sent.
+	Age time.Duration
+}
+
+// PendingRequests returns the requests which are awaiting a response from the
+// server, oldest first.  This is intended as a diagnostic aid, for example to
+// detect a backend which has stopped responding to a particular method.
+//
+// NOTE: Requests issued in HTTP POST mode are not tracked by the client once
+// they have been handed to the HTTP client, so they are not included.
+//
+// This function is safe for concurrent access.
+func (c *Client) PendingRequests() []PendingRequest {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	now := c.config.clock().Now()
+	pending := make([]PendingRequest, 0, c.requestList.Len())
+	for e := c.requestList.Front(); e != nil; e = e.Next() {
+		req := e.Value.(*jsonRequest)
+		pending = append(pending, PendingRequest{
+			ID:     req.id,
+			Method: req.method,
+			Age:    now.Sub(req.addedAt),
+		})
+	}
+
+	return pending
+}
+
+// PendingRequestCount returns the number of requests which are awaiting a
+// response from the server.  A count which keeps growing indicates responses
+// are not being received, which RequestTTL guards against.
+//
+// NOTE: Requests issued in HTTP POST mode are not tracked by the client once
+// they have been handed to the HTTP client, so they are not counted.
+//
+// This function is safe for concurrent access.
+func (c *Client) PendingRequestCount() int {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	return len(c.requestMap)
+}
+
 // removeRequest returns and removes the jsonRequest which contains the response
 // channel and original method associated with the passed id or nil if there is
 // no association.
@@ -264,6 +554,59 @@ func (c *Client) removeAllRequests() {
 	c.requestList.Init()
 }
 
+// expireRequests removes the requests which have been awaiting a response for
+// at least the configured RequestTTL as of the passed time, delivering
+// ErrRequestTimeout to each of them.  Only the requests sent over the websocket
+// connection are considered, since the requests queued by a batch client are
+// sent in HTTP POST mode, where RequestTimeout applies instead.
+//
+// This function is safe for concurrent access.
+func (c *Client) expireRequests(now time.Time) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// Requests are kept in the order they were added, so stop at the
+	// first one which has not expired yet.
+	var nextElem *list.Element
+	for e := c.requestList.Front(); e != nil; e = nextElem {
+		nextElem = e.Next()
+
+		jReq := e.Value.(*jsonRequest)
+		if now.Sub(jReq.addedAt) < c.config.RequestTTL {
+			break
+		}
+
+		log.Warnf("Request %v to %s expired after %v without a "+
+			"response", jReq, c.config.Host, c.config.RequestTTL)
+		delete(c.requestMap, jReq.id)
+		c.requestList.Remove(e)
+		jReq.responseChan <- &Response{
+			result: nil,
+			err:    c.connErr(ErrRequestTimeout),
+		}
+	}
+}
+
+// requestSweeper periodically expires the requests which have been awaiting a
+// response for longer than the configured RequestTTL.  It must be run as a
+// goroutine.
+func (c *Client) requestSweeper() {
+	defer c.wg.Done()
+
+	clock := c.config.clock()
+	for {
+		select {
+		case now := <-clock.After(c.config.RequestTTL / 2):
+			c.expireRequests(now)
+
+		case <-c.shutdown:
+			log.Tracef("RPC client request sweeper done for %s",
+				c.config.Host)
+			return
+		}
+	}
+}
+
 // trackRegisteredNtfns examines the passed command to see if it is one of
 // the notification commands and updates the notification state that is used
 // to automatically re-establish registered notifications on reconnects.
@@ -307,13 +650,19 @@ type FutureGetBulkResult chan *Response
 // Receive waits for the response promised by the future and returns an map
 // of results by request id
 func (r FutureGetBulkResult) Receive() (BulkResult, error) {
-	m := make(BulkResult)
 	res, err := ReceiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
+	return decodeBulkResult(res)
+}
+
+// decodeBulkResult decodes the passed raw batch response into a map of results
+// by request id.
+func decodeBulkResult(res []byte) (BulkResult, error) {
+	m := make(BulkResult)
 	var arr []IndividualBulkResult
-	err = json.Unmarshal(res, &arr)
+	err := json.Unmarshal(res, &arr)
 	if err != nil {
 		return nil, err
 	}
@@ -333,6 +682,23 @@ type IndividualBulkResult struct {
 	Id     uint64            `json:"id"`
 }
 
+// UnmarshalJSON unmarshals the result, accepting ids which have been echoed
+// back as strings.
+func (r *IndividualBulkResult) UnmarshalJSON(data []byte) error {
+	// The alias type does not have the UnmarshalJSON method, which avoids
+	// infinite recursion, and its Id field is shadowed by the outer one.
+	type bulkResult IndividualBulkResult
+	aux := struct {
+		*bulkResult
+		Id responseID `json:"id"`
+	}{bulkResult: (*bulkResult)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Id = uint64(aux.Id)
+	return nil
+}
+
 type BulkResult = map[uint64]IndividualBulkResult
 
 // inMessage is the first type that an incoming message is unmarshaled
@@ -341,11 +707,48 @@ type BulkResult = map[uint64]IndividualBulkResult
 // the embedded ID (from the response) is nil.  Otherwise, it is a
 // response.
 type inMessage struct {
-	ID *float64 `json:"id"`
+	ID *responseID `json:"id"`
 	*rawNotification
 	*rawResponse
 }
 
+// responseID is the id of a JSON-RPC response.  The client only issues
+// requests with unsigned integer ids, however, some gateways echo the ids back
+// as strings, so both forms are accepted.
+type responseID uint64
+
+// UnmarshalJSON unmarshals an id which is either a non-negative integer or a
+// string containing one.
+func (id *responseID) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		n, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid identifier %q", str)
+		}
+		*id = responseID(n)
+		return nil
+	}
+
+	// Parse integers directly to avoid losing the precision of large ids.
+	if n, err := strconv.ParseUint(string(data), 10, 64); err == nil {
+		*id = responseID(n)
+		return nil
+	}
+
+	// Ensure that the id can be converted to an integer without loss of
+	// precision.
+	var num float64
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	if num < 0 || num != math.Trunc(num) {
+		return fmt.Errorf("invalid identifier %v", num)
+	}
+	*id = responseID(num)
+	return nil
+}
+
 // rawNotification is a partially-unmarshaled JSON-RPC notification.
 type rawNotification struct {
 	Method string            `json:"method"`
@@ -384,9 +787,11 @@ func (c *Client) handleMessage(msg []byte) {
 	var in inMessage
 	in.rawResponse = new(rawResponse)
 	in.rawNotification = new(rawNotification)
-	err := json.Unmarshal(msg, &in)
+	err := c.unmarshalJSON(msg, &in)
 	if err != nil {
 		log.Warnf("Remote server sent invalid message: %v", err)
+		c.recordError(ErrorSourceTransport, err)
+		c.notifyMalformedMessage(msg, "invalid JSON: "+err.Error())
 		return
 	}
 
@@ -396,31 +801,42 @@ func (c *Client) handleMessage(msg []byte) {
 		if ntfn == nil {
 			log.Warn("Malformed notification: missing " +
 				"method and parameters")
+			c.notifyMalformedMessage(msg, "notification missing "+
+				"method and parameters")
+			return
+		}
+
+		// Some JSON-RPC 2.0 servers push errors which are not associated
+		// with any request as an error object without a method.
+		rpcErr := in.rawResponse.Error
+		if ntfn.Method == "" && rpcErr != nil {
+			c.handleNotificationError(rpcErr)
 			return
 		}
+
 		if ntfn.Method == "" {
 			log.Warn("Malformed notification: missing method")
+			c.notifyMalformedMessage(msg, "notification missing "+
+				"method")
 			return
 		}
 		// params are not optional: nil isn't valid (but len == 0 is)
 		if ntfn.Params == nil {
 			log.Warn("Malformed notification: missing params")
+			c.notifyMalformedMessage(msg, "notification missing "+
+				"params")
 			return
 		}
-		// Deliver the notification.
 		log.Tracef("Received notification [%s]", in.Method)
-		c.handleNotification(in.rawNotification)
-		return
-	}
-
-	// ensure that in.ID can be converted to an integer without loss of precision
-	if *in.ID < 0 || *in.ID != math.Trunc(*in.ID) {
-		log.Warn("Malformed response: invalid identifier")
+		c.recordNotification(in.rawNotification)
+		c.deliverNotification(in.rawNotification)
 		return
 	}
 
 	if in.rawResponse == nil {
 		log.Warn("Malformed response: missing result and error")
+		c.notifyMalformedMessage(msg, "response missing result and "+
+			"error")
 		return
 	}
 
@@ -428,21 +844,117 @@ func (c *Client) handleMessage(msg []byte) {
 	log.Tracef("Received response for id %d (result %s)", id, in.Result)
 	request := c.removeRequest(id)
 
+	// A reply to a request which was already answered is a protocol
+	// violation by the server.
+	if request == nil && c.answeredIDs.contains(id) {
+		c.handleDuplicateResponse(id, msg)
+		return
+	}
+
 	// Nothing more to do if there is no request associated with this reply.
 	if request == nil || request.responseChan == nil {
 		log.Warnf("Received unexpected reply: %s (id %d)", in.Result,
 			id)
+		c.notifyMalformedMessage(msg, fmt.Sprintf("response with "+
+			"unknown id %d", id))
 		return
 	}
 
+	c.answeredIDs.add(id)
+
 	// Since the command was successful, examine it to see if it's a
 	// notification, and if is, add it to the notification state so it
 	// can automatically be re-established on reconnect.
 	c.trackRegisteredNtfns(request.cmd)
 
-	// Deliver the response.
+	// Deliver the response, notifying the caller if it was a rejected
+	// transaction.
 	result, err := in.rawResponse.result()
+	if err != nil {
+		c.notifyTxRejected(request, err)
+	} else if err = c.validateResponse(request.method, result); err != nil {
+		result = nil
+	}
 	request.responseChan <- &Response{result: result, err: err}
+
+	// Force a reconnect when the error indicates the backend is stuck in a
+	// state that only a fresh connection will recover from.
+	rpcErr := in.rawResponse.Error
+	if rpcErr != nil && c.config.ReconnectOnRPCError != nil &&
+		c.config.ReconnectOnRPCError(rpcErr) {
+
+		log.Warnf("Disconnecting from %s due to RPC error: %v",
+			c.config.Host, rpcErr)
+		c.recordError(ErrorSourceRPC, rpcErr)
+		c.disconnectWithError(rpcErr)
+	}
+}
+
+// answeredIDs is a bounded set of the most recently answered request ids.  A
+// nil set contains no ids and ignores additions.
+type answeredIDs struct {
+	ids  map[uint64]struct{}
+	ring []uint64
+	next int
+}
+
+// newAnsweredIDs returns an empty set which remembers up to size ids.
+func newAnsweredIDs(size int) *answeredIDs {
+	return &answeredIDs{
+		ids:  make(map[uint64]struct{}, size),
+		ring: make([]uint64, 0, size),
+	}
+}
+
+// add adds the passed id to the set, evicting the oldest id when it is full.
+func (a *answeredIDs) add(id uint64) {
+	if a == nil {
+		return
+	}
+	if len(a.ring) < cap(a.ring) {
+		a.ring = append(a.ring, id)
+	} else {
+		delete(a.ids, a.ring[a.next])
+		a.ring[a.next] = id
+		a.next = (a.next + 1) % len(a.ring)
+	}
+	a.ids[id] = struct{}{}
+}
+
+// contains returns whether the passed id is in the set.
+func (a *answeredIDs) contains(id uint64) bool {
+	if a == nil {
+		return false
+	}
+	_, ok := a.ids[id]
+	return ok
+}
+
+// handleDuplicateResponse handles a response from the server to a request which
+// was already answered by invoking the OnDuplicateResponse callback, if any,
+// and disconnecting when DisconnectOnDuplicateResponse is set.
+func (c *Client) handleDuplicateResponse(id uint64, msg []byte) {
+	log.Warnf("Received duplicate reply for id %d", id)
+	if c.config.OnDuplicateResponse != nil {
+		c.config.OnDuplicateResponse(id, msg)
+	}
+	if !c.config.DisconnectOnDuplicateResponse {
+		return
+	}
+
+	err := fmt.Errorf("duplicate reply for id %d", id)
+	log.Warnf("Disconnecting from %s due to protocol error: %v",
+		c.config.Host, err)
+	c.recordError(ErrorSourceTransport, err)
+	c.disconnectWithError(err)
+}
+
+// notifyMalformedMessage invokes the OnMalformedMessage callback, if any, with
+// the passed message received from the server and the reason it was dropped.
+func (c *Client) notifyMalformedMessage(msg []byte, reason string) {
+	if c.config.OnMalformedMessage != nil {
+		c.config.OnMalformedMessage(msg, reason)
+	}
 }
 
 // shouldLogReadError returns whether or not the passed error, which is expected
@@ -467,9 +979,75 @@ func (c *Client) shouldLogReadError(err error) bool {
 	return true
 }
 
+// closeErrPrefix is the prefix of the errors returned by the websocket package
+// when the server closes the connection with a close message.
+const closeErrPrefix = "websocket: close "
+
+// parseCloseError returns the close code and text from the error which ended
+// reading from a websocket connection.  The websocket package does not export
+// its close error type, so the code is parsed from the error message.  It
+// reports both normal closure and going away as io.EOF, so both are returned
+// as normal closure, and errors other than a close message from the server are
+// returned as an abnormal closure.
+func parseCloseError(err error) (int, string) {
+	if err == io.EOF {
+		return websocket.CloseNormalClosure, ""
+	}
+
+	msg := err.Error()
+	if strings.HasPrefix(msg, closeErrPrefix) {
+		parts := strings.SplitN(
+			strings.TrimPrefix(msg, closeErrPrefix), " ", 2,
+		)
+		if code, err := strconv.Atoi(parts[0]); err == nil {
+			var text string
+			if len(parts) == 2 {
+				text = parts[1]
+			}
+			return code, text
+		}
+	}
+
+	return websocket.CloseAbnormalClosure, msg
+}
+
+// handleClose records the close code and text of the websocket connection from
+// the error which ended reading from it and passes them to the
+// OnClientDisconnected notification handler, if any.
+func (c *Client) handleClose(err error) {
+	code, text := parseCloseError(err)
+
+	c.mtx.Lock()
+	c.lastCloseCode = code
+	c.lastCloseText = text
+	if !c.disconnected {
+		c.disconnectErr = err
+	}
+	c.mtx.Unlock()
+
+	if c.ntfnHandlers != nil && c.ntfnHandlers.OnClientDisconnected != nil {
+		c.ntfnHandlers.OnClientDisconnected(code, text)
+	}
+}
+
+// LastCloseCode returns the websocket close code and text describing why the
+// connection to the server was last closed, such as 1013 (try again later)
+// when the server asked the client to back off.  A code of 1006 (abnormal
+// closure) is returned when the connection was lost without a close message,
+// and a code of zero when the connection has never been closed.  Going away
+// (1001) is reported as normal closure (1000), since the websocket package does
+// not distinguish them.
+func (c *Client) LastCloseCode() (int, string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.lastCloseCode, c.lastCloseText
+}
+
 // wsInHandler handles all incoming messages for the websocket connection
 // associated with the client.  It must be run as a goroutine.
 func (c *Client) wsInHandler() {
+	var readErr error
 out:
 	for {
 		// Break out of the loop once the shutdown channel has been
@@ -481,24 +1059,101 @@ out:
 		default:
 		}
 
+		if c.config.ReadTimeout > 0 {
+			deadline := time.Now().Add(c.config.ReadTimeout)
+			if err := c.wsConn.SetReadDeadline(deadline); err != nil {
+				log.Errorf("Unable to set read deadline for "+
+					"%s: %v", c.config.Host, err)
+				c.recordError(ErrorSourceTransport, err)
+				readErr = err
+				break out
+			}
+		}
+
 		_, msg, err := c.wsConn.ReadMessage()
 		if err != nil {
+			readErr = err
+
+			// A timeout means the connection stalled, so disconnect
+			// to trigger a reconnect.
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Warnf("No data received from %s for %v, "+
+					"disconnecting", c.config.Host,
+					c.config.ReadTimeout)
+				c.recordError(ErrorSourceTransport, err)
+				break out
+			}
+
 			// Log the error if it's not due to disconnecting.
 			if c.shouldLogReadError(err) {
 				log.Errorf("Websocket receive error from "+
 					"%s: %v", c.config.Host, err)
+				c.recordError(ErrorSourceTransport, err)
 			}
 			break out
 		}
+
+		// Don't handle messages which arrived while the client was
+		// shutting down.
+		select {
+		case <-c.shutdown:
+			break out
+		default:
+		}
 		c.handleMessage(msg)
 	}
 
+	// Report why the connection was closed unless shutting down.
+	if readErr != nil {
+		select {
+		case <-c.shutdown:
+		default:
+			c.handleClose(readErr)
+		}
+	}
+
 	// Ensure the connection is closed.
 	c.Disconnect()
 	c.wg.Done()
 	log.Tracef("RPC client input handler done for %s", c.config.Host)
 }
 
+// ntfnHandler dispatches the notifications queued by handleMessage to the
+// notification handlers one at a time, in the order they were received, so
+// slow handlers do not delay the delivery of responses.  It must be run as a
+// goroutine.
+func (c *Client) ntfnHandler() {
+out:
+	for {
+		select {
+		case ntfn := <-c.ntfnQueue:
+			c.dispatchNotification(ntfn)
+
+		case <-c.shutdown:
+			break out
+		}
+	}
+
+	// Dispatch any notifications which are still queued when requested,
+	// otherwise they are dropped.
+	if c.config.DrainNotificationsOnShutdown {
+	drain:
+		for {
+			select {
+			case ntfn := <-c.ntfnQueue:
+				c.dispatchNotification(ntfn)
+			default:
+				break drain
+			}
+		}
+	}
+
+	c.wg.Done()
+	log.Tracef("RPC client notification handler done for %s",
+		c.config.Host)
+}
+
 // disconnectChan returns a copy of the current disconnect channel.  The channel
 // is read protected by the client mutex, and is safe to call while the channel
 // is being reassigned during a reconnect.
@@ -521,7 +1176,7 @@ out:
 		case msg := <-c.sendChan:
 			err := c.wsConn.WriteMessage(websocket.TextMessage, msg)
 			if err != nil {
-				c.Disconnect()
+				c.disconnectWithError(err)
 				break out
 			}
 
@@ -544,6 +1199,47 @@ cleanup:
 	log.Tracef("RPC client output handler done for %s", c.config.Host)
 }
 
+// keepAliveRPCHandler periodically issues the configured keepalive RPC on the
+// websocket connection so gateways which only consider application traffic
+// do not close it as idle.  It must be run as a goroutine and exits once the
+// connection is lost.
+func (c *Client) keepAliveRPCHandler() {
+	defer c.wg.Done()
+
+	method := c.config.KeepAliveRPCMethod
+	if method == "" {
+		method = defaultKeepAliveRPCMethod
+	}
+	disconnect := c.disconnectChan()
+	ticker := time.NewTicker(c.config.KeepAliveRPCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-disconnect:
+			return
+		}
+
+		select {
+		case resp := <-c.RawRequestAsync(method, nil):
+			if resp.err != nil {
+				log.Debugf("Keepalive %s request to %s failed: %v",
+					method, c.config.Host, resp.err)
+				source := ErrorSourceTransport
+				var rpcErr *btcjson.RPCError
+				if errors.As(resp.err, &rpcErr) {
+					source = ErrorSourceRPC
+				}
+				c.recordError(source, resp.err)
+			}
+
+		case <-disconnect:
+			return
+		}
+	}
+}
+
 // sendMessage sends the passed JSON to the connected server using the
 // websocket connection.  It is backed by a buffered channel, so it will not
 // block until the send channel is full.
@@ -627,7 +1323,9 @@ func (c *Client) reregisterNtfns() error {
 }
 
 // ignoreResends is a set of all methods for requests that are "long running"
-// are not be reissued by the client on reconnect.
+// are not be reissued by the client on reconnect.  Rescans which have reported
+// progress are the exception, and are resumed after the last rescanned block
+// instead.
 var ignoreResends = map[string]struct{}{
 	"rescan": {},
 }
@@ -640,7 +1338,36 @@ func (c *Client) resendRequests() {
 	// disconnect the client.
 	if err := c.reregisterNtfns(); err != nil {
 		log.Warnf("Unable to re-establish notification state: %v", err)
-		c.Disconnect()
+		c.recordError(ErrorSourceReconnect, err)
+		c.disconnectWithError(err)
+		return
+	}
+
+	// Re-establish the application state next, disconnecting to try again
+	// with a fresh connection on failure.
+	if c.config.OnReconnectBootstrap != nil {
+		if err := c.config.OnReconnectBootstrap(c); err != nil {
+			log.Warnf("Unable to bootstrap reconnected client: %v",
+				err)
+			c.recordError(ErrorSourceReconnect, err)
+			c.disconnectWithError(err)
+			return
+		}
+	}
+
+	// Fail the pending requests instead of resending them when requested,
+	// so the caller can decide whether to reissue them.
+	if c.config.DisableResendOnReconnect {
+		c.requestLock.Lock()
+		for e := c.requestList.Front(); e != nil; e = e.Next() {
+			req := e.Value.(*jsonRequest)
+			req.responseChan <- &Response{
+				result: nil,
+				err:    c.connErr(ErrClientDisconnect),
+			}
+		}
+		c.removeAllRequests()
+		c.requestLock.Unlock()
 		return
 	}
 
@@ -655,13 +1382,25 @@ func (c *Client) resendRequests() {
 		nextElem = e.Next()
 
 		jReq := e.Value.(*jsonRequest)
-		if _, ok := ignoreResends[jReq.method]; ok {
+		switch _, ok := ignoreResends[jReq.method]; {
+		case ok && !c.rescanResumable(jReq):
 			// If a request is not sent on reconnect, remove it
 			// from the request structures, since no reply is
 			// expected.
 			delete(c.requestMap, jReq.id)
 			c.requestList.Remove(e)
-		} else {
+
+		// Fail requests which have been sent as many times as allowed
+		// by their call options.
+		case jReq.tries > 0 && jReq.resends+1 >= jReq.tries:
+			delete(c.requestMap, jReq.id)
+			c.requestList.Remove(e)
+			jReq.responseChan <- &Response{
+				err: c.connErr(ErrClientDisconnect),
+			}
+
+		default:
+			jReq.resends++
 			resendReqs = append(resendReqs, jReq)
 		}
 	}
@@ -674,12 +1413,26 @@ func (c *Client) resendRequests() {
 			return
 		}
 
-		log.Tracef("Sending command [%s] with id %d", jReq.method,
-			jReq.id)
-		c.sendMessage(jReq.marshalledJSON)
-	}
-}
-
+		// Rescans are resumed where they were interrupted, which
+		// requires looking up the block to resume at, so it is done
+		// without holding the request lock.
+		if _, ok := ignoreResends[jReq.method]; ok {
+			if err := c.resumeRescanRequest(jReq); err != nil {
+				log.Warnf("Unable to resume rescan: %v", err)
+				if c.removeRequest(jReq.id) != nil {
+					jReq.responseChan <- &Response{
+						err: c.connErr(err),
+					}
+				}
+				continue
+			}
+		}
+
+		log.Tracef("Sending command %v", jReq)
+		c.sendMessage(jReq.marshalledJSON)
+	}
+}
+
 // wsReconnectHandler listens for client disconnects and automatically tries
 // to reconnect with retry interval that scales based on the number of retries.
 // It also resends any commands that had not completed when the client
@@ -708,23 +1461,56 @@ out:
 			default:
 			}
 
+			// Wait for the turn of the client when reconnects
+			// are limited across clients.
+			limiter := c.config.ReconnectLimiter
+			if limiter != nil && !limiter.acquire(c.shutdown) {
+				break out
+			}
+
+			attempt := c.retryCount + 1
+			c.events.emit(ConnectionEvent{
+				Type:    EventReconnecting,
+				Attempt: attempt,
+			})
+			if c.config.OnReconnectAttempt != nil {
+				c.config.OnReconnectAttempt(attempt)
+			}
+
 			wsConn, err := dial(c.config)
+			if limiter != nil {
+				limiter.release()
+			}
 			if err != nil {
 				c.retryCount++
 				log.Infof("Failed to connect to %s: %v",
 					c.config.Host, err)
+				c.recordError(ErrorSourceReconnect, err)
+				if c.config.OnReconnectFailed != nil {
+					c.config.OnReconnectFailed(attempt, err)
+				}
 
 				// Scale the retry interval by the number of
-				// retries so there is a backoff up to a max
-				// of 1 minute.
-				scaledInterval := connectionRetryInterval.Nanoseconds() * c.retryCount
-				scaledDuration := time.Duration(scaledInterval)
-				if scaledDuration > time.Minute {
-					scaledDuration = time.Minute
+				// retries using the configured backoff, which
+				// defaults to a linear backoff up to a max of
+				// 1 minute.
+				backoffFunc := c.config.ReconnectBackoff
+				if backoffFunc == nil {
+					backoffFunc = DefaultReconnectBackoff
+				}
+				scaledDuration := backoffFunc(c.retryCount)
+
+				// Wait longer when needed for the attempt to
+				// be covered by the retry budget.
+				if c.retryBudget != nil {
+					wait := c.retryBudget.reserve()
+					if wait > scaledDuration {
+						scaledDuration = wait
+					}
 				}
 				log.Infof("Retrying connection to %s in "+
 					"%s", c.config.Host, scaledDuration)
-				time.Sleep(scaledDuration)
+				c.config.clock().Sleep(scaledDuration)
 				continue reconnect
 			}
 
@@ -737,19 +1523,33 @@ out:
 			c.backendVersion = nil
 			c.backendVersionMu.Unlock()
 
+			c.supportedMethodsMu.Lock()
+			c.supportedMethods = nil
+			c.supportedMethodsMu.Unlock()
+
 			// Reset the connection state and signal the reconnect
 			// has happened.
 			c.mtx.Lock()
 			c.wsConn = wsConn
 			c.retryCount = 0
+			c.connectedAt = c.config.clock().Now()
 
 			c.disconnect = make(chan struct{})
 			c.disconnected = false
+			c.disconnectErr = nil
 			c.mtx.Unlock()
 
 			// Start processing input and output for the
 			// new connection.
 			c.start()
+			c.events.emit(ConnectionEvent{Type: EventReconnected})
+
+			if c.config.OnReconnectSucceeded != nil {
+				c.config.OnReconnectSucceeded()
+			}
+			if c.metrics != nil {
+				c.metrics.reconnected()
+			}
 
 			// Reissue pending requests in another goroutine since
 			// the send can block.
@@ -764,6 +1564,21 @@ out:
 	log.Tracef("RPC client reconnect handler done for %s", c.config.Host)
 }
 
+// DefaultReconnectBackoff is the backoff used between automatic reconnect
+// attempts when the ReconnectBackoff connection option is not set, and between
+// initial connection attempts when InitialConnectBackoff is not set.  It scales
+// the retry interval linearly by the number of failed attempts, up to a
+// maximum of one minute.  It is exported so custom backoff functions may build
+// upon it.
+func DefaultReconnectBackoff(attempt int64) time.Duration {
+	scaledInterval := connectionRetryInterval.Nanoseconds() * attempt
+	scaledDuration := time.Duration(scaledInterval)
+	if scaledDuration > time.Minute {
+		scaledDuration = time.Minute
+	}
+	return scaledDuration
+}
+
 // handleSendPostMessage handles performing the passed HTTP request, reading the
 // result, unmarshalling it, and delivering the unmarshalled result to the
 // provided response channel.
@@ -777,43 +1592,48 @@ func (c *Client) handleSendPostMessage(jReq *jsonRequest) {
 	httpURL, err := c.config.httpURL()
 	if err != nil {
 		jReq.responseChan <- &Response{
-			err: fmt.Errorf("failed to parse address %v", err),
+			err: c.connErr(fmt.Errorf("failed to parse address "+
+				"%v", err)),
 		}
 		return
 	}
 
+	ctx := jReq.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	tries := 10
+	if c.config.RetryableMethods != nil &&
+		(jReq.batch || !c.config.RetryableMethods[jReq.method]) {
+
+		tries = 1
+	}
+	if jReq.tries > 0 {
+		tries = jReq.tries
+	}
 	for i := 0; i < tries; i++ {
 		var httpReq *http.Request
-
-		bodyReader := bytes.NewReader(jReq.marshalledJSON)
-		httpReq, err = http.NewRequest("POST", httpURL, bodyReader)
+		httpReq, err = c.newPostRequest(
+			ctx, httpURL, jReq.marshalledJSON,
+		)
 		if err != nil {
-			jReq.responseChan <- &Response{result: nil, err: err}
+			jReq.responseChan <- &Response{err: c.connErr(err)}
 			return
 		}
-		httpReq.Close = true
-		httpReq.Header.Set("Content-Type", "application/json")
-		for key, value := range c.config.ExtraHeaders {
-			httpReq.Header.Set(key, value)
-		}
-
-		// Configure basic access authorization.
-		// Check if username and password are provided directly
-		if c.config.User != "" && c.config.Pass != "" {
-			user, pass, err := c.config.getAuth()
-			if err != nil {
-				jReq.responseChan <- &Response{result: nil, err: err}
-				return
-			}
-			// Only set basic auth if username and password are not empty
-			if user != "" && pass != "" {
-				httpReq.SetBasicAuth(user, pass)
-			}
+		for key, values := range jReq.headers {
+			httpReq.Header[key] = values
 		}
 
 		httpResponse, err = c.httpClient.Do(httpReq)
 
+		// There is no point retrying once the caller has given up on
+		// the request.
+		if err != nil && ctx.Err() != nil {
+			jReq.responseChan <- &Response{err: c.connErr(ctx.Err())}
+			return
+		}
+
 		// Quit the retry loop on success or if we can't retry anymore.
 		if err == nil || i == tries-1 {
 			break
@@ -825,79 +1645,277 @@ func (c *Client) handleSendPostMessage(jReq *jsonRequest) {
 		// message that we pass back to the caller.
 		lastErr = err
 
+		// Fail fast once the retry budget shared by all requests has
+		// been exhausted.
+		if c.retryBudget != nil && !c.retryBudget.take() {
+			c.recordPostFailure()
+			jReq.responseChan <- &Response{
+				err: c.connErr(fmt.Errorf("%w: %v",
+					ErrRetryBudgetExceeded, err)),
+			}
+			return
+		}
+
 		// Backoff sleep otherwise.
 		backoff = requestRetryInterval * time.Duration(i+1)
 		if backoff > time.Minute {
 			backoff = time.Minute
 		}
-		log.Debugf("Failed command [%s] with id %d attempt %d."+
-			" Retrying in %v... \n", jReq.method, jReq.id,
-			i, backoff)
+		log.Debugf("Failed command %v attempt %d."+
+			" Retrying in %v... \n", jReq, i, backoff)
+		c.recordError(ErrorSourceTransport, err)
 
 		select {
-		case <-time.After(backoff):
+		case <-c.config.clock().After(backoff):
+
+		case <-ctx.Done():
+			jReq.responseChan <- &Response{err: c.connErr(ctx.Err())}
+			return
 
 		case <-c.shutdown:
 			return
 		}
 	}
 	if err != nil {
-		jReq.responseChan <- &Response{err: err}
+		c.recordPostFailure()
+		jReq.responseChan <- &Response{err: c.connErr(err)}
 		return
 	}
 
 	// We still want to return an error if for any reason the response
 	// remains empty.
 	if httpResponse == nil {
+		c.recordPostFailure()
 		jReq.responseChan <- &Response{
-			err: fmt.Errorf("invalid http POST response (nil), "+
-				"method: %s, id: %d, last error=%v",
-				jReq.method, jReq.id, lastErr),
+			err: c.connErr(fmt.Errorf("invalid http POST response "+
+				"(nil), method: %s, id: %d, last error=%v",
+				jReq.method, jReq.id, lastErr)),
 		}
 		return
 	}
 
+	c.notifyHTTPResponse(jReq.method, httpResponse)
+
 	// Read the raw bytes and close the response.
-	respBytes, err := ioutil.ReadAll(httpResponse.Body)
-	httpResponse.Body.Close()
+	respBytes, err := readResponseBody(httpResponse)
 	if err != nil {
+		c.recordPostFailure()
 		err = fmt.Errorf("error reading json reply: %v", err)
-		jReq.responseChan <- &Response{err: err}
+		jReq.responseChan <- &Response{err: c.connErr(err)}
 		return
 	}
 
 	// Try to unmarshal the response as a regular JSON-RPC response.
 	var resp rawResponse
 	var batchResponse json.RawMessage
-	if c.batch {
-		err = json.Unmarshal(respBytes, &batchResponse)
+	if jReq.batch {
+		err = c.unmarshalJSON(respBytes, &batchResponse)
 	} else {
-		err = json.Unmarshal(respBytes, &resp)
+		err = c.unmarshalJSON(respBytes, &resp)
 	}
 	if err != nil {
 		// When the response itself isn't a valid JSON-RPC response
 		// return an error which includes the HTTP status code and raw
 		// response bytes.
+		c.recordPostFailure()
 		err = fmt.Errorf("status code: %d, response: %q",
 			httpResponse.StatusCode, string(respBytes))
-		jReq.responseChan <- &Response{err: err}
+		jReq.responseChan <- &Response{err: c.connErr(err)}
 		return
 	}
+	c.recordPostSuccess()
+
 	var res []byte
-	if c.batch {
+	if jReq.batch {
 		// errors must be dealt with downstream since a whole request cannot
 		// "error out" other than through the status code error handled above
 		res, err = batchResponse, nil
 	} else {
 		res, err = resp.result()
+		if err != nil {
+			c.notifyTxRejected(jReq, err)
+		} else if err = c.validateResponse(jReq.method, res); err != nil {
+			res = nil
+		}
 	}
 	jReq.responseChan <- &Response{result: res, err: err}
 }
 
+// connErr prefixes the passed error, which describes a failure of the client
+// rather than an error returned by the server, with the connection name from
+// the configuration, if any.  The error is wrapped, so it can still be matched
+// with errors.Is.
+func (c *Client) connErr(err error) error {
+	if c.config.ConnName == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", c.config.ConnName, err)
+}
+
+// validateResponse checks the raw result of a successful response to the
+// passed method with the ValidateResponse hook, if any.
+func (c *Client) validateResponse(method string, result []byte) error {
+	if c.config.ValidateResponse == nil {
+		return nil
+	}
+	if err := c.config.ValidateResponse(method, result); err != nil {
+		return fmt.Errorf("invalid %s response: %w", method, err)
+	}
+	return nil
+}
+
+// newPostRequest returns an HTTP POST request to the passed URL with the
+// passed marshalled JSON-RPC request as its body, and the headers and
+// authorization from the connection configuration.
+func (c *Client) newPostRequest(ctx context.Context, httpURL string,
+	marshalledJSON []byte) (*http.Request, error) {
+
+	bodyReader := bytes.NewReader(marshalledJSON)
+	httpReq, err := http.NewRequestWithContext(
+		ctx, "POST", httpURL, bodyReader,
+	)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Close = true
+	contentType := c.config.PostContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if c.config.RequestCompression {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+	for key, value := range c.config.ExtraHeaders {
+		httpReq.Header.Set(key, value)
+	}
+
+	// Use the custom authorization scheme when one is configured.
+	if c.config.AuthHeaderFunc != nil {
+		auth, err := c.config.AuthHeaderFunc()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get authorization "+
+				"header: %v", err)
+		}
+		httpReq.Header.Set("Authorization", auth)
+		return httpReq, nil
+	}
+
+	// Configure basic access authorization.
+	// Check if username and password are provided directly
+	if c.config.User != "" && c.config.Pass != "" {
+		user, pass, err := c.config.getAuth()
+		if err != nil {
+			return nil, err
+		}
+		// Only set basic auth if username and password are not empty
+		if user != "" && pass != "" {
+			httpReq.SetBasicAuth(user, pass)
+		}
+	}
+
+	return httpReq, nil
+}
+
+// responseBody returns a reader for the body of the passed HTTP response,
+// decompressing it when it is gzip-encoded.  The HTTP client only decompresses
+// responses transparently when it added the Accept-Encoding header itself, so
+// this is needed when the header is set explicitly.  The caller is responsible
+// for closing the returned reader, which also closes the response body.
+func responseBody(httpResponse *http.Response) (io.ReadCloser, error) {
+	encoding := httpResponse.Header.Get("Content-Encoding")
+	if !strings.EqualFold(encoding, "gzip") {
+		return httpResponse.Body, nil
+	}
+
+	gzipReader, err := gzip.NewReader(httpResponse.Body)
+	if err != nil {
+		httpResponse.Body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gzipReader, httpResponse.Body}, nil
+}
+
+// readResponseBody reads and closes the body of the passed HTTP response,
+// decompressing it when it is gzip-encoded.
+func readResponseBody(httpResponse *http.Response) ([]byte, error) {
+	body, err := responseBody(httpResponse)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// notifyHTTPResponse invokes the OnHTTPResponse callback, if any, with the
+// status code and a copy of the headers of the passed response.
+func (c *Client) notifyHTTPResponse(method string, resp *http.Response) {
+	if c.config.OnHTTPResponse == nil {
+		return
+	}
+	c.config.OnHTTPResponse(method, resp.StatusCode, resp.Header.Clone())
+}
+
+// recordPostFailure notes a failed HTTP POST request with the circuit breaker
+// when it is enabled, and marks the client disconnected once the configured
+// number of consecutive failures is reached.
+func (c *Client) recordPostFailure() {
+	if c.breaker != nil {
+		c.breaker.recordFailure()
+	}
+
+	threshold := c.config.PostFailureThreshold
+	if threshold == 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.postFailures++
+	if c.postFailures >= threshold && !c.disconnected {
+		log.Warnf("Marking RPC server %s disconnected after %d "+
+			"consecutive failed requests", c.config.Host,
+			c.postFailures)
+		c.disconnected = true
+	}
+}
+
+// recordPostSuccess notes a successful HTTP POST request with the circuit
+// breaker when it is enabled, and marks the client connected again if it was
+// marked disconnected due to failed requests.
+func (c *Client) recordPostSuccess() {
+	if c.breaker != nil {
+		c.breaker.recordSuccess()
+	}
+
+	if c.config.PostFailureThreshold == 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.postFailures = 0
+	if c.disconnected {
+		log.Infof("RPC server %s is responding again", c.config.Host)
+		c.disconnected = false
+		c.connectedAt = c.config.clock().Now()
+	}
+}
+
 // sendPostHandler handles all outgoing messages when the client is running
 // in HTTP POST mode.  It uses a buffered channel to serialize output messages
 // while allowing the sender to continue running asynchronously.  It must be run
 // as a goroutine.
+//
+// Requests are handled one at a time in the order they were queued, which
+// provides the ordering guarantee documented on the HTTPPostMode connection
+// option, so this must not be changed to dispatch requests concurrently.
 func (c *Client) sendPostHandler() {
 out:
 	for {
@@ -920,7 +1938,7 @@ cleanup:
 		case jReq := <-c.sendPostChan:
 			jReq.responseChan <- &Response{
 				result: nil,
-				err:    ErrClientShutdown,
+				err:    c.connErr(ErrClientShutdown),
 			}
 
 		default:
@@ -935,16 +1953,27 @@ cleanup:
 // HTTP client associated with the client.  It is backed by a buffered channel,
 // so it will not block until the send channel is full.
 func (c *Client) sendPostRequest(jReq *jsonRequest) {
+	// Fail the request immediately while the circuit breaker is open.
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			jReq.responseChan <- &Response{err: c.connErr(err)}
+			return
+		}
+	}
+
 	// Don't send the message if shutting down.
 	select {
 	case <-c.shutdown:
-		jReq.responseChan <- &Response{result: nil, err: ErrClientShutdown}
+		jReq.responseChan <- &Response{
+			result: nil,
+			err:    c.connErr(ErrClientShutdown),
+		}
 	default:
 	}
 
 	select {
 	case c.sendPostChan <- jReq:
-		log.Tracef("Sent command [%s] with id %d", jReq.method, jReq.id)
+		log.Tracef("Sent command %v", jReq)
 
 	case <-c.shutdown:
 		return
@@ -979,6 +2008,11 @@ func ReceiveFuture(f chan *Response) ([]byte, error) {
 // provided response channel for the reply.  It handles both websocket and HTTP
 // POST mode depending on the configuration of the client.
 func (c *Client) sendRequest(jReq *jsonRequest) {
+	if err := c.checkReadOnly(jReq.method); err != nil {
+		jReq.responseChan <- &Response{err: err}
+		return
+	}
+
 	// Choose which marshal and send function to use depending on whether
 	// the client running in HTTP POST mode or not.  When running in HTTP
 	// POST mode, the command is issued via an HTTP client.  Otherwise,
@@ -999,7 +2033,9 @@ func (c *Client) sendRequest(jReq *jsonRequest) {
 	select {
 	case <-c.connEstablished:
 	default:
-		jReq.responseChan <- &Response{err: ErrClientNotConnected}
+		jReq.responseChan <- &Response{
+			err: c.connErr(ErrClientNotConnected),
+		}
 		return
 	}
 
@@ -1011,7 +2047,7 @@ func (c *Client) sendRequest(jReq *jsonRequest) {
 		jReq.responseChan <- &Response{err: err}
 		return
 	}
-	log.Tracef("Sending command [%s] with id %d", jReq.method, jReq.id)
+	log.Tracef("Sending command %v", jReq)
 	c.sendMessage(jReq.marshalledJSON)
 }
 
@@ -1020,6 +2056,28 @@ func (c *Client) sendRequest(jReq *jsonRequest) {
 // future.  It handles both websocket and HTTP POST mode depending on the
 // configuration of the client.
 func (c *Client) SendCmd(cmd interface{}) chan *Response {
+	jReq, err := c.newCmdRequest(cmd)
+	if err != nil {
+		return newFutureError(err)
+	}
+
+	var responseChan chan *Response
+	if c.config.DeduplicateRequests && !c.batch {
+		responseChan = c.sendRequestDeduplicated(jReq)
+	} else {
+		c.sendRequest(jReq)
+		responseChan = jReq.responseChan
+	}
+	if c.metrics != nil {
+		responseChan = c.metrics.observe(responseChan)
+	}
+
+	return responseChan
+}
+
+// newCmdRequest marshals the passed command and returns a request for it
+// along with a channel to respond on.
+func (c *Client) newCmdRequest(cmd interface{}) (*jsonRequest, error) {
 	rpcVersion := btcjson.RpcVersion1
 	if c.batch {
 		rpcVersion = btcjson.RpcVersion2
@@ -1028,29 +2086,144 @@ func (c *Client) SendCmd(cmd interface{}) chan *Response {
 	method, err := btcjson.CmdMethod(cmd)
 
 	if err != nil {
-		return newFutureError(err)
+		return nil, err
 	}
 
 	// Marshal the command.
 	id := c.NextID()
-	marshalledJSON, err := btcjson.MarshalCmd(rpcVersion, id, cmd)
+	marshalledJSON, err := c.marshalCmdRequest(rpcVersion, id, method, cmd)
 	if err != nil {
-		return newFutureError(err)
+		return nil, err
 	}
 
-	// Generate the request and send it along with a channel to respond on.
-	responseChan := make(chan *Response, 1)
-	jReq := &jsonRequest{
+	return &jsonRequest{
 		id:             id,
 		method:         method,
 		cmd:            cmd,
 		marshalledJSON: marshalledJSON,
-		responseChan:   responseChan,
+		responseChan:   make(chan *Response, 1),
+	}, nil
+}
+
+// marshalCmdRequest marshals the passed command into a request with the passed
+// id, checks it with the ValidateRequest function from the connection
+// configuration, and decorates it with its RequestDecorator function.
+func (c *Client) marshalCmdRequest(rpcVersion btcjson.RPCVersion, id uint64,
+	method string, cmd interface{}) ([]byte, error) {
+
+	marshalledJSON, err := btcjson.MarshalCmdWith(
+		rpcVersion, id, cmd, c.marshalJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.ValidateRequest != nil {
+		err := c.config.ValidateRequest(method, marshalledJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s request: %w", method,
+				err)
+		}
+	}
+	return c.decorateRequest(method, id, marshalledJSON)
+}
+
+// decorateRequest returns the passed marshalled request as rewritten by the
+// RequestDecorator function from the connection configuration, or unchanged
+// when it is not set.
+func (c *Client) decorateRequest(method string, id uint64,
+	marshalled []byte) ([]byte, error) {
+
+	if c.config.RequestDecorator == nil {
+		return marshalled, nil
+	}
+	decorated, err := c.config.RequestDecorator(method, id, marshalled)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decorate %s request: %w",
+			method, err)
 	}
+	return decorated, nil
+}
 
+// CallContext sends the passed command to the associated server, waits for the
+// reply, and unmarshals its result into result, which should be a pointer to a
+// value of the type expected for the command.  The result is discarded when
+// result is nil.  It will return the error field in the reply if there is one.
+//
+// The call is abandoned and the context error returned once the passed context
+// is done.  In HTTP POST mode this also aborts the in-flight HTTP request and
+// any remaining retries, while in websocket mode the request is no longer
+// tracked, so a reply which arrives later is ignored.  This is the recommended
+// way to issue synchronous calls in new code.
+//
+// NOTE: CallContext should not be used with a batch client, since the reply
+// to a queued command is not available until the batch is sent.
+func (c *Client) CallContext(ctx context.Context, cmd interface{},
+	result interface{}) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	jReq, err := c.newCmdRequest(cmd)
+	if err != nil {
+		return err
+	}
+	jReq.ctx = ctx
+	jReq.requestID, _ = RequestIDFromContext(ctx)
+
+	var sent time.Time
+	if c.metrics != nil {
+		sent = c.metrics.begin()
+	}
 	c.sendRequest(jReq)
 
-	return responseChan
+	select {
+	case resp := <-jReq.responseChan:
+		if c.metrics != nil {
+			c.metrics.end(sent, resp.err)
+		}
+		if resp.err != nil {
+			return resp.err
+		}
+		if result == nil {
+			return nil
+		}
+		return c.unmarshalJSON(resp.result, result)
+
+	case <-ctx.Done():
+		// Stop tracking the request so a late reply is ignored.
+		c.removeRequest(jReq.id)
+		if c.metrics != nil {
+			c.metrics.end(sent, ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+// marshalJSON encodes the passed value using the Codec or JSONMarshal function
+// from the connection configuration, or the standard encoding/json package when
+// neither is set.
+func (c *Client) marshalJSON(v interface{}) ([]byte, error) {
+	if c.config.Codec != nil {
+		return c.config.Codec.Marshal(v)
+	}
+	if c.config.JSONMarshal != nil {
+		return c.config.JSONMarshal(v)
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalJSON decodes the passed data into v using the Codec or JSONUnmarshal
+// function from the connection configuration, or the standard encoding/json
+// package when neither is set.
+func (c *Client) unmarshalJSON(data []byte, v interface{}) error {
+	if c.config.Codec != nil {
+		return c.config.Codec.Unmarshal(data, v)
+	}
+	if c.config.JSONUnmarshal != nil {
+		return c.config.JSONUnmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
 }
 
 // sendCmdAndWait sends the passed command to the associated server, waits
@@ -1064,6 +2237,10 @@ func (c *Client) sendCmdAndWait(cmd interface{}) (interface{}, error) {
 
 // Disconnected returns whether or not the server is disconnected.  If a
 // websocket client was created but never connected, this also returns false.
+//
+// In HTTP POST mode there is no persistent connection, so the server is only
+// considered disconnected after PostFailureThreshold consecutive requests have
+// failed, until a request succeeds again.
 func (c *Client) Disconnected() bool {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
@@ -1076,6 +2253,58 @@ func (c *Client) Disconnected() bool {
 	}
 }
 
+// IsConnected returns whether or not the client is currently connected to the
+// server.  Unlike Disconnected, this returns false if a websocket client was
+// created but never connected.
+func (c *Client) IsConnected() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	select {
+	case <-c.connEstablished:
+		return !c.disconnected
+	default:
+		return false
+	}
+}
+
+// ConnectedSince returns the time the current connection to the RPC server was
+// established and true, or false if the client has never connected or is
+// currently disconnected.  The time is reset each time the client reconnects.
+// In HTTP POST mode, this is the time the client was created or last recovered
+// after being marked disconnected due to failed requests.
+func (c *Client) ConnectedSince() (time.Time, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	select {
+	case <-c.connEstablished:
+	default:
+		return time.Time{}, false
+	}
+	if c.disconnected {
+		return time.Time{}, false
+	}
+	return c.connectedAt, true
+}
+
+// Subprotocol returns the websocket subprotocol negotiated with the server for
+// the current connection, or an empty string if the server did not select one
+// of the requested Subprotocols.
+func (c *Client) Subprotocol() (string, error) {
+	if c.config.HTTPPostMode {
+		return "", ErrNotWebsocketClient
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.wsConn == nil {
+		return "", ErrClientNotConnected
+	}
+	return c.wsConn.Subprotocol(), nil
+}
+
 // doDisconnect disconnects the websocket associated with the client if it
 // hasn't already been disconnected.  It will return false if the disconnect is
 // not needed or the client is running in HTTP POST mode.
@@ -1100,6 +2329,10 @@ func (c *Client) doDisconnect() bool {
 		c.wsConn.Close()
 	}
 	c.disconnected = true
+	c.events.emit(ConnectionEvent{
+		Type: EventDisconnected,
+		Err:  c.disconnectErr,
+	})
 	return true
 }
 
@@ -1142,12 +2375,61 @@ func (c *Client) Disconnect() {
 			req := e.Value.(*jsonRequest)
 			req.responseChan <- &Response{
 				result: nil,
-				err:    ErrClientDisconnect,
+				err:    c.connErr(ErrClientDisconnect),
 			}
 		}
 		c.removeAllRequests()
 		c.doShutdown()
+		c.events.shutdown()
+	}
+}
+
+// disconnectWithError disconnects the current websocket in the same manner as
+// Disconnect, reporting the passed error as the cause of the disconnect in the
+// disconnected event.
+func (c *Client) disconnectWithError(err error) {
+	c.mtx.Lock()
+	if !c.disconnected {
+		c.disconnectErr = err
+	}
+	c.mtx.Unlock()
+
+	c.Disconnect()
+}
+
+// CancelAllRequests abandons all outstanding requests, delivering
+// ErrRequestCanceled to each of their futures.  Unlike Disconnect and
+// Shutdown, the connection is left open and may continue to be used for new
+// requests.  Any replies which arrive later for the canceled requests are
+// ignored.
+//
+// NOTE: Requests issued in HTTP POST mode are not tracked by the client once
+// they have been handed to the HTTP client, so only queued batch requests are
+// canceled in that mode.
+func (c *Client) CancelAllRequests() {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	for e := c.requestList.Front(); e != nil; e = e.Next() {
+		req := e.Value.(*jsonRequest)
+		req.responseChan <- &Response{
+			result: nil,
+			err:    c.connErr(ErrRequestCanceled),
+		}
+	}
+
+	c.batchLock.Lock()
+	for e := c.batchList.Front(); e != nil; e = e.Next() {
+		req := e.Value.(*jsonRequest)
+		req.responseChan <- &Response{
+			result: nil,
+			err:    c.connErr(ErrRequestCanceled),
+		}
 	}
+	c.batchList.Init()
+	c.batchLock.Unlock()
+
+	c.removeAllRequests()
 }
 
 // Shutdown shuts down the client by disconnecting any connections associated
@@ -1170,13 +2452,14 @@ func (c *Client) Shutdown() {
 		req := e.Value.(*jsonRequest)
 		req.responseChan <- &Response{
 			result: nil,
-			err:    ErrClientShutdown,
+			err:    c.connErr(ErrClientShutdown),
 		}
 	}
 	c.removeAllRequests()
 
 	// Disconnect the client if needed.
 	c.doDisconnect()
+	c.events.shutdown()
 }
 
 // start begins processing input and output messages.
@@ -1200,18 +2483,58 @@ func (c *Client) start() {
 		}()
 		go c.wsInHandler()
 		go c.wsOutHandler()
+
+		if c.config.KeepAliveRPCInterval > 0 {
+			c.wg.Add(1)
+			go c.keepAliveRPCHandler()
+		}
+
+		c.pingMtx.Lock()
+		c.startPingHandler()
+		c.pingMtx.Unlock()
 	}
 }
 
 // WaitForShutdown blocks until the client goroutines are stopped and the
-// connection is closed.
+// connection is closed.  This includes waiting for the notification handler
+// which is currently running, if any, to return.
 func (c *Client) WaitForShutdown() {
 	c.wg.Wait()
 }
 
+// Codec encodes outgoing requests and decodes incoming responses and
+// notifications for the connection, which allows using a more compact encoding
+// than JSON against a compatible gateway.
+//
+// Each param of a request is encoded with the codec before being embedded in
+// the request as a json.RawMessage, so the codec must embed such values as is.
+// The results of responses, on the other hand, are decoded by the futures with
+// encoding/json, so a codec for a different encoding must transcode a result
+// to JSON when decoding it into a json.RawMessage.
+type Codec interface {
+	// Marshal encodes the passed value.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes the passed data into the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
 // ConnConfig describes the connection configuration parameters for the client.
 // This
 type ConnConfig struct {
+	// ConnName is an optional name for the connection which is prepended to
+	// the errors the client returns for failed requests, such as
+	// ErrClientDisconnect, to tell apart the errors of multiple clients.
+	// The errors are wrapped, so they can still be matched with errors.Is.
+	// Errors returned by the server are not modified.
+	ConnName string
+
+	// ReadOnly specifies that requests for the state-changing methods in
+	// MutatingMethods, such as sendrawtransaction or importprivkey, should
+	// be rejected with ErrReadOnlyClient without being sent to the server.
+	// This is a guardrail for services which should only query the node.
+	ReadOnly bool
+
 	// Host is the IP address and port of the RPC server you want to connect
 	// to.
 	Host string
@@ -1220,6 +2543,15 @@ type ConnConfig struct {
 	// typically "ws".
 	Endpoint string
 
+	// Endpoints is an optional list of candidate websocket endpoints which
+	// are tried in order each time the connection is dialed, using the
+	// first for which the websocket handshake succeeds.  This allows
+	// connecting to servers which have moved the endpoint between
+	// versions.  A candidate is skipped when the server rejects the
+	// handshake, but an authentication or network error fails the dial
+	// immediately.  It may not be specified with Endpoint.
+	Endpoints []string
+
 	// User is the username to use to authenticate to the RPC server.
 	User string
 
@@ -1231,17 +2563,64 @@ type ConnConfig struct {
 	// instead of User and Pass if non-empty.
 	CookiePath string
 
+	// CookieEnvVar is the name of an environment variable containing the
+	// contents of a cookie, in the form username:passphrase, to use to
+	// authenticate to the RPC server.  This avoids mounting a cookie file
+	// into minimal containers.  It is used instead of User, Pass, and
+	// CookiePath if non-empty.
+	CookieEnvVar string
+
+	// CookieCacheDuration is the amount of time the credentials read from
+	// the cookie file at CookiePath are reused before the file is checked
+	// for changes again, which should be shorter than the interval at which
+	// the node rotates its cookie.  It defaults to 30 seconds when zero,
+	// and a negative value checks the file for every request.
+	CookieCacheDuration time.Duration
+
+	// Clock is an optional source of the current time and timers used for
+	// the cookie cache and the backoffs between HTTP POST retries and
+	// reconnect attempts.  It allows tests to control the passage of time
+	// deterministically.  The real clock is used when nil.
+	Clock Clock
+
 	cookieLastCheckTime time.Time
 	cookieLastModTime   time.Time
 	cookieLastUser      string
 	cookieLastPass      string
 	cookieLastErr       error
 
+	// conn is an established connection provided to NewWithConn which is
+	// used in place of dialing the next websocket connection.
+	conn net.Conn
+
 	// Params is the string representing the network that the server
 	// is running. If there is no parameter set in the config, then
 	// mainnet will be used by default.
 	Params string
 
+	// ChainParams are the parameters of the network that the server is
+	// running.  When set, they are used directly and Params is ignored,
+	// which allows using the client with custom networks whose names are
+	// not known to this package.
+	ChainParams *chaincfg.Params
+
+	// DetectChainParams specifies whether the network the server is
+	// running is detected with getblockchaininfo as soon as the client
+	// connects, replacing the configured parameters with those of the
+	// detected network.  This avoids addresses being encoded for another
+	// network than the one of the server.  The configured parameters are
+	// kept when detection fails or the network is not known to this
+	// package.
+	DetectChainParams bool
+
+	// VerifyNetwork specifies whether the network the server is running is
+	// checked with getblockchaininfo as soon as the client connects, so
+	// that connecting to the wrong network, such as a mainnet client to a
+	// testnet node, fails with ErrWrongNetwork before any request is made.
+	// The server is checked against the configured parameters, before
+	// they are replaced when DetectChainParams is also set.
+	VerifyNetwork bool
+
 	// DisableTLS specifies whether transport layer security should be
 	// disabled.  It is recommended to always use TLS if the RPC server
 	// supports it as otherwise your username and password is sent across
@@ -1253,6 +2632,29 @@ type ConnConfig struct {
 	// is true.
 	Certificates []byte
 
+	// AppendSystemCertPool specifies whether the Certificates should be
+	// trusted in addition to the system root certificates rather than in
+	// place of them.  This allows connecting through a gateway with a
+	// publicly trusted certificate while still trusting a private CA.  It
+	// has no effect if the DisableTLS parameter is true.
+	AppendSystemCertPool bool
+
+	// MinTLSVersion is the minimum TLS version, such as tls.VersionTLS11,
+	// to accept for the TLS connection.  It defaults to TLS 1.2 when zero
+	// and should only be lowered when connecting to legacy servers which
+	// do not support TLS 1.2.  It has no effect if the DisableTLS
+	// parameter is true.
+	MinTLSVersion uint16
+
+	// PinnedCertFingerprints are the SHA-256 fingerprints of the
+	// certificates the server may present.  When set, the TLS handshake is
+	// rejected unless the fingerprint of the leaf certificate presented by
+	// the server matches one of them, which protects against a compromised
+	// certificate authority.  The certificate must still be trusted, so a
+	// self-signed certificate must also be passed in Certificates.  It has
+	// no effect if the DisableTLS parameter is true.
+	PinnedCertFingerprints [][32]byte
+
 	// Proxy specifies to connect through a SOCKS 5 proxy server.  It may
 	// be an empty string if a proxy is not required.
 	Proxy string
@@ -1267,6 +2669,13 @@ type ConnConfig struct {
 	// is not set.
 	ProxyPass string
 
+	// Resolver is an optional resolver used to look up the host of the
+	// RPC server in both websocket and HTTP POST modes in place of the
+	// system default, which is useful with split-horizon DNS or service
+	// discovery.  It has no effect when connecting through a proxy, since
+	// the proxy resolves the host.
+	Resolver *net.Resolver
+
 	// DisableAutoReconnect specifies the client should not automatically
 	// try to reconnect to the server when it has been disconnected.
 	DisableAutoReconnect bool
@@ -1283,15 +2692,584 @@ type ConnConfig struct {
 	// features of the client such notifications only work with websockets,
 	// however, not all servers support the websocket extensions, so this
 	// flag can be set to true to use basic HTTP POST requests instead.
+	//
+	// Requests are sent one at a time in the order they were issued, each
+	// only once the response to the previous one has been received, so
+	// the server observes and answers them in that order.  Callers relying
+	// on this, for example to submit dependent transactions with the async
+	// functions, should not assume the same of websocket connections.
 	HTTPPostMode bool
 
+	// FallbackToHTTPPost specifies that the client switches to HTTP POST
+	// mode rather than failing when the server does not accept the
+	// websocket connection made by New because the endpoint does not
+	// support websockets (ErrInvalidEndpoint).  The notification handlers
+	// are discarded on fallback, since notifications require websockets,
+	// and the proxy, if any, must then be given as a URL.  The passed
+	// configuration is not modified.  It has no effect when
+	// DisableConnectOnNew is set.
+	FallbackToHTTPPost bool
+
 	// ExtraHeaders specifies the extra headers when perform request. It's
 	// useful when RPC provider need customized headers.
 	ExtraHeaders map[string]string
 
+	// AuthHeaderFunc is an optional function which returns the full value
+	// of the Authorization header, such as a bearer token, to use in place
+	// of basic authorization.  It is called for each HTTP POST request and
+	// each websocket connection attempt, so rotating credentials are picked
+	// up without recreating the client.  It may not be specified together
+	// with a username, password, or cookie.
+	AuthHeaderFunc func() (string, error)
+
+	// PostFailureThreshold is the number of consecutive failed HTTP POST
+	// requests after which the client is reported as disconnected by
+	// Disconnected and IsConnected, until a request succeeds again.  This
+	// gives meaningful connectivity state in HTTP POST mode, where there
+	// is no persistent connection.  The client is always reported as
+	// connected in HTTP POST mode when this is zero.
+	PostFailureThreshold uint32
+
+	// RequestCompression specifies that HTTP POST requests should
+	// explicitly advertise support for gzip-compressed responses via the
+	// Accept-Encoding header, so gateways which support it send compressed
+	// responses, reducing bandwidth for large verbose responses.
+	// Compressed responses are decompressed by the client before being
+	// decoded.
+	RequestCompression bool
+
+	// PostContentType is the Content-Type header sent with HTTP POST
+	// requests, such as "application/json-rpc" or
+	// "application/json; charset=utf-8", for gateways which require a
+	// specific value.  It defaults to "application/json" when empty.
+	PostContentType string
+
+	// HandshakeTimeout bounds the amount of time a single websocket dial
+	// attempt, including the TLS and HTTP upgrade handshakes, may take
+	// before it is abandoned and the usual retry backoff applies.  It
+	// defaults to 30 seconds when zero, and a negative value disables the
+	// timeout.  It has no effect in HTTP POST mode.
+	HandshakeTimeout time.Duration
+
+	// ReadTimeout is the maximum amount of time to wait for a message from
+	// the server on the websocket connection before treating it as stalled
+	// and disconnecting, which triggers a reconnect unless automatic
+	// reconnect is disabled.  Since the server may legitimately send
+	// nothing for long periods, it should be set well above the expected
+	// interval between notifications.  It is disabled when zero and has no
+	// effect in HTTP POST mode.
+	ReadTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum amount of time to wait for the
+	// server to begin its response, that is to send the response headers,
+	// after an HTTP POST request has been written.  This allows a stalled
+	// backend to be detected quickly while RequestTimeout still permits a
+	// slow transfer of a large response body.  It is disabled when zero and
+	// is only used in HTTP POST mode.
+	ResponseHeaderTimeout time.Duration
+
+	// RequestTimeout bounds the total amount of time an HTTP POST request
+	// may take, including reading the entire response body.  It defaults to
+	// 10 minutes when zero, and a negative value disables the timeout.  It
+	// is only used in HTTP POST mode.
+	RequestTimeout time.Duration
+
+	// RequestTTL is the maximum amount of time a request may await its
+	// response on the websocket connection, including across reconnects.
+	// Requests are checked periodically, every half of the TTL, and
+	// those which have expired fail with ErrRequestTimeout and are no
+	// longer tracked, which keeps a server which never answers some
+	// requests from growing the pending requests without bound.  It
+	// should exceed the duration of the longest expected request, such
+	// as a rescan.  It is disabled when zero and is not used in HTTP POST
+	// mode, including by batch clients, where RequestTimeout applies
+	// instead.
+	RequestTTL time.Duration
+
+	// DisableResendOnReconnect specifies that requests which are still
+	// pending when the websocket connection is lost should fail with
+	// ErrClientDisconnect once the connection is re-established, rather
+	// than being sent again.  This avoids executing non-idempotent or
+	// time-sensitive commands twice.  Notification registrations are
+	// still re-established.  It has no effect in HTTP POST mode.
+	DisableResendOnReconnect bool
+
+	// MaxOutgoingFrameSize is the maximum payload size, in bytes, of the
+	// websocket frames used to send messages to the server.  Larger
+	// messages, such as a submitblock command for a big block, are split
+	// into multiple frames so they do not exceed the frame size limits of
+	// the server.  The frames are bounded by sizing the write buffer of the
+	// websocket connection accordingly, since the buffer is flushed as a
+	// frame each time it fills.  It defaults to 4096 bytes when zero and
+	// has no effect in HTTP POST mode.
+	MaxOutgoingFrameSize int
+
+	// Subprotocols specifies the websocket subprotocols, in order of
+	// preference, to request from the server via the
+	// Sec-WebSocket-Protocol header during the websocket handshake.  The
+	// subprotocol selected by the server can be retrieved with the
+	// Subprotocol method once connected.  It has no effect in HTTP POST
+	// mode.
+	Subprotocols []string
+
+	// ReconnectBackoff is an optional function which returns the amount of
+	// time to wait before the next automatic reconnect attempt given the
+	// number of consecutive failed attempts so far, starting at 1.  This
+	// allows arbitrary backoff curves such as decorrelated jitter.  When
+	// nil, DefaultReconnectBackoff is used.
+	ReconnectBackoff func(attempt int64) time.Duration
+
+	// InitialConnectBackoff is an optional function which returns the
+	// amount of time to wait before the next attempt to establish the
+	// initial connection with Connect given the number of consecutive
+	// failed attempts so far, starting at 1.  This allows a different
+	// policy for startup than ReconnectBackoff does for reconnects, such
+	// as failing fast.  When nil, DefaultReconnectBackoff is used.
+	InitialConnectBackoff func(attempt int64) time.Duration
+
+	// OnReconnectAttempt is an optional callback which is invoked before
+	// each automatic reconnect attempt with the number of the attempt,
+	// starting at 1 after each disconnect.
+	OnReconnectAttempt func(attempt int64)
+
+	// OnReconnectFailed is an optional callback which is invoked with the
+	// number of the attempt and the resulting error each time an automatic
+	// reconnect attempt fails.
+	OnReconnectFailed func(attempt int64, err error)
+
+	// OnReconnectSucceeded is an optional callback which is invoked once
+	// the connection has been re-established by an automatic reconnect.
+	//
+	// Together with OnReconnectAttempt and OnReconnectFailed, it allows
+	// distinguishing a client which is failing to reconnect from one which
+	// has recovered.  The callbacks are run from the reconnect goroutine,
+	// so they should not block.
+	OnReconnectSucceeded func()
+
+	// OnReconnectBootstrap is an optional function which is invoked with
+	// the client each time the connection has been re-established by an
+	// automatic reconnect, after the notification registrations have been
+	// re-established and before the pending requests are resent.  It
+	// allows re-running application setup, such as importing descriptors
+	// to watch again.  It is run from its own goroutine, so it may issue
+	// blocking calls on the client.  When it returns an error, the client
+	// disconnects so that the reconnect is tried again.  It has no effect
+	// in HTTP POST mode.
+	OnReconnectBootstrap func(c *Client) error
+
+	// ReconnectLimiter is an optional limiter, created with
+	// NewReconnectLimiter, which may be shared with other clients to limit
+	// the number of them attempting to reconnect at the same time.  This
+	// keeps many clients of a restarting backend from overwhelming it.
+	ReconnectLimiter *ReconnectLimiter
+
+	// TCPKeepAlive specifies the interval between TCP keepalive probes
+	// on the underlying connection for both websocket and HTTP POST modes.
+	// This helps detect half-open connections to peers which have gone
+	// away without closing the connection.  When zero, the operating
+	// system and Go defaults are used.  A negative value disables TCP
+	// keepalive.
+	TCPKeepAlive time.Duration
+
+	// KeepAliveRPCInterval is the interval at which a lightweight RPC is
+	// issued on the websocket connection to keep it active at the
+	// application layer, for proxies and gateways which close idle
+	// connections without regard for TCP keepalive probes.  It is disabled
+	// when zero and has no effect in HTTP POST mode.
+	KeepAliveRPCInterval time.Duration
+
+	// KeepAliveRPCMethod is the method of the RPC issued every
+	// KeepAliveRPCInterval.  It must not require any parameters, and
+	// defaults to getblockcount when empty.
+	KeepAliveRPCMethod string
+
+	// PingInterval is the interval at which websocket ping frames are sent
+	// to keep the connection alive, which can be changed at runtime with
+	// SetPingInterval.  A failed ping is treated as a lost connection.
+	// Pings are disabled when zero and have no effect in HTTP POST mode.
+	PingInterval time.Duration
+
+	// RetryBudget is the maximum number of retries, shared by all HTTP
+	// POST requests and automatic reconnect attempts, which may be made
+	// per RetryBudgetWindow.  This bounds the aggregate retry load, for
+	// example for cost control against metered providers.  Once
+	// exhausted, failed HTTP POST requests fail fast with
+	// ErrRetryBudgetExceeded and reconnect attempts are delayed until the
+	// budget refills.  There is no budget when this is zero.
+	RetryBudget uint32
+
+	// RetryBudgetWindow is the window over which the retry budget
+	// continuously refills.  It defaults to one minute when zero.
+	RetryBudgetWindow time.Duration
+
+	// CircuitBreakerThreshold is the number of consecutive failed HTTP POST
+	// requests after which the client stops sending requests to the
+	// backend and fails them immediately with ErrCircuitOpen.  The circuit
+	// breaker is disabled when this is zero.
+	CircuitBreakerThreshold uint32
+
+	// CircuitBreakerCooldown is the amount of time the circuit breaker
+	// stays open before allowing a single probe request through to test
+	// whether the backend has recovered.  It defaults to 30 seconds when
+	// zero.
+	CircuitBreakerCooldown time.Duration
+
+	// ReconnectOnRPCError is an optional function which is called with
+	// each error returned by the server in response to a request.  When it
+	// returns true, the client disconnects so that a fresh connection is
+	// established, which allows recovering from known sticky backend error
+	// states automatically.  The connection is not re-established when
+	// DisableAutoReconnect is set.  It has no effect in HTTP POST mode.
+	ReconnectOnRPCError func(*btcjson.RPCError) bool
+
+	// RequireBackend is the type of backend the client must be connected
+	// to.  When set, the backend version is detected as soon as the client
+	// connects, and New or Connect shut down the client and return an
+	// error wrapping ErrWrongBackend when the backend is of a different
+	// type.  This catches misconfigurations at startup rather than on the
+	// first call to a backend-specific method.  Any backend is accepted
+	// when it is BackendAny, the default.
+	RequireBackend BackendType
+
+	// ValidateRequest is an optional function which is called with the
+	// method and marshalled JSON of each command before it is sent.  When
+	// it returns an error, the command is not sent and the error is
+	// returned to the caller instead.
+	ValidateRequest func(method string, marshalled []byte) error
+
+	// RequestDecorator is an optional function which is called with the
+	// method, id, and marshalled JSON of each request just before it is
+	// sent, and returns the JSON to send in its place.  This allows adding
+	// fields required by gateways, such as a nonce or timestamp to prevent
+	// replays.  The id of the request must be kept, since it is used to
+	// match the reply.  When it returns an error, the request is not sent
+	// and the error is returned to the caller instead.  Requests resent
+	// after a reconnect are not decorated again, except for resumed
+	// rescans, which are marshalled anew.
+	RequestDecorator func(method string, id uint64,
+		raw []byte) ([]byte, error)
+
+	// RetryableMethods is an optional set of the methods which are safe to
+	// retry when a request fails due to a network error.  When it is set,
+	// requests for any other method are only attempted once, which avoids
+	// repeating side effects such as submitting a block twice.  Batch
+	// requests, which may contain any method, are never retried when it is
+	// set.  All requests are retried when it is nil.  It is only used in
+	// HTTP POST mode.
+	RetryableMethods map[string]bool
+
+	// DeduplicateRequests specifies that a request made through SendCmd,
+	// which includes the async functions, while an identical request is
+	// still awaiting its response is not sent, and instead the caller
+	// receives the response to the request already in flight.  This avoids
+	// several goroutines issuing the same query, such as getblock for the
+	// same hash, each hitting the backend.  Since it only makes sense for
+	// reads, requests of the methods in MutatingMethods are always sent.
+	DeduplicateRequests bool
+
+	// ValidateResponse is an optional function which is called with the
+	// method and raw JSON result of each successful response before it is
+	// delivered to the caller.  When it returns an error, the caller
+	// receives that error instead of the result.  SendBatch returns the
+	// error, without any of the results, when the result of any of its
+	// commands is rejected.
+	ValidateResponse func(method string, result []byte) error
+
+	// AsyncNotifications specifies that notifications should be queued and
+	// dispatched to the notification handlers from a dedicated goroutine
+	// rather than from the goroutine reading the websocket connection.
+	// This prevents a slow handler from delaying the delivery of responses
+	// to outstanding requests.
+	//
+	// Notifications are still dispatched one at a time in the order they
+	// were received, however, they are no longer ordered with respect to
+	// responses.  For example, the response to a request may be delivered
+	// before a notification which was received prior to it has been
+	// handled.  Once the queue is full, reading from the connection blocks
+	// until the handlers catch up.
+	AsyncNotifications bool
+
+	// NotificationHistorySize is the number of the most recently received
+	// notifications to record for retrieval with RecentNotifications,
+	// which helps diagnose dropped notifications and ordering or timing
+	// issues without enabling trace logging.  No notifications are
+	// recorded when this is zero.
+	NotificationHistorySize int
+
+	// ErrorHistorySize is the number of the most recent errors which are
+	// otherwise only logged, such as websocket read errors and failed
+	// reconnect attempts, to record for retrieval with RecentErrors.  It
+	// defaults to 16 when zero, and a negative value disables the history.
+	ErrorHistorySize int
+
+	// CollectMetrics enables collecting request counts, errors, latencies,
+	// and reconnects for retrieval with Metrics, or for export to
+	// Prometheus with the separate rpcclient/promcollector module.
+	CollectMetrics bool
+
+	// BufferPausedNotifications specifies that notifications received
+	// while notification delivery is paused with PauseNotifications should
+	// be buffered and delivered once ResumeNotifications is called, rather
+	// than dropped.  The buffer is unbounded, so every notification
+	// received while paused is held in memory, which may be significant
+	// for long pauses when subscribed to busy notifications such as
+	// transaction notifications.
+	BufferPausedNotifications bool
+
+	// NotificationQueueSize is the number of notifications which may be
+	// queued when AsyncNotifications is set.  It defaults to 100 when
+	// zero.
+	NotificationQueueSize int
+
+	// DrainNotificationsOnShutdown specifies that notifications which are
+	// still queued when the client is shut down should be dispatched to
+	// the notification handlers before the notification goroutine exits,
+	// rather than dropped.  WaitForShutdown blocks until they have been
+	// dispatched.  It has no effect unless AsyncNotifications is set.
+	DrainNotificationsOnShutdown bool
+
+	// CoalesceBlockNotifications specifies that block connected
+	// notifications are delivered from a dedicated goroutine which, when
+	// several blocks are connected while the handler is still running,
+	// invokes it once with only the most recent block rather than once
+	// per block.  This keeps a slow handler from falling ever further
+	// behind during initial sync.  The number of skipped blocks is passed
+	// to OnBlockConnectedCoalesced.  Block connected notifications are no
+	// longer ordered with respect to the other notifications.
+	CoalesceBlockNotifications bool
+
+	// BlockChannelSize is the number of block events buffered by the
+	// channels returned by BlockChannel.  It defaults to 16 when zero.
+	BlockChannelSize int
+
+	// BlockChannelBlocking specifies that block events are not dropped
+	// when the buffer of a channel returned by BlockChannel is full, but
+	// instead the delivery of all notifications waits for the consumer to
+	// catch up.  By default, the events which do not fit in the buffer are
+	// dropped, so a slow consumer never stalls the client.
+	BlockChannelBlocking bool
+
+	// EventBufferSize is the number of connection events buffered by the
+	// channel returned by Events, beyond which the oldest events are
+	// dropped.  It defaults to 32 when zero, and a negative value disables
+	// the events.
+	EventBufferSize int
+
+	// OnBatchSent is an optional callback which is invoked each time a
+	// batch of requests has been sent and its response received, or the
+	// batch failed, with the number of commands in the batch, the size of
+	// the marshalled batch in bytes, the round-trip duration, and the error
+	// for the batch as a whole, if any.  This is useful for tuning batch
+	// sizes against a given backend.  It is only used in HTTP POST mode.
+	OnBatchSent func(count int, bytes int, dur time.Duration, err error)
+
+	// OnHTTPResponse is an optional callback which is invoked with the
+	// method, status code, and headers of each HTTP POST response before
+	// its body is read, which allows reading rate limit and quota
+	// information conveyed in headers by gateways.  The method is empty
+	// for batch requests.  The headers are a copy which may be retained.
+	// It is only used in HTTP POST mode.
+	OnHTTPResponse func(method string, statusCode int, headers http.Header)
+
+	// OnMalformedMessage is an optional callback which is invoked with the
+	// raw message and the reason whenever a message received from the
+	// server on the websocket connection is dropped because it is not a
+	// valid notification or response, such as invalid JSON, a notification
+	// without a method, or a response with an unknown id.  This allows
+	// alerting on a misbehaving or malicious endpoint.  The message must
+	// not be modified or retained.
+	OnMalformedMessage func(raw []byte, reason string)
+
+	// OnDuplicateResponse is an optional callback which is invoked with
+	// the id and raw message whenever the server sends a response on the
+	// websocket connection to a request which was already answered, which
+	// indicates a non-conformant server or gateway.  Only duplicates of
+	// the most recently answered requests are detected.  The duplicate is
+	// otherwise ignored.  The message must not be modified or retained.
+	OnDuplicateResponse func(id uint64, raw []byte)
+
+	// DisconnectOnDuplicateResponse specifies that a duplicate response,
+	// as described for OnDuplicateResponse, is treated as a protocol error
+	// which disconnects the client, so that a fresh connection is
+	// established unless DisableAutoReconnect is set.
+	DisconnectOnDuplicateResponse bool
+
 	// EnableBCInfoHacks is an option provided to enable compatibility hacks
 	// when connecting to blockchain.info RPC server
 	EnableBCInfoHacks bool
+
+	// JSONMarshal is an optional function used in place of json.Marshal
+	// when encoding outgoing requests.  It may be nil, in which case the
+	// standard encoding/json package is used.
+	JSONMarshal func(v interface{}) ([]byte, error)
+
+	// JSONUnmarshal is an optional function used in place of
+	// json.Unmarshal when decoding incoming responses and notifications.
+	// This allows, for example, a decoder that uses json.Number to avoid
+	// losing precision on large numbers.  It may be nil, in which case the
+	// standard encoding/json package is used.
+	JSONUnmarshal func(data []byte, v interface{}) error
+
+	// Codec is an optional codec used in place of JSON to encode outgoing
+	// requests and decode incoming responses and notifications.  It may
+	// not be specified together with JSONMarshal or JSONUnmarshal.  Over
+	// websocket connections, messages are still sent as text messages, and
+	// in HTTP POST mode the PostContentType option should be set to the
+	// content type expected by the server.
+	Codec Codec
+}
+
+// Validate checks the connection configuration for malformed or contradictory
+// settings and returns a descriptive error for the first one found.  It is
+// called by New, so misconfigurations are reported when the client is created
+// rather than when the first request is made.
+func (config *ConnConfig) Validate() error {
+	if config.Host == "" {
+		return errors.New("no host specified")
+	}
+	if err := validateHost(config.Host); err != nil {
+		return fmt.Errorf("invalid host %q: %v", config.Host, err)
+	}
+
+	if config.CookiePath != "" && (config.User != "" || config.Pass != "") {
+		return errors.New("only one of a cookie path or a username " +
+			"and password may be specified")
+	}
+	if config.CookieEnvVar != "" && (config.User != "" ||
+		config.Pass != "" || config.CookiePath != "") {
+
+		return errors.New("a cookie environment variable may not be " +
+			"specified with a cookie path or a username and password")
+	}
+
+	if config.AuthHeaderFunc != nil && (config.User != "" ||
+		config.Pass != "" || config.CookiePath != "" ||
+		config.CookieEnvVar != "") {
+
+		return errors.New("an authorization header function may not " +
+			"be specified with a cookie or a username and password")
+	}
+
+	if config.Endpoint != "" && len(config.Endpoints) > 0 {
+		return errors.New("only one of an endpoint or a list of " +
+			"endpoints may be specified")
+	}
+
+	if config.Codec != nil && (config.JSONMarshal != nil ||
+		config.JSONUnmarshal != nil) {
+
+		return errors.New("a codec may not be specified with custom " +
+			"JSON functions")
+	}
+
+	if config.Proxy != "" && config.HTTPPostMode {
+		// The HTTP client expects the proxy as a URL.
+		if _, err := url.Parse(config.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy URL %q: %v",
+				config.Proxy, err)
+		}
+	} else if config.Proxy != "" {
+		// The websocket dialer connects to a SOCKS proxy by address.
+		if _, _, err := net.SplitHostPort(config.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy address %q: %v",
+				config.Proxy, err)
+		}
+	}
+
+	return nil
+}
+
+// rootCAs returns the pool of root certificates to trust for the TLS
+// connection to the RPC server.  A nil pool, meaning the system roots are
+// used, is returned when no certificates are configured.
+func (config *ConnConfig) rootCAs() (*x509.CertPool, error) {
+	if len(config.Certificates) == 0 {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+	if config.AppendSystemCertPool {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load system root "+
+				"certificates: %v", err)
+		}
+		pool = systemPool
+	}
+	pool.AppendCertsFromPEM(config.Certificates)
+
+	return pool, nil
+}
+
+// minTLSVersion returns the minimum TLS version to accept for connections to
+// the RPC server.
+func (config *ConnConfig) minTLSVersion() uint16 {
+	if config.MinTLSVersion == 0 {
+		return tls.VersionTLS12
+	}
+	return config.MinTLSVersion
+}
+
+// verifyPinnedCert returns an error unless the leaf certificate presented by
+// the server matches one of the pinned certificate fingerprints.  It is set as
+// the VerifyConnection function of the TLS configuration when certificates are
+// pinned.
+func (config *ConnConfig) verifyPinnedCert(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	fingerprint := sha256.Sum256(state.PeerCertificates[0].Raw)
+	for _, pinned := range config.PinnedCertFingerprints {
+		if fingerprint == pinned {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate with fingerprint %x is not "+
+		"pinned", fingerprint)
+}
+
+// tlsConfig returns the TLS configuration for connections to the RPC server.
+func (config *ConnConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: config.minTLSVersion(),
+	}
+	pool, err := config.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = pool
+	if len(config.PinnedCertFingerprints) > 0 {
+		tlsConfig.VerifyConnection = config.verifyPinnedCert
+	}
+	return tlsConfig, nil
+}
+
+// normalize adjusts equivalent forms of configuration values to the form
+// expected by the client.
+func (config *ConnConfig) normalize() {
+	// The endpoint is joined to the host with a slash when dialing.
+	config.Endpoint = strings.TrimPrefix(config.Endpoint, "/")
+	if config.Endpoints != nil {
+		endpoints := make([]string, 0, len(config.Endpoints))
+		for _, endpoint := range config.Endpoints {
+			endpoints = append(endpoints,
+				strings.TrimPrefix(endpoint, "/"))
+		}
+		config.Endpoints = endpoints
+	}
+}
+
+// httpPostFallback returns a copy of the configuration set to run in HTTP POST
+// mode, leaving the configuration passed to New untouched.  The copy is
+// validated again since some options, such as the proxy, are interpreted
+// differently in HTTP POST mode.
+func (config *ConnConfig) httpPostFallback() (*ConnConfig, error) {
+	postConfig := *config
+	postConfig.HTTPPostMode = true
+	if err := postConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return &postConfig, nil
 }
 
 // getAuth returns the username and passphrase that will actually be used for
@@ -1310,11 +3288,29 @@ func (config *ConnConfig) getAuth() (username, passphrase string, err error) {
 
 // retrieveCookie returns the cookie username and passphrase.
 func (config *ConnConfig) retrieveCookie() (username, passphrase string, err error) {
-	if !config.cookieLastCheckTime.IsZero() && time.Now().Before(config.cookieLastCheckTime.Add(30*time.Second)) {
+	// The environment is cheap to read, so there is no need to cache the
+	// cookie when it is supplied through an environment variable.
+	if config.CookieEnvVar != "" {
+		cookie, ok := os.LookupEnv(config.CookieEnvVar)
+		if !ok {
+			return "", "", fmt.Errorf("cookie environment "+
+				"variable %s is not set", config.CookieEnvVar)
+		}
+		return parseCookie(strings.TrimSpace(cookie))
+	}
+
+	cacheDuration := config.CookieCacheDuration
+	if cacheDuration == 0 {
+		cacheDuration = defaultCookieCacheDuration
+	}
+	now := config.clock().Now()
+	if cacheDuration > 0 && !config.cookieLastCheckTime.IsZero() &&
+		now.Before(config.cookieLastCheckTime.Add(cacheDuration)) {
+
 		return config.cookieLastUser, config.cookieLastPass, config.cookieLastErr
 	}
 
-	config.cookieLastCheckTime = time.Now()
+	config.cookieLastCheckTime = now
 
 	st, err := os.Stat(config.CookiePath)
 	if err != nil {
@@ -1347,35 +3343,45 @@ func newHTTPClient(config *ConnConfig) (*http.Client, error) {
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
 	if !config.DisableTLS {
-		if len(config.Certificates) > 0 {
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(config.Certificates)
-			tlsConfig = &tls.Config{
-				RootCAs: pool,
-			}
+		var err error
+		tlsConfig, err = config.tlsConfig()
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	parsedDialAddr, err := ParseAddressString(config.Host)
+	parsedDialAddr, err := config.dialAddr()
 	if err != nil {
 		return nil, err
 	}
+	timeout := defaultHTTPTimeout
+	switch {
+	case config.RequestTimeout > 0:
+		timeout = config.RequestTimeout
+	case config.RequestTimeout < 0:
+		timeout = 0
+	}
 	client := http.Client{
 		Transport: &http.Transport{
-			Proxy:           proxyFunc,
-			TLSClientConfig: tlsConfig,
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30 * time.Second,
-			DialContext: func(_ context.Context, _,
+			Proxy:                 proxyFunc,
+			TLSClientConfig:       tlsConfig,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       30 * time.Second,
+			ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+			DialContext: func(ctx context.Context, _,
 				_ string) (net.Conn, error) {
 
-				return net.Dial(
-					parsedDialAddr.Network(),
+				dialer := net.Dialer{
+					KeepAlive: config.TCPKeepAlive,
+					Resolver:  config.Resolver,
+				}
+				return dialer.DialContext(
+					ctx, parsedDialAddr.Network(),
 					parsedDialAddr.String(),
 				)
 			},
 		},
-		Timeout: defaultHTTPTimeout,
+		Timeout: timeout,
 	}
 
 	return &client, nil
@@ -1388,7 +3394,7 @@ func (config *ConnConfig) httpURL() (string, error) {
 		protocol = "https"
 	}
 
-	parsedAddr, err := ParseAddressString(config.Host)
+	parsedAddr, err := config.dialAddr()
 	if err != nil {
 		return "", fmt.Errorf("error parsing host '%v': %v",
 			config.Host, err)
@@ -1414,20 +3420,42 @@ func dial(config *ConnConfig) (*websocket.Conn, error) {
 	var tlsConfig *tls.Config
 	var scheme = "ws"
 	if !config.DisableTLS {
-		tlsConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		}
-		if len(config.Certificates) > 0 {
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(config.Certificates)
-			tlsConfig.RootCAs = pool
+		var err error
+		tlsConfig, err = config.tlsConfig()
+		if err != nil {
+			return nil, err
 		}
 		scheme = "wss"
 	}
 
 	// Create a websocket dialer that will be used to make the connection.
-	// It is modified by the proxy setting below as needed.
-	dialer := websocket.Dialer{TLSClientConfig: tlsConfig}
+	// It is modified by the keepalive, resolver and proxy settings below
+	// as needed.
+	dialer := websocket.Dialer{
+		TLSClientConfig: tlsConfig,
+		Subprotocols:    config.Subprotocols,
+	}
+
+	// Outgoing messages are streamed through the write buffer, which is
+	// flushed as a separate frame each time it fills, so its size bounds
+	// the size of the frames.
+	if config.MaxOutgoingFrameSize > 0 {
+		dialer.WriteBufferSize = config.MaxOutgoingFrameSize
+	}
+	switch {
+	case config.HandshakeTimeout == 0:
+		dialer.HandshakeTimeout = defaultHandshakeTimeout
+	case config.HandshakeTimeout > 0:
+		dialer.HandshakeTimeout = config.HandshakeTimeout
+	}
+	if config.TCPKeepAlive != 0 || config.Resolver != nil {
+		netDialer := net.Dialer{
+			Timeout:   dialer.HandshakeTimeout,
+			KeepAlive: config.TCPKeepAlive,
+			Resolver:  config.Resolver,
+		}
+		dialer.NetDial = netDialer.Dial
+	}
 
 	// Setup the proxy if one is configured.
 	if config.Proxy != "" {
@@ -1437,64 +3465,204 @@ func dial(config *ConnConfig) (*websocket.Conn, error) {
 			Password: config.ProxyPass,
 		}
 		dialer.NetDial = proxy.Dial
+
+		// The proxy dials its own connection, so apply the keepalive
+		// setting to the resulting connection instead.
+		if config.TCPKeepAlive != 0 {
+			dialer.NetDial = func(network, addr string) (net.Conn, error) {
+				conn, err := proxy.Dial(network, addr)
+				if err != nil {
+					return nil, err
+				}
+				if err := setTCPKeepAlive(conn, config.TCPKeepAlive); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return conn, nil
+			}
+		}
 	}
 
-	// The RPC server requires basic authorization, so create a custom
-	// request header with the Authorization header set.
-	user, pass, err := config.getAuth()
-	if err != nil {
-		return nil, err
+	// Use the connection provided to NewWithConn instead of dialing one.
+	// It is only used once, so reconnects dial the server as usual.
+	if config.conn != nil {
+		conn := config.conn
+		config.conn = nil
+		dialer.NetDial = func(string, string) (net.Conn, error) {
+			return conn, nil
+		}
+	}
+
+	// The RPC server requires authorization, so create a custom request
+	// header with the Authorization header set, using basic authorization
+	// unless a custom scheme is configured.
+	var auth string
+	if config.AuthHeaderFunc != nil {
+		var err error
+		auth, err = config.AuthHeaderFunc()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get authorization "+
+				"header: %v", err)
+		}
+	} else {
+		user, pass, err := config.getAuth()
+		if err != nil {
+			return nil, err
+		}
+		login := user + ":" + pass
+		auth = "Basic " +
+			base64.StdEncoding.EncodeToString([]byte(login))
 	}
-	login := user + ":" + pass
-	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
 	requestHeader := make(http.Header)
 	requestHeader.Add("Authorization", auth)
 	for key, value := range config.ExtraHeaders {
 		requestHeader.Add(key, value)
 	}
 
-	// Dial the connection.
-	url := fmt.Sprintf("%s://%s/%s", scheme, config.Host, config.Endpoint)
+	// Dial the connection, trying each of the candidate endpoints in turn
+	// until the handshake succeeds with one of them.
+	endpoints := config.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{config.Endpoint}
+	}
+	var err error
+	for _, endpoint := range endpoints {
+		var wsConn *websocket.Conn
+		var rejected bool
+		wsConn, rejected, err = dialEndpoint(
+			&dialer, scheme, config.Host, endpoint, requestHeader,
+		)
+		if err == nil {
+			return wsConn, nil
+		}
+		if !rejected {
+			return nil, err
+		}
+		log.Debugf("Websocket handshake with endpoint %q of %s "+
+			"failed: %v", endpoint, config.Host, err)
+	}
+	return nil, err
+}
+
+// dialEndpoint dials the passed websocket endpoint of the host.  The returned
+// bool reports whether the error is due to the server rejecting the handshake
+// for the endpoint, in which case another endpoint may succeed.
+func dialEndpoint(dialer *websocket.Dialer, scheme, host, endpoint string,
+	requestHeader http.Header) (*websocket.Conn, bool, error) {
+
+	url := fmt.Sprintf("%s://%s/%s", scheme, host, endpoint)
 	wsConn, resp, err := dialer.Dial(url, requestHeader)
 	if err != nil {
 		if err != websocket.ErrBadHandshake || resp == nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		// Detect HTTP authentication error status codes.
 		if resp.StatusCode == http.StatusUnauthorized ||
 			resp.StatusCode == http.StatusForbidden {
-			return nil, ErrInvalidAuth
+			return nil, false, ErrInvalidAuth
 		}
 
 		// The connection was authenticated and the status response was
 		// ok, but the websocket handshake still failed, so the endpoint
 		// is invalid in some way.
 		if resp.StatusCode == http.StatusOK {
-			return nil, ErrInvalidEndpoint
+			return nil, true, ErrInvalidEndpoint
 		}
 
 		// Return the status text from the server if none of the special
 		// cases above apply.
-		return nil, errors.New(resp.Status)
+		return nil, true, errors.New(resp.Status)
+	}
+	return wsConn, false, nil
+}
+
+// setTCPKeepAlive configures TCP keepalive on the passed connection when it is
+// a TCP connection.  A negative period disables keepalive.
+func setTCPKeepAlive(conn net.Conn, period time.Duration) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if period < 0 {
+		return tcpConn.SetKeepAlive(false)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return err
 	}
-	return wsConn, nil
+	return tcpConn.SetKeepAlivePeriod(period)
 }
 
 // New creates a new RPC client based on the provided connection configuration
 // details.  The notification handlers parameter may be nil if you are not
-// interested in receiving notifications and will be ignored if the
-// configuration is set to run in HTTP POST mode.
+// interested in receiving notifications and must be nil if the configuration
+// is set to run in HTTP POST mode.  The configuration is checked with Validate
+// before any connection is attempted.
 func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("rpcclient.New: %v", err)
+	}
+	if config.HTTPPostMode && ntfnHandlers != nil {
+		// Only the notifications generated by the client itself are
+		// supported without a websocket connection.
+		postHandlers := *ntfnHandlers
+		postHandlers.OnTxRejected = nil
+		if !reflect.ValueOf(postHandlers).IsZero() {
+			return nil, errors.New("rpcclient.New: notification " +
+				"handlers are not supported in HTTP POST mode")
+		}
+	}
+	config.normalize()
+
+	if !config.DisableTLS && config.minTLSVersion() < tls.VersionTLS12 {
+		log.Warnf("Allowing TLS versions older than 1.2 for RPC "+
+			"server %s", config.Host)
+	}
+
+	// Warn about options which have no effect with the configuration so
+	// that a mistake in it does not go unnoticed.
+	if config.Proxy == "" && (config.ProxyUser != "" ||
+		config.ProxyPass != "") {
+
+		log.Warnf("Ignoring proxy credentials for RPC server %s "+
+			"without a proxy", config.Host)
+	}
+	if config.DisableTLS && len(config.Certificates) > 0 {
+		log.Warnf("Ignoring certificates for RPC server %s with TLS "+
+			"disabled", config.Host)
+	}
+
 	// Either open a websocket connection or create an HTTP client depending
-	// on the HTTP POST mode.  Also, set the notification handlers to nil
-	// when running in HTTP POST mode.
+	// on the HTTP POST mode.
 	var wsConn *websocket.Conn
 	var httpClient *http.Client
 	connEstablished := make(chan struct{})
 	var start bool
+	if !config.HTTPPostMode && !config.DisableConnectOnNew {
+		var err error
+		wsConn, err = dial(config)
+		switch {
+		case errors.Is(err, ErrInvalidEndpoint) &&
+			config.FallbackToHTTPPost:
+
+			log.Warnf("Unable to establish websocket connection to "+
+				"%s, falling back to HTTP POST mode without "+
+				"notifications: %v", config.Host, err)
+			config, err = config.httpPostFallback()
+			if err != nil {
+				return nil, fmt.Errorf("rpcclient.New: unable to "+
+					"fall back to HTTP POST mode: %v", err)
+			}
+			ntfnHandlers = nil
+
+		case err != nil:
+			return nil, err
+
+		default:
+			start = true
+		}
+	}
 	if config.HTTPPostMode {
-		ntfnHandlers = nil
 		start = true
 
 		var err error
@@ -1502,15 +3670,6 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		if !config.DisableConnectOnNew {
-			var err error
-			wsConn, err = dial(config)
-			if err != nil {
-				return nil, err
-			}
-			start = true
-		}
 	}
 
 	client := &Client{
@@ -1530,36 +3689,232 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		shutdown:        make(chan struct{}),
 	}
 
+	if config.NotificationHistorySize > 0 {
+		client.ntfnHistory = newNotificationHistory(
+			config.NotificationHistorySize,
+		)
+	}
+	switch {
+	case config.ErrorHistorySize == 0:
+		client.errHistory = newErrorHistory(defaultErrorHistorySize)
+	case config.ErrorHistorySize > 0:
+		client.errHistory = newErrorHistory(config.ErrorHistorySize)
+	}
+	switch {
+	case config.EventBufferSize == 0:
+		client.events = newEventStream(defaultEventBufferSize)
+	case config.EventBufferSize > 0:
+		client.events = newEventStream(config.EventBufferSize)
+	}
+	if config.CollectMetrics {
+		client.metrics = newClientMetrics()
+	}
+	if config.PingInterval > 0 {
+		client.pingInterval = config.PingInterval
+	}
+	if config.OnDuplicateResponse != nil ||
+		config.DisconnectOnDuplicateResponse {
+
+		client.answeredIDs = newAnsweredIDs(answeredIDWindow)
+	}
+
+	if config.RetryBudget > 0 {
+		client.retryBudget = newRetryBudget(
+			config.RetryBudget, config.RetryBudgetWindow,
+		)
+	}
+
+	if config.CircuitBreakerThreshold > 0 {
+		client.breaker = newCircuitBreaker(
+			config.CircuitBreakerThreshold,
+			config.CircuitBreakerCooldown,
+		)
+	}
+
 	// Default network is mainnet, no parameters are necessary but if mainnet
 	// is specified it will be the param
-	switch config.Params {
-	case "":
+	switch {
+	case config.ChainParams != nil:
+		client.chainParams = config.ChainParams
+	case config.Params == "":
 		fallthrough
-	case chaincfg.MainNetParams.Name:
+	case config.Params == chaincfg.MainNetParams.Name:
 		client.chainParams = &chaincfg.MainNetParams
-	case chaincfg.TestNet3Params.Name:
+	case config.Params == chaincfg.TestNet3Params.Name:
 		client.chainParams = &chaincfg.TestNet3Params
-	case chaincfg.RegressionNetParams.Name:
+	case config.Params == chaincfg.RegressionNetParams.Name:
 		client.chainParams = &chaincfg.RegressionNetParams
-	case chaincfg.SigNetParams.Name:
+	case config.Params == chaincfg.SigNetParams.Name:
 		client.chainParams = &chaincfg.SigNetParams
-	case chaincfg.SimNetParams.Name:
+	case config.Params == chaincfg.SimNetParams.Name:
 		client.chainParams = &chaincfg.SimNetParams
 	default:
 		return nil, fmt.Errorf("rpcclient.New: Unknown chain %s", config.Params)
 	}
 
+	if config.RequestTTL > 0 && !config.HTTPPostMode {
+		client.wg.Add(1)
+		go client.requestSweeper()
+	}
+
+	if config.AsyncNotifications && ntfnHandlers != nil {
+		queueSize := config.NotificationQueueSize
+		if queueSize <= 0 {
+			queueSize = defaultNtfnQueueSize
+		}
+		client.ntfnQueue = make(chan *rawNotification, queueSize)
+
+		// A single handler is used for the lifetime of the client, rather
+		// than one per connection, so the notifications are dispatched
+		// one at a time across reconnects.
+		client.wg.Add(1)
+		go client.ntfnHandler()
+	}
+
+	if config.CoalesceBlockNotifications && ntfnHandlers != nil {
+		client.coalescedBlockSignal = make(chan struct{}, 1)
+		client.wg.Add(1)
+		go client.blockConnectedHandler()
+	}
+
 	if start {
 		log.Infof("Established connection to RPC server %s",
 			config.Host)
+		client.connectedAt = client.config.clock().Now()
 		close(connEstablished)
 		client.start()
+		if !client.config.HTTPPostMode {
+			client.events.emit(ConnectionEvent{Type: EventConnected})
+		}
 		if !client.config.HTTPPostMode && !client.config.DisableAutoReconnect {
 			client.wg.Add(1)
 			go client.wsReconnectHandler()
 		}
+
+		// Fail early when connected to the wrong type of backend or
+		// network.
+		if err := client.checkBackend(); err != nil {
+			client.Shutdown()
+			client.WaitForShutdown()
+			return nil, err
+		}
+		if err := client.verifyNetwork(); err != nil {
+			client.Shutdown()
+			client.WaitForShutdown()
+			return nil, err
+		}
+		client.detectChainParams()
+	}
+
+	return client, nil
+}
+
+// chainParamsForNetwork returns the chain parameters of the passed network as
+// reported by getblockchaininfo, or nil when the network is not known to this
+// package.
+func chainParamsForNetwork(chain string) *chaincfg.Params {
+	// bitcoind reports the network with its own short names, while btcd
+	// reports the name of its chain parameters.
+	switch chain {
+	case "main", chaincfg.MainNetParams.Name:
+		return &chaincfg.MainNetParams
+	case "test", chaincfg.TestNet3Params.Name:
+		return &chaincfg.TestNet3Params
+	case chaincfg.RegressionNetParams.Name:
+		return &chaincfg.RegressionNetParams
+	case chaincfg.SigNetParams.Name:
+		return &chaincfg.SigNetParams
+	case chaincfg.SimNetParams.Name:
+		return &chaincfg.SimNetParams
+	default:
+		return nil
+	}
+}
+
+// verifyNetwork ensures the server is running the network of the configured
+// chain parameters when the VerifyNetwork connection option is set.
+func (c *Client) verifyNetwork() error {
+	if !c.config.VerifyNetwork {
+		return nil
+	}
+
+	info, err := c.GetBlockChainInfo()
+	if err != nil {
+		return fmt.Errorf("unable to verify the network of RPC server "+
+			"%s: %w", c.config.Host, err)
+	}
+
+	params := chainParamsForNetwork(info.Chain)
+	if params == nil {
+		return fmt.Errorf("%w: expected %s, RPC server %s reported "+
+			"unknown network %q", ErrWrongNetwork, c.chainParams.Name,
+			c.config.Host, info.Chain)
+	}
+	if params.Net != c.chainParams.Net {
+		return fmt.Errorf("%w: expected %s, RPC server %s is running %s",
+			ErrWrongNetwork, c.chainParams.Name, c.config.Host,
+			params.Name)
+	}
+	return nil
+}
+
+// detectChainParams replaces the chain parameters of the client with those of
+// the network reported by the server when the DetectChainParams connection
+// option is set.  The configured parameters are kept when detection fails.
+func (c *Client) detectChainParams() {
+	if !c.config.DetectChainParams {
+		return
+	}
+
+	info, err := c.GetBlockChainInfo()
+	if err != nil {
+		log.Warnf("Unable to detect the network of RPC server %s, "+
+			"using %s: %v", c.config.Host, c.chainParams.Name, err)
+		return
+	}
+
+	params := chainParamsForNetwork(info.Chain)
+	if params == nil {
+		log.Warnf("Unknown network %q reported by RPC server %s, "+
+			"using %s", info.Chain, c.config.Host, c.chainParams.Name)
+		return
+	}
+
+	if params != c.chainParams {
+		log.Infof("Detected network %s of RPC server %s", params.Name,
+			c.config.Host)
+		c.chainParams = params
+	}
+}
+
+// NewWithConn creates a new websocket RPC client in the same manner as New,
+// except that the passed connection, which must already be established, is
+// used in place of dialing the server.  This decouples establishing the
+// connection from the client, which is useful for testing and for exotic
+// transports.  The websocket handshake is performed over the connection, along
+// with the TLS handshake unless DisableTLS is set, so DisableTLS should be set
+// when the connection is already secured.  The Host and Endpoint connection
+// options are still used for the handshake request.
+//
+// The connection is only used for the initial connection, which is made by
+// the first call to Connect when DisableConnectOnNew is set, so automatic
+// reconnects dial the configured host as usual.  Set DisableAutoReconnect
+// when that is not possible.  The connection is closed if the handshake
+// fails.
+func NewWithConn(config *ConnConfig, conn net.Conn,
+	ntfnHandlers *NotificationHandlers) (*Client, error) {
+
+	if config.HTTPPostMode {
+		return nil, ErrNotWebsocketClient
 	}
 
+	config.conn = conn
+	client, err := New(config, ntfnHandlers)
+	if err != nil {
+		config.conn = nil
+		conn.Close()
+		return nil, err
+	}
 	return client, nil
 }
 
@@ -1593,6 +3948,35 @@ func NewBatch(config *ConnConfig) (*Client, error) {
 // connection has already been established, or if none of the connection
 // attempts were successful.
 func (c *Client) Connect(tries int) error {
+	return c.ConnectContext(context.Background(), tries)
+}
+
+// ConnectContext establishes the initial websocket connection in the same
+// manner as Connect, except that the connection attempts are abandoned and the
+// context error returned once the passed context is done.  The context is
+// checked before each attempt and during the backoff between attempts, while
+// each attempt itself is bounded by the HandshakeTimeout connection option.
+func (c *Client) ConnectContext(ctx context.Context, tries int) error {
+	if err := c.connect(ctx, tries); err != nil {
+		return err
+	}
+
+	// Fail early when connected to the wrong type of backend or network.
+	if err := c.checkBackend(); err != nil {
+		c.Shutdown()
+		return err
+	}
+	if err := c.verifyNetwork(); err != nil {
+		c.Shutdown()
+		return err
+	}
+	c.detectChainParams()
+	return nil
+}
+
+// connect establishes the initial websocket connection as described by
+// ConnectContext.
+func (c *Client) connect(ctx context.Context, tries int) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
@@ -1604,18 +3988,26 @@ func (c *Client) Connect(tries int) error {
 	}
 
 	// Begin connection attempts.  Increase the backoff after each failed
-	// attempt, up to a maximum of one minute.
+	// attempt using the configured backoff, which defaults to a linear
+	// backoff up to a maximum of one minute.
+	backoffFunc := c.config.InitialConnectBackoff
+	if backoffFunc == nil {
+		backoffFunc = DefaultReconnectBackoff
+	}
 	var err error
-	var backoff time.Duration
 	for i := 0; tries == 0 || i < tries; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
 		var wsConn *websocket.Conn
 		wsConn, err = dial(c.config)
 		if err != nil {
-			backoff = connectionRetryInterval * time.Duration(i+1)
-			if backoff > time.Minute {
-				backoff = time.Minute
+			select {
+			case <-c.config.clock().After(backoffFunc(int64(i + 1))):
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			time.Sleep(backoff)
 			continue
 		}
 
@@ -1625,8 +4017,10 @@ func (c *Client) Connect(tries int) error {
 		log.Infof("Established connection to RPC server %s",
 			c.config.Host)
 		c.wsConn = wsConn
+		c.connectedAt = c.config.clock().Now()
 		close(c.connEstablished)
 		c.start()
+		c.events.emit(ConnectionEvent{Type: EventConnected})
 		if !c.config.DisableAutoReconnect {
 			c.wg.Add(1)
 			go c.wsReconnectHandler()
@@ -1690,22 +4084,89 @@ func (c *Client) BackendVersion() (BackendVersion, error) {
 	return c.backendVersion, nil
 }
 
-func (c *Client) sendAsync() (FutureGetBulkResult, error) {
+// RefreshBackendVersion clears the cached version of the backend the client is
+// currently connected to and detects it again.  This is useful when the backend
+// may have been upgraded without the connection being dropped, such as when it
+// is reached through a proxy.
+func (c *Client) RefreshBackendVersion() (BackendVersion, error) {
+	c.backendVersionMu.Lock()
+	c.backendVersion = nil
+	c.backendVersionMu.Unlock()
+
+	return c.BackendVersion()
+}
+
+// HasMethod returns whether the backend the client is currently connected to
+// supports the passed RPC method.  Support is determined by requesting the help
+// text of the method, and the result is cached until the client reconnects.
+//
+// NOTE: btcd only provides help for the methods available over HTTP POST, so
+// websocket-specific methods such as notifyblocks are reported as unsupported
+// when connected to a btcd backend.
+func (c *Client) HasMethod(method string) (bool, error) {
+	if method == "" {
+		return false, errors.New("method must be specified")
+	}
+
+	c.supportedMethodsMu.Lock()
+	supported, ok := c.supportedMethods[method]
+	c.supportedMethodsMu.Unlock()
+	if ok {
+		return supported, nil
+	}
+
+	res, err := ReceiveFuture(c.SendCmd(btcjson.NewHelpCmd(&method)))
+	switch err := err.(type) {
+	// bitcoind returns the help text of unknown methods as a regular
+	// result, so check for its unknown command message.
+	case nil:
+		var help string
+		if err := c.unmarshalJSON(res, &help); err != nil {
+			return false, err
+		}
+		supported = !strings.HasPrefix(help, "help: unknown command")
+
+	// btcd returns an invalid parameter error for unknown methods.
+	case *btcjson.RPCError:
+		if err.Code != btcjson.ErrRPCInvalidParameter {
+			return false, err
+		}
+		supported = false
+
+	default:
+		return false, err
+	}
+
+	c.supportedMethodsMu.Lock()
+	if c.supportedMethods == nil {
+		c.supportedMethods = make(map[string]bool)
+	}
+	c.supportedMethods[method] = supported
+	c.supportedMethodsMu.Unlock()
+
+	return supported, nil
+}
+
+func (c *Client) sendAsync(ctx context.Context) (*jsonRequest, []*jsonRequest,
+	error) {
+
 	c.batchLock.Lock()
 	defer c.batchLock.Unlock()
 
 	// If batchList is empty, there's nothing to send.
 	if c.batchList.Len() == 0 {
-		return nil, ErrEmptyBatch
+		return nil, nil, ErrEmptyBatch
 	}
 
 	// convert the array of marshalled json requests to a single request we can send
 	responseChan := make(chan *Response, 1)
 	marshalledRequest := []byte("[")
+	requests := make([]*jsonRequest, 0, c.batchList.Len())
 	for iter := c.batchList.Front(); iter != nil; iter = iter.Next() {
 		request := iter.Value.(*jsonRequest)
 		marshalledRequest = append(marshalledRequest, request.marshalledJSON...)
 		marshalledRequest = append(marshalledRequest, []byte(",")...)
+		requests = append(requests, request)
 	}
 	if len(marshalledRequest) > 0 {
 		// removes the trailing comma to process the request individually
@@ -1718,20 +4179,56 @@ func (c *Client) sendAsync() (FutureGetBulkResult, error) {
 		cmd:            nil,
 		marshalledJSON: marshalledRequest,
 		responseChan:   responseChan,
+		batch:          true,
+		ctx:            ctx,
 	}
 	c.sendPostRequest(&request)
-	return responseChan, nil
+	return &request, requests, nil
+}
+
+// notifyBatchSent reports a batch of count commands, marshalled into size
+// bytes, which was sent at the passed start time and completed with the passed
+// error to the OnBatchSent callback when it is set.
+func (c *Client) notifyBatchSent(count, size int, start time.Time, err error) {
+	if c.config.OnBatchSent != nil {
+		c.config.OnBatchSent(count, size, time.Since(start), err)
+	}
 }
 
 // Marshall's bulk requests and sends to the server
 // creates a response channel to receive the response
 func (c *Client) Send() error {
-	future, err := c.sendAsync()
+	return c.SendContext(context.Background())
+}
+
+// SendContext sends the queued batch of commands in the same manner as Send,
+// except that the batch is abandoned and the context error returned once the
+// passed context is done, which also aborts the in-flight HTTP request and any
+// remaining retries.  The context error is then delivered to each of the
+// batched commands and the batch is cleared.
+func (c *Client) SendContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	request, requests, err := c.sendAsync(ctx)
 	if err != nil {
 		return err
 	}
 
-	batchResp, err := future.Receive()
+	var batchResp BulkResult
+	select {
+	case resp := <-request.responseChan:
+		future := make(FutureGetBulkResult, 1)
+		future <- resp
+		batchResp, err = future.Receive()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.notifyBatchSent(
+		len(requests), len(request.marshalledJSON), start, err,
+	)
 	if err != nil {
 		// Clear batchlist in case of an error.
 
@@ -1739,6 +4236,19 @@ func (c *Client) Send() error {
 		c.batchList = list.New()
 		c.batchLock.Unlock()
 
+		// Release the batched commands when the batch was abandoned,
+		// since no response will be delivered for them.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			for _, req := range requests {
+				if c.removeRequest(req.id) == nil {
+					continue
+				}
+				req.responseChan <- &Response{
+					err: c.connErr(ctxErr),
+				}
+			}
+		}
+
 		return err
 	}
 
@@ -1754,7 +4264,7 @@ func (c *Client) Send() error {
 
 		// If there's an error, we log it and continue to the next
 		// request.
-		fullResult, err := json.Marshal(resp.Result)
+		fullResult, err := c.marshalJSON(resp.Result)
 		if err != nil {
 			log.Errorf("Unable to marshal result: %v for req=%v",
 				err, request.id)
@@ -1766,6 +4276,13 @@ func (c *Client) Send() error {
 		var requestError error
 		if resp.Error != nil {
 			requestError = resp.Error
+		} else {
+			requestError = c.validateResponse(
+				request.method, fullResult,
+			)
+			if requestError != nil {
+				fullResult = nil
+			}
 		}
 
 		result := Response{
@@ -1775,6 +4292,21 @@ func (c *Client) Send() error {
 		request.responseChan <- &result
 	}
 
+	// Some servers drop entries from the response, so deliver an error
+	// to any requests in the batch without a response rather than leaving
+	// them waiting forever.
+	for _, req := range requests {
+		if _, ok := batchResp[req.id]; ok {
+			continue
+		}
+		if c.removeRequest(req.id) == nil {
+			continue
+		}
+		log.Warnf("No response received for batched command [%s] "+
+			"with id %d", req.method, req.id)
+		req.responseChan <- &Response{err: c.connErr(ErrNoResponse)}
+	}
+
 	return nil
 }
 
@@ -1817,6 +4349,95 @@ func ParseAddressString(strAddress string) (net.Addr, error) {
 	return net.ResolveTCPAddr("tcp", verifyPort(u.Host))
 }
 
+// validateHost checks the passed address is in one of the formats accepted by
+// ParseAddressString without resolving it, so that a host which cannot be
+// resolved yet is only reported once a connection is made.
+func validateHost(strAddress string) error {
+	// Unix domain socket addresses are parsed without any lookup, and any
+	// other protocol is rejected.
+	if strings.Contains(strAddress, "://") {
+		_, err := ParseAddressString(strAddress)
+		return err
+	}
+
+	u, err := url.Parse("dummy://" + strAddress)
+	if err != nil {
+		return err
+	}
+	_, _, err = net.SplitHostPort(verifyPort(u.Host))
+	return err
+}
+
+// unresolvedAddr is a TCP address whose host has not been resolved.
+type unresolvedAddr string
+
+// Network returns the name of the network of the address.
+//
+// This is part of the net.Addr interface.
+func (a unresolvedAddr) Network() string {
+	return "tcp"
+}
+
+// String returns the address in host:port form.
+//
+// This is part of the net.Addr interface.
+func (a unresolvedAddr) String() string {
+	return string(a)
+}
+
+// dialAddr returns the address to dial to connect to the RPC server.  It is
+// parsed with ParseAddressString unless a resolver is configured, in which
+// case the host of TCP addresses is left unresolved so it is looked up with
+// the resolver each time a connection is made.
+func (config *ConnConfig) dialAddr() (net.Addr, error) {
+	// Unix domain socket addresses, which are in URL format, are not
+	// resolved.
+	if config.Resolver == nil || strings.Contains(config.Host, "://") {
+		return ParseAddressString(config.Host)
+	}
+
+	u, err := url.Parse("dummy://" + config.Host)
+	if err != nil {
+		return nil, err
+	}
+	return unresolvedAddr(verifyPort(u.Host)), nil
+}
+
+// ResolvedAddress returns the address of the RPC server the client connects to,
+// after the normalization applied to the configured host, such as expanding a
+// bare port to a localhost address, and name resolution, which uses the
+// Resolver connection option when set.  When a proxy is configured, the
+// connection is made through the proxy instead.
+func (c *Client) ResolvedAddress() (net.Addr, error) {
+	addr, err := c.config.dialAddr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := addr.(unresolvedAddr); !ok {
+		return addr, nil
+	}
+
+	// Resolve the host with the configured resolver.
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	ips, err := c.config.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := c.config.Resolver.LookupPort(ctx, "tcp", port)
+	if err != nil {
+		return nil, err
+	}
+	return &net.TCPAddr{
+		IP:   ips[0].IP,
+		Port: portNum,
+		Zone: ips[0].Zone,
+	}, nil
+}
+
 // verifyPort makes sure that an address string has both a host and a port.
 // If the address is just a port, then we'll assume that the user is using the
 // shortcut to specify a localhost:port address.
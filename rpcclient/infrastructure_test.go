@@ -1,8 +1,31 @@
 package rpcclient
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,3 +131,2870 @@ func TestParseAddressString(t *testing.T) {
 		})
 	}
 }
+
+// TestCustomJSONFuncs ensures the JSONMarshal and JSONUnmarshal functions of
+// the connection configuration are used in place of the standard library when
+// sending a command and decoding its response in HTTP POST mode.
+func TestCustomJSONFuncs(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"result":12345678901234567890,` +
+				`"error":null,"id":1}`))
+		},
+	))
+	defer server.Close()
+
+	var marshalCalls, unmarshalCalls int
+	config := &ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		JSONMarshal: func(v interface{}) ([]byte, error) {
+			marshalCalls++
+			return json.Marshal(v)
+		},
+		JSONUnmarshal: func(data []byte, v interface{}) error {
+			unmarshalCalls++
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			return dec.Decode(v)
+		},
+	}
+	client, err := New(config, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	res, err := ReceiveFuture(client.SendCmd(btcjson.NewGetBlockCountCmd()))
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567890", string(res))
+	require.NotZero(t, marshalCalls)
+	require.Equal(t, 1, unmarshalCalls)
+}
+
+// TestConnConfigValidate ensures contradictory and malformed connection
+// configurations are rejected by Validate.
+func TestConnConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		config    ConnConfig
+		expErrStr string
+	}{
+		{
+			name: "valid",
+			config: ConnConfig{
+				Host: "localhost:8334",
+				User: "user",
+				Pass: "pass",
+			},
+		},
+		{
+			name:      "missing host",
+			config:    ConnConfig{},
+			expErrStr: "no host specified",
+		},
+		{
+			name: "invalid host",
+			config: ConnConfig{
+				Host: "http://localhost:8334",
+			},
+			expErrStr: "invalid host",
+		},
+		{
+			name: "unresolvable host",
+			config: ConnConfig{
+				Host: "node.rpcclient.invalid:8334",
+				User: "user",
+				Pass: "pass",
+			},
+		},
+		{
+			name: "unix socket",
+			config: ConnConfig{
+				Host: "unix:///tmp/btcd.sock",
+				User: "user",
+				Pass: "pass",
+			},
+		},
+		{
+			name: "cookie and password",
+			config: ConnConfig{
+				Host:       "localhost:8334",
+				Pass:       "pass",
+				CookiePath: "/tmp/.cookie",
+			},
+			expErrStr: "only one of a cookie path",
+		},
+		{
+			name: "proxy credentials without proxy",
+			config: ConnConfig{
+				Host:      "localhost:8334",
+				ProxyUser: "user",
+			},
+		},
+		{
+			name: "websocket proxy without port",
+			config: ConnConfig{
+				Host:  "localhost:8334",
+				Proxy: "localhost",
+			},
+			expErrStr: "invalid proxy address",
+		},
+		{
+			name: "post mode proxy url",
+			config: ConnConfig{
+				Host:         "localhost:8334",
+				Proxy:        "127.0.0.1:9050",
+				HTTPPostMode: true,
+			},
+			expErrStr: "invalid proxy URL",
+		},
+		{
+			name: "certificates with tls disabled",
+			config: ConnConfig{
+				Host:         "localhost:8334",
+				DisableTLS:   true,
+				Certificates: []byte("cert"),
+			},
+		},
+		{
+			name: "auth header func and password",
+			config: ConnConfig{
+				Host: "localhost:8334",
+				Pass: "pass",
+				AuthHeaderFunc: func() (string, error) {
+					return "Bearer token", nil
+				},
+			},
+			expErrStr: "authorization header function",
+		},
+		{
+			name: "codec with custom json functions",
+			config: ConnConfig{
+				Host:        "localhost:8334",
+				Codec:       prefixCodec{},
+				JSONMarshal: json.Marshal,
+			},
+			expErrStr: "codec may not be specified",
+		},
+		{
+			name: "endpoint and endpoints",
+			config: ConnConfig{
+				Host:      "localhost:8334",
+				Endpoint:  "ws",
+				Endpoints: []string{"ws", "v2/ws"},
+			},
+			expErrStr: "only one of an endpoint",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.expErrStr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expErrStr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestWebsocketSubprotocol ensures the configured subprotocols are requested
+// during the websocket handshake and the server's selection is recorded.
+func TestWebsocketSubprotocol(t *testing.T) {
+	t.Parallel()
+
+	requested := make(chan string, 1)
+	subprotocolUpgrader := websocket.Upgrader{
+		Subprotocols: []string{"jsonrpc-v2"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requested <- r.Header.Get("Sec-Websocket-Protocol")
+			conn, err := subprotocolUpgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	config := &ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		Subprotocols:         []string{"jsonrpc-v1", "jsonrpc-v2"},
+	}
+	client, err := New(config, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Equal(t, "jsonrpc-v1, jsonrpc-v2", <-requested)
+
+	subprotocol, err := client.Subprotocol()
+	require.NoError(t, err)
+	require.Equal(t, "jsonrpc-v2", subprotocol)
+}
+
+// TestReconnectOnRPCError ensures the client disconnects when the
+// ReconnectOnRPCError hook reports an error as requiring a fresh connection.
+func TestReconnectOnRPCError(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config: &ConnConfig{
+			ReconnectOnRPCError: func(err *btcjson.RPCError) bool {
+				return err.Code == btcjson.ErrRPCDatabase
+			},
+		},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		disconnect:  make(chan struct{}),
+		shutdown:    make(chan struct{}),
+	}
+
+	sendErr := func(id uint64, code btcjson.RPCErrorCode) error {
+		responseChan := make(chan *Response, 1)
+		require.NoError(t, client.addRequest(&jsonRequest{
+			id:           id,
+			method:       "getblockcount",
+			responseChan: responseChan,
+		}))
+		msg := fmt.Sprintf(`{"result":null,"error":{"code":%d,`+
+			`"message":"failed"},"id":%d}`, code, id)
+		client.handleMessage([]byte(msg))
+		return (<-responseChan).err
+	}
+
+	connEstablished := make(chan struct{})
+	close(connEstablished)
+	client.connEstablished = connEstablished
+
+	// Errors the hook does not match leave the connection alone.
+	require.Error(t, sendErr(1, btcjson.ErrRPCMisc))
+	require.False(t, client.Disconnected())
+
+	// Matching errors are still delivered, and force a disconnect.
+	require.Error(t, sendErr(2, btcjson.ErrRPCDatabase))
+	require.True(t, client.Disconnected())
+}
+
+// TestPendingRequests ensures the pending requests are reported oldest first
+// and are no longer reported once their responses arrive.
+func TestPendingRequests(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:      &ConnConfig{},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		shutdown:    make(chan struct{}),
+	}
+	require.Empty(t, client.PendingRequests())
+
+	for id, method := range []string{"getblock", "getbestblockhash"} {
+		require.NoError(t, client.addRequest(&jsonRequest{
+			id:           uint64(id + 1),
+			method:       method,
+			responseChan: make(chan *Response, 1),
+		}))
+	}
+
+	pending := client.PendingRequests()
+	require.Len(t, pending, 2)
+	require.Equal(t, uint64(1), pending[0].ID)
+	require.Equal(t, "getblock", pending[0].Method)
+	require.Equal(t, uint64(2), pending[1].ID)
+	require.Equal(t, "getbestblockhash", pending[1].Method)
+	require.GreaterOrEqual(t, pending[0].Age, pending[1].Age)
+
+	client.handleMessage([]byte(`{"result":"00","error":null,"id":1}`))
+	pending = client.PendingRequests()
+	require.Len(t, pending, 1)
+	require.Equal(t, "getbestblockhash", pending[0].Method)
+}
+
+// TestCallContext ensures CallContext unmarshals the result of a call and
+// abandons the call once its context is done in both HTTP POST and websocket
+// modes.
+func TestCallContext(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	postServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			// Never reply to getbestblockhash.
+			if req.Method == "getbestblockhash" {
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+				return
+			}
+			fmt.Fprintf(w, `{"result":100,"error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer postServer.Close()
+
+	postClient, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(postServer.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer postClient.Shutdown()
+
+	var count int64
+	err = postClient.CallContext(
+		context.Background(), btcjson.NewGetBlockCountCmd(), &count,
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), count)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+	err = postClient.CallContext(
+		ctx, btcjson.NewGetBestBlockHashCmd(), nil,
+	)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The websocket server never replies.
+	wsServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer wsServer.Close()
+
+	wsClient, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(wsServer.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, nil)
+	require.NoError(t, err)
+	defer wsClient.Shutdown()
+
+	ctx, cancel = context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+	err = wsClient.CallContext(ctx, btcjson.NewGetBlockCountCmd(), nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Empty(t, wsClient.PendingRequests())
+}
+
+// TestPostContentType ensures HTTP POST requests are sent with the configured
+// Content-Type, defaulting to application/json.
+func TestPostContentType(t *testing.T) {
+	t.Parallel()
+
+	contentTypes := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			contentTypes <- r.Header.Get("Content-Type")
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	for _, contentType := range []string{"", "application/json-rpc"} {
+		client, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(server.URL, "http://"),
+			User:            "user",
+			Pass:            "pass",
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			PostContentType: contentType,
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = client.GetBlockCount()
+		require.NoError(t, err)
+		client.Shutdown()
+
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		require.Equal(t, contentType, <-contentTypes)
+	}
+}
+
+// TestPostFailureThreshold ensures a client in HTTP POST mode is reported as
+// disconnected after the configured number of consecutive failed requests and
+// as connected again after a successful one.
+func TestPostFailureThreshold(t *testing.T) {
+	t.Parallel()
+
+	connEstablished := make(chan struct{})
+	close(connEstablished)
+	client := &Client{
+		config: &ConnConfig{
+			HTTPPostMode:         true,
+			PostFailureThreshold: 2,
+		},
+		connEstablished: connEstablished,
+	}
+	require.True(t, client.IsConnected())
+
+	client.recordPostFailure()
+	require.True(t, client.IsConnected())
+
+	client.recordPostFailure()
+	require.False(t, client.IsConnected())
+	require.True(t, client.Disconnected())
+	_, ok := client.ConnectedSince()
+	require.False(t, ok)
+
+	client.recordPostSuccess()
+	require.True(t, client.IsConnected())
+	require.False(t, client.Disconnected())
+	_, ok = client.ConnectedSince()
+	require.True(t, ok)
+}
+
+// TestStringResponseIDs ensures responses are correlated with their requests
+// when the server echoes the ids back as strings.
+func TestStringResponseIDs(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:      &ConnConfig{},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		shutdown:    make(chan struct{}),
+	}
+
+	for _, msg := range []string{
+		`{"result":1,"error":null,"id":"1"}`,
+		`{"result":2,"error":null,"id":2}`,
+		`{"result":3,"error":null,"id":3.0}`,
+	} {
+		responseChan := make(chan *Response, 1)
+		id := client.NextID()
+		require.NoError(t, client.addRequest(&jsonRequest{
+			id:           id,
+			method:       "getblockcount",
+			responseChan: responseChan,
+		}))
+		client.handleMessage([]byte(msg))
+
+		select {
+		case resp := <-responseChan:
+			require.NoError(t, resp.err)
+			require.Equal(t, []byte(fmt.Sprint(id)), resp.result)
+		default:
+			t.Fatalf("no response delivered for %s", msg)
+		}
+	}
+
+	// Invalid identifiers are rejected.
+	for _, id := range []string{`"abc"`, `"-1"`, `-1`, `1.5`} {
+		var in inMessage
+		err := json.Unmarshal([]byte(`{"id":`+id+`}`), &in)
+		require.Error(t, err, id)
+	}
+
+	// Batch results with string ids are keyed by their integer id.
+	var results []IndividualBulkResult
+	err := json.Unmarshal(
+		[]byte(`[{"result":"a","error":null,"id":"7"}]`), &results,
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), results[0].Id)
+	require.Equal(t, "a", results[0].Result)
+}
+
+// TestRequestCompression ensures HTTP POST requests advertise gzip support
+// when RequestCompression is set and gzip-encoded responses are decompressed.
+func TestRequestCompression(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `{"result":42,"error":null,"id":%v}`,
+				req.ID)
+			require.NoError(t, gz.Close())
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:               strings.TrimPrefix(server.URL, "http://"),
+		User:               "user",
+		Pass:               "pass",
+		DisableTLS:         true,
+		HTTPPostMode:       true,
+		RequestCompression: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.Equal(t, int64(42), count)
+}
+
+// TestNewWithConn ensures a client created with an established connection
+// performs the websocket handshake and issues requests over it.
+func TestNewWithConn(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				reply := fmt.Sprintf(`{"result":5,"error":null,`+
+					`"id":%v}`, req.ID)
+				err := conn.WriteMessage(
+					websocket.TextMessage, []byte(reply),
+				)
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+
+	// Nothing listens on the host, so the provided connection must be used.
+	client, err := NewWithConn(&ConnConfig{
+		Host:                 "127.0.0.1:1",
+		Endpoint:             "ws",
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, conn, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), count)
+}
+
+// TestWithRequestID ensures the correlation id attached to a context is
+// included in the description of requests used in log messages.
+func TestWithRequestID(t *testing.T) {
+	t.Parallel()
+
+	_, ok := RequestIDFromContext(context.Background())
+	require.False(t, ok)
+
+	ctx := WithRequestID(context.Background(), "trace-1")
+	requestID, ok := RequestIDFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "trace-1", requestID)
+
+	jReq := &jsonRequest{id: 3, method: "getblock"}
+	require.Equal(t, "[getblock] with id 3", jReq.String())
+
+	jReq.requestID = requestID
+	require.Equal(t, "[getblock] with id 3 (request id trace-1)",
+		jReq.String())
+}
+
+// TestCustomChainParams ensures custom chain parameters are used in place of
+// the network name.
+func TestCustomChainParams(t *testing.T) {
+	t.Parallel()
+
+	customParams := chaincfg.RegressionNetParams
+	customParams.Name = "customnet"
+
+	newClient := func(config *ConnConfig) (*Client, error) {
+		config.Host = "127.0.0.1:8334"
+		config.HTTPPostMode = true
+		return New(config, nil)
+	}
+
+	_, err := newClient(&ConnConfig{Params: customParams.Name})
+	require.ErrorContains(t, err, "Unknown chain")
+
+	client, err := newClient(&ConnConfig{
+		Params:      customParams.Name,
+		ChainParams: &customParams,
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+	require.Same(t, &customParams, client.chainParams)
+}
+
+// TestConnectContext ensures ConnectContext stops retrying once its context is
+// done.
+func TestConnectContext(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on the host, so every attempt fails.
+	client, err := New(&ConnConfig{
+		Host:                "127.0.0.1:1",
+		User:                "user",
+		Pass:                "pass",
+		DisableTLS:          true,
+		DisableConnectOnNew: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 100*time.Millisecond,
+	)
+	defer cancel()
+
+	start := time.Now()
+	err = client.ConnectContext(ctx, 0)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), connectionRetryInterval)
+}
+
+// TestInitialConnectBackoff ensures Connect waits between failed attempts for
+// the durations returned by InitialConnectBackoff rather than those used for
+// reconnects.
+func TestInitialConnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on the host, so every attempt fails.
+	var attempts []int64
+	client, err := New(&ConnConfig{
+		Host:                "127.0.0.1:1",
+		User:                "user",
+		Pass:                "pass",
+		DisableTLS:          true,
+		DisableConnectOnNew: true,
+		InitialConnectBackoff: func(attempt int64) time.Duration {
+			attempts = append(attempts, attempt)
+			return time.Millisecond
+		},
+		ReconnectBackoff: func(int64) time.Duration {
+			return time.Hour
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	start := time.Now()
+	require.Error(t, client.Connect(3))
+	require.Less(t, time.Since(start), connectionRetryInterval)
+	require.Equal(t, []int64{1, 2, 3}, attempts)
+}
+
+// TestAppendSystemCertPool ensures the configured certificates are trusted
+// together with the system roots when AppendSystemCertPool is set.
+func TestAppendSystemCertPool(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	// No pool is returned without certificates so the system roots are
+	// used as is.
+	config := &ConnConfig{AppendSystemCertPool: true}
+	pool, err := config.rootCAs()
+	require.NoError(t, err)
+	require.Nil(t, pool)
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "https://"),
+		User:                 "user",
+		Pass:                 "pass",
+		Certificates:         cert,
+		AppendSystemCertPool: true,
+		HTTPPostMode:         true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+// TestPinnedCertFingerprints ensures the TLS handshake only succeeds when the
+// certificate presented by the server matches a pinned fingerprint.
+func TestPinnedCertFingerprints(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+	newClient := func(pins ...[32]byte) *Client {
+		client, err := New(&ConnConfig{
+			Host:                   strings.TrimPrefix(server.URL, "https://"),
+			User:                   "user",
+			Pass:                   "pass",
+			Certificates:           cert,
+			PinnedCertFingerprints: pins,
+			HTTPPostMode:           true,
+			RetryableMethods:       map[string]bool{},
+		}, nil)
+		require.NoError(t, err)
+		return client
+	}
+
+	pinned := sha256.Sum256(server.Certificate().Raw)
+	client := newClient([32]byte{1}, pinned)
+	defer client.Shutdown()
+	_, err := client.GetBlockCount()
+	require.NoError(t, err)
+
+	client = newClient([32]byte{1})
+	defer client.Shutdown()
+	_, err = client.GetBlockCount()
+	require.ErrorContains(t, err, "is not pinned")
+}
+
+// TestMinTLSVersion ensures the TLS handshake fails when the server does not
+// support the minimum TLS version of the client.
+func TestMinTLSVersion(t *testing.T) {
+	t.Parallel()
+
+	// The server supports up to TLS 1.2.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+	newClient := func(minVersion uint16) *Client {
+		client, err := New(&ConnConfig{
+			Host:             strings.TrimPrefix(server.URL, "https://"),
+			User:             "user",
+			Pass:             "pass",
+			Certificates:     cert,
+			MinTLSVersion:    minVersion,
+			HTTPPostMode:     true,
+			RetryableMethods: map[string]bool{},
+		}, nil)
+		require.NoError(t, err)
+		return client
+	}
+
+	// TLS 1.2 is negotiated by default.
+	client := newClient(0)
+	defer client.Shutdown()
+	_, err := client.GetBlockCount()
+	require.NoError(t, err)
+
+	client = newClient(tls.VersionTLS13)
+	defer client.Shutdown()
+	_, err = client.GetBlockCount()
+	require.ErrorContains(t, err, "protocol version")
+}
+
+// TestHasMethod ensures HasMethod detects unknown methods as reported by both
+// btcd and bitcoind and caches the results.
+func TestHasMethod(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+
+			var req struct {
+				Params []string `json:"params"`
+				ID     uint64   `json:"id"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			switch req.Params[0] {
+			case "getblockcount":
+				fmt.Fprintf(w, `{"result":"getblockcount\n",`+
+					`"error":null,"id":%d}`, req.ID)
+			case "btcdunknown":
+				fmt.Fprintf(w, `{"result":null,"error":{"code":-8,`+
+					`"message":"Unknown command"},"id":%d}`,
+					req.ID)
+			default:
+				fmt.Fprintf(w, `{"result":"help: unknown command: `+
+					`%s","error":null,"id":%d}`, req.Params[0],
+					req.ID)
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	tests := []struct {
+		method    string
+		supported bool
+	}{
+		{"getblockcount", true},
+		{"btcdunknown", false},
+		{"bitcoindunknown", false},
+	}
+	for _, test := range tests {
+		supported, err := client.HasMethod(test.method)
+		require.NoError(t, err)
+		require.Equal(t, test.supported, supported, test.method)
+	}
+
+	// The results are cached, so no further requests are made.
+	for _, test := range tests {
+		supported, err := client.HasMethod(test.method)
+		require.NoError(t, err)
+		require.Equal(t, test.supported, supported, test.method)
+	}
+	require.EqualValues(t, len(tests), atomic.LoadInt32(&requests))
+}
+
+// TestReadTimeout ensures a websocket client disconnects when the server stops
+// sending data for longer than the configured read timeout.
+func TestReadTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// Never respond, so the connection stalls.
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		ReadTimeout:          50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Eventually(t, client.Disconnected, time.Second,
+		10*time.Millisecond)
+}
+
+// TestValidationHooks ensures failures of the request and response validation
+// hooks are returned to the caller.
+func TestValidationHooks(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			fmt.Fprint(w, `{"result":"notanumber","error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	errInvalid := errors.New("invalid")
+	var rejectRequests bool
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		ValidateRequest: func(method string, marshalled []byte) error {
+			require.Equal(t, "getblockcount", method)
+			require.Contains(t, string(marshalled), method)
+			if rejectRequests {
+				return errInvalid
+			}
+			return nil
+		},
+		ValidateResponse: func(method string, result []byte) error {
+			require.Equal(t, "getblockcount", method)
+			if !bytes.Equal(result, []byte("1")) {
+				return errInvalid
+			}
+			return nil
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// An invalid response is reported to the caller.
+	_, err = client.GetBlockCount()
+	require.ErrorIs(t, err, errInvalid)
+	require.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+	// An invalid request is never sent.
+	rejectRequests = true
+	_, err = client.GetBlockCount()
+	require.ErrorIs(t, err, errInvalid)
+	require.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
+
+// TestRefreshBackendVersion ensures RefreshBackendVersion detects the backend
+// version again rather than returning the cached one.
+func TestRefreshBackendVersion(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":{"version":240100},"error":null,`+
+				`"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// Start with a stale cached version.
+	client.backendVersion = BitcoindPost25
+	version, err := client.BackendVersion()
+	require.NoError(t, err)
+	require.Equal(t, BitcoindPost25, version)
+
+	version, err = client.RefreshBackendVersion()
+	require.NoError(t, err)
+	require.Equal(t, BtcdPost2401, version)
+}
+
+// TestRetryableMethods ensures only the methods in RetryableMethods are retried
+// after a network error.
+func TestRetryableMethods(t *testing.T) {
+	t.Parallel()
+
+	var mtx sync.Mutex
+	attempts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			mtx.Lock()
+			attempts[req.Method]++
+			first := attempts[req.Method] == 1
+			mtx.Unlock()
+
+			// Drop the connection on the first attempt of each
+			// method.
+			if first {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				conn.Close()
+				return
+			}
+			fmt.Fprintf(w, `{"result":"00","error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		RetryableMethods: map[string]bool{
+			"getbestblockhash": true,
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	_, err = client.GetBlockHash(1)
+	require.Error(t, err)
+
+	_, err = client.GetBestBlockHash()
+	require.NoError(t, err)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, 1, attempts["getblockhash"])
+	require.Equal(t, 2, attempts["getbestblockhash"])
+}
+
+// wsFrame describes a websocket frame received by the server.
+type wsFrame struct {
+	opcode byte
+	final  bool
+	size   uint64
+}
+
+// readFrames parses the websocket frames sent by a client from the passed
+// stream, which begins with the opening handshake, and sends them to frames.
+func readFrames(r io.Reader, frames chan<- wsFrame) {
+	// Keep consuming the stream on failure so the connection is not
+	// blocked.
+	defer io.Copy(io.Discard, r)
+
+	br := bufio.NewReader(r)
+	if _, err := http.ReadRequest(br); err != nil {
+		return
+	}
+
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return
+		}
+
+		size := uint64(header[1] & 0x7f)
+		switch size {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(br, ext[:]); err != nil {
+				return
+			}
+			size = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(br, ext[:]); err != nil {
+				return
+			}
+			size = binary.BigEndian.Uint64(ext[:])
+		}
+
+		// Skip the masking key and the payload.
+		skip := int64(size)
+		if header[1]&0x80 != 0 {
+			skip += 4
+		}
+		if _, err := io.CopyN(io.Discard, br, skip); err != nil {
+			return
+		}
+
+		frames <- wsFrame{
+			opcode: header[0] & 0x0f,
+			final:  header[0]&0x80 != 0,
+			size:   size,
+		}
+	}
+}
+
+// frameListener is a net.Listener whose connections pass the data read from
+// them to readFrames, so that the frames sent by the client are recorded
+// while the server reads the messages as usual.
+type frameListener struct {
+	net.Listener
+	frames chan wsFrame
+}
+
+// Accept waits for the next connection and records the frames read from it.
+func (l *frameListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go readFrames(pr, l.frames)
+	return &frameConn{Conn: conn, pw: pw}, nil
+}
+
+// frameConn is a net.Conn which copies the data read from it to a pipe.
+type frameConn struct {
+	net.Conn
+	pw *io.PipeWriter
+}
+
+// Read reads from the wrapped connection and copies the data to the pipe.
+func (c *frameConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		_, _ = c.pw.Write(b[:n])
+	}
+	return n, err
+}
+
+// Close closes the pipe and the wrapped connection.
+func (c *frameConn) Close() error {
+	c.pw.Close()
+	return c.Conn.Close()
+}
+
+// TestMaxOutgoingFrameSize ensures large messages are split into frames no
+// bigger than the configured maximum, which the server reassembles into the
+// original message.
+func TestMaxOutgoingFrameSize(t *testing.T) {
+	t.Parallel()
+
+	messages := make(chan []byte, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				messages <- msg
+			}
+		},
+	))
+	frames := make(chan wsFrame, 100)
+	server.Listener = &frameListener{
+		Listener: server.Listener,
+		frames:   frames,
+	}
+	server.Start()
+	defer server.Close()
+
+	const maxFrameSize = 1000
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		MaxOutgoingFrameSize: maxFrameSize,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	block := strings.Repeat("00", 5000)
+	client.SendCmd(btcjson.NewSubmitBlockCmd(block, nil))
+
+	var msg []byte
+	select {
+	case msg = <-messages:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+	var req btcjson.Request
+	require.NoError(t, json.Unmarshal(msg, &req))
+	require.Equal(t, "submitblock", req.Method)
+	require.Equal(t, `"`+block+`"`, string(req.Params[0]))
+
+	// The message was sent as a text frame followed by continuation
+	// frames, none bigger than the maximum.
+	var sizes []uint64
+	var total uint64
+	for {
+		var frame wsFrame
+		select {
+		case frame = <-frames:
+		case <-time.After(time.Second):
+			t.Fatal("frame not received")
+		}
+		if len(sizes) == 0 {
+			require.EqualValues(t, websocket.TextMessage, frame.opcode)
+		} else {
+			require.Zero(t, frame.opcode)
+		}
+		require.LessOrEqual(t, frame.size, uint64(maxFrameSize))
+		sizes = append(sizes, frame.size)
+		total += frame.size
+		if frame.final {
+			break
+		}
+	}
+	require.Greater(t, len(sizes), 1)
+	require.EqualValues(t, len(msg), total)
+}
+
+// TestDisableResendOnReconnect ensures pending requests fail with
+// ErrClientDisconnect on reconnect instead of being resent when
+// DisableResendOnReconnect is set.
+func TestDisableResendOnReconnect(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:      &ConnConfig{DisableResendOnReconnect: true},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		ntfnState:   newNotificationState(),
+		sendChan:    make(chan []byte, 1),
+		shutdown:    make(chan struct{}),
+	}
+	responseChan := make(chan *Response, 1)
+	require.NoError(t, client.addRequest(&jsonRequest{
+		id:           1,
+		method:       "sendrawtransaction",
+		responseChan: responseChan,
+	}))
+
+	client.resendRequests()
+
+	resp := <-responseChan
+	require.ErrorIs(t, resp.err, ErrClientDisconnect)
+	require.Empty(t, client.PendingRequests())
+	require.Empty(t, client.sendChan)
+}
+
+// TestReconnectHooks ensures the reconnect lifecycle callbacks are invoked for
+// each automatic reconnect attempt and its outcome.
+func TestReconnectHooks(t *testing.T) {
+	t.Parallel()
+
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Reject the first reconnect attempt.
+			if atomic.AddInt32(&connections, 1) == 2 {
+				http.Error(w, "unavailable",
+					http.StatusServiceUnavailable)
+				return
+			}
+
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	events := make(chan string, 10)
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ReconnectBackoff: func(int64) time.Duration {
+			return 10 * time.Millisecond
+		},
+		OnReconnectAttempt: func(attempt int64) {
+			events <- fmt.Sprintf("attempt %d", attempt)
+		},
+		OnReconnectFailed: func(attempt int64, err error) {
+			events <- fmt.Sprintf("failed %d", attempt)
+		},
+		OnReconnectSucceeded: func() {
+			events <- "succeeded"
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	client.Disconnect()
+
+	expected := []string{"attempt 1", "failed 1", "attempt 2", "succeeded"}
+	for _, event := range expected {
+		select {
+		case got := <-events:
+			require.Equal(t, event, got)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", event)
+		}
+	}
+}
+
+// prefixCodec is a Codec which encodes requests and responses as JSON prefixed
+// with a single marker byte.
+type prefixCodec struct{}
+
+// Marshal encodes requests as prefixed JSON and all other values, such as the
+// params of requests, as plain JSON.
+func (prefixCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.(*btcjson.Request); ok {
+		b = append([]byte("J"), b...)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes prefixed or plain JSON.
+func (prefixCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(bytes.TrimPrefix(data, []byte("J")), v)
+}
+
+// TestCodec ensures requests and responses are encoded and decoded with the
+// configured codec.
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(body, []byte("J")))
+
+			var req btcjson.Request
+			err = json.Unmarshal(body[1:], &req)
+			require.NoError(t, err)
+			require.Equal(t, "getblockhash", req.Method)
+
+			fmt.Fprintf(w, `J{"result":"00","error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		Codec:        prefixCodec{},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	res, err := ReceiveFuture(client.SendCmd(btcjson.NewGetBlockHashCmd(1)))
+	require.NoError(t, err)
+	require.Equal(t, `"00"`, string(res))
+}
+
+// TestResolvedAddress ensures ResolvedAddress returns the normalized address of
+// the configured host.
+func TestResolvedAddress(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{config: &ConnConfig{Host: "127.0.0.1:8334"}}
+	addr, err := client.ResolvedAddress()
+	require.NoError(t, err)
+	require.Equal(t, "tcp", addr.Network())
+	require.Equal(t, "127.0.0.1:8334", addr.String())
+
+	// A bare port is expanded to a localhost address.
+	client = &Client{config: &ConnConfig{Host: "18334"}}
+	addr, err = client.ResolvedAddress()
+	require.NoError(t, err)
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	require.True(t, ok)
+	require.True(t, tcpAddr.IP.IsLoopback())
+	require.Equal(t, 18334, tcpAddr.Port)
+
+	client = &Client{config: &ConnConfig{Host: "unix:///tmp/btcd.sock"}}
+	addr, err = client.ResolvedAddress()
+	require.NoError(t, err)
+	require.Equal(t, "unix", addr.Network())
+	require.Equal(t, "/tmp/btcd.sock", addr.String())
+}
+
+// TestAuthHeaderFunc ensures the Authorization header returned by
+// AuthHeaderFunc is used for each request in place of basic authorization.
+func TestAuthHeaderFunc(t *testing.T) {
+	t.Parallel()
+
+	auths := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			auths <- r.Header.Get("Authorization")
+
+			if r.Header.Get("Upgrade") == "" {
+				fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+				return
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			conn.Close()
+		},
+	))
+	defer server.Close()
+
+	var tokens int32
+	authHeaderFunc := func() (string, error) {
+		token := atomic.AddInt32(&tokens, 1)
+		return fmt.Sprintf("Bearer token%d", token), nil
+	}
+
+	client, err := New(&ConnConfig{
+		Host:           strings.TrimPrefix(server.URL, "http://"),
+		DisableTLS:     true,
+		HTTPPostMode:   true,
+		AuthHeaderFunc: authHeaderFunc,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// The function is called again for each request.
+	for _, expected := range []string{"token1", "token2"} {
+		_, err := client.GetBlockCount()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer "+expected, <-auths)
+	}
+
+	wsClient, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		AuthHeaderFunc:       authHeaderFunc,
+	}, nil)
+	require.NoError(t, err)
+	defer wsClient.Shutdown()
+	require.Equal(t, "Bearer token3", <-auths)
+}
+
+// TestConnName ensures errors of failed requests are prefixed with the
+// connection name while still matching the sentinel errors.
+func TestConnName(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(&ConnConfig{
+		ConnName:            "backup",
+		Host:                "127.0.0.1:1",
+		User:                "user",
+		Pass:                "pass",
+		DisableTLS:          true,
+		DisableConnectOnNew: true,
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = client.GetBlockCount()
+	require.ErrorIs(t, err, ErrClientNotConnected)
+	require.True(t, strings.HasPrefix(err.Error(), "backup: "))
+
+	client.Shutdown()
+	err = client.addRequest(&jsonRequest{id: 1})
+	require.ErrorIs(t, err, ErrClientShutdown)
+	require.True(t, strings.HasPrefix(err.Error(), "backup: "))
+}
+
+// TestClientDisconnectedCloseCode ensures the close code sent by the server is
+// passed to the OnClientDisconnected handler and recorded.
+func TestClientDisconnectedCloseCode(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			msg := websocket.FormatCloseMessage(
+				1013, "try again later",
+			)
+			err = conn.WriteControl(
+				websocket.CloseMessage, msg,
+				time.Now().Add(time.Second),
+			)
+			require.NoError(t, err)
+		},
+	))
+	defer server.Close()
+
+	type closeEvent struct {
+		code int
+		text string
+	}
+	closes := make(chan closeEvent, 1)
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, &NotificationHandlers{
+		OnClientDisconnected: func(code int, text string) {
+			closes <- closeEvent{code, text}
+		},
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	select {
+	case event := <-closes:
+		require.Equal(t, closeEvent{1013, "try again later"}, event)
+	case <-time.After(time.Second):
+		t.Fatal("OnClientDisconnected not invoked")
+	}
+
+	code, text := client.LastCloseCode()
+	require.Equal(t, 1013, code)
+	require.Equal(t, "try again later", text)
+}
+
+// TestParseCloseError ensures close codes are extracted from the errors
+// returned by the websocket package.
+func TestParseCloseError(t *testing.T) {
+	t.Parallel()
+
+	code, text := parseCloseError(io.EOF)
+	require.Equal(t, 1000, code)
+	require.Empty(t, text)
+
+	code, text = parseCloseError(errors.New("websocket: close 4000 custom"))
+	require.Equal(t, 4000, code)
+	require.Equal(t, "custom", text)
+
+	code, text = parseCloseError(errors.New("connection reset"))
+	require.Equal(t, 1006, code)
+	require.Equal(t, "connection reset", text)
+}
+
+// TestPostTimeouts ensures the response header and request timeouts are
+// applied to the HTTP client used in HTTP POST mode.
+func TestPostTimeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration
+		want           time.Duration
+	}{
+		{"default", 0, defaultHTTPTimeout},
+		{"custom", time.Minute, time.Minute},
+		{"disabled", -1, 0},
+	}
+	for _, test := range tests {
+		httpClient, err := newHTTPClient(&ConnConfig{
+			Host:                  "127.0.0.1:8334",
+			DisableTLS:            true,
+			ResponseHeaderTimeout: time.Second,
+			RequestTimeout:        test.requestTimeout,
+		})
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, httpClient.Timeout, test.name)
+
+		transport := httpClient.Transport.(*http.Transport)
+		require.Equal(t, time.Second, transport.ResponseHeaderTimeout,
+			test.name)
+	}
+
+	// A server which does not begin its response in time fails the
+	// request even though the overall request timeout is much longer.
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		},
+	))
+	defer server.Close()
+	defer close(release)
+
+	client, err := New(&ConnConfig{
+		Host:                  strings.TrimPrefix(server.URL, "http://"),
+		User:                  "user",
+		Pass:                  "pass",
+		DisableTLS:            true,
+		HTTPPostMode:          true,
+		RetryableMethods:      map[string]bool{},
+		ResponseHeaderTimeout: 50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	start := time.Now()
+	_, err = client.GetBlockCount()
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+// TestKeepAliveRPC ensures the configured keepalive RPC is issued periodically
+// on the websocket connection.
+func TestKeepAliveRPC(t *testing.T) {
+	t.Parallel()
+
+	requests := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				select {
+				case requests <- req.Method:
+				default:
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": "00",
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+		KeepAliveRPCInterval: 20 * time.Millisecond,
+		KeepAliveRPCMethod:   "getbestblockhash",
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case method := <-requests:
+			require.Equal(t, "getbestblockhash", method)
+		case <-time.After(time.Second):
+			t.Fatal("keepalive request not sent")
+		}
+	}
+}
+
+// TestDialEndpoints ensures each of the candidate endpoints is tried in order
+// until the websocket handshake succeeds, and that an authentication failure
+// stops the dial immediately.
+func TestDialEndpoints(t *testing.T) {
+	t.Parallel()
+
+	var mtx sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			paths = append(paths, r.URL.Path)
+			mtx.Unlock()
+
+			switch {
+			case r.Header.Get("Authorization") == "Bearer bad":
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			case r.URL.Path != "/v2/ws":
+				http.NotFound(w, r)
+				return
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			conn.Close()
+		},
+	))
+	defer server.Close()
+
+	config := &ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		Endpoints:  []string{"ws", "/legacy", "/v2/ws"},
+	}
+	config.normalize()
+	wsConn, err := dial(config)
+	require.NoError(t, err)
+	wsConn.Close()
+	require.Equal(t, []string{"/ws", "/legacy", "/v2/ws"}, paths)
+
+	// The last handshake error is returned when no endpoint succeeds.
+	config.Endpoints = []string{"ws", "legacy"}
+	_, err = dial(config)
+	require.EqualError(t, err, "404 Not Found")
+
+	// An authentication failure is not retried with other endpoints.
+	paths = nil
+	config.User, config.Pass = "", ""
+	config.AuthHeaderFunc = func() (string, error) {
+		return "Bearer bad", nil
+	}
+	_, err = dial(config)
+	require.ErrorIs(t, err, ErrInvalidAuth)
+	require.Equal(t, []string{"/ws"}, paths)
+}
+
+// TestPostRequestOrdering ensures requests issued with the async functions in
+// HTTP POST mode reach the server one at a time in the order they were issued.
+func TestPostRequestOrdering(t *testing.T) {
+	t.Parallel()
+
+	var mtx sync.Mutex
+	var inFlight int
+	var heights []int64
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var height int64
+			require.NoError(t, json.Unmarshal(req.Params[0], &height))
+
+			mtx.Lock()
+			inFlight++
+			require.Equal(t, 1, inFlight)
+			heights = append(heights, height)
+			mtx.Unlock()
+
+			// Give later requests the chance to overtake this one
+			// if they were dispatched concurrently.
+			time.Sleep(time.Millisecond)
+
+			mtx.Lock()
+			inFlight--
+			mtx.Unlock()
+
+			fmt.Fprintf(w, `{"result":"00","error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	var futures []FutureGetBlockHashResult
+	var want []int64
+	for height := int64(0); height < 20; height++ {
+		futures = append(futures, client.GetBlockHashAsync(height))
+		want = append(want, height)
+	}
+	for _, future := range futures {
+		_, err := future.Receive()
+		require.NoError(t, err)
+	}
+	require.Equal(t, want, heights)
+}
+
+// TestOnHTTPResponse ensures the status code and headers of each HTTP POST
+// response are passed to the OnHTTPResponse callback.
+func TestOnHTTPResponse(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			w.Header().Set("X-RateLimit-Remaining", "41")
+			fmt.Fprintf(w, `{"result":100,"error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	type httpResponse struct {
+		method     string
+		statusCode int
+		remaining  string
+	}
+	responses := make(chan httpResponse, 1)
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		OnHTTPResponse: func(method string, statusCode int,
+			headers http.Header) {
+
+			responses <- httpResponse{
+				method:     method,
+				statusCode: statusCode,
+				remaining:  headers.Get("X-RateLimit-Remaining"),
+			}
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// The body is still read after the callback.
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.Equal(t, int64(100), count)
+	require.Equal(t, httpResponse{"getblockcount", http.StatusOK, "41"},
+		<-responses)
+}
+
+// TestOnMalformedMessage ensures messages dropped by handleMessage are passed
+// to the OnMalformedMessage callback along with the reason.
+func TestOnMalformedMessage(t *testing.T) {
+	t.Parallel()
+
+	var reasons []string
+	client := &Client{
+		config: &ConnConfig{
+			OnMalformedMessage: func(raw []byte, reason string) {
+				reasons = append(reasons, reason)
+			},
+		},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		ntfnState:   newNotificationState(),
+	}
+
+	for _, msg := range []string{
+		`{`,
+		`{"method":"","params":[],"id":null}`,
+		`{"method":"blockconnected","id":null}`,
+		`{"result":1,"error":null,"id":5}`,
+	} {
+		client.handleMessage([]byte(msg))
+	}
+
+	require.Len(t, reasons, 4)
+	require.True(t, strings.HasPrefix(reasons[0], "invalid JSON: "))
+	require.Equal(t, []string{
+		"notification missing method",
+		"notification missing params",
+		"response with unknown id 5",
+	}, reasons[1:])
+}
+
+// TestDetectChainParams ensures the chain parameters of the client are
+// replaced by those of the network reported by the server when requested, and
+// that the configured parameters are kept when detection fails.
+func TestDetectChainParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response string
+		want     *chaincfg.Params
+	}{{
+		name:     "bitcoind regtest",
+		response: `{"result":{"chain":"regtest"},"error":null,"id":1}`,
+		want:     &chaincfg.RegressionNetParams,
+	}, {
+		name:     "bitcoind testnet",
+		response: `{"result":{"chain":"test"},"error":null,"id":1}`,
+		want:     &chaincfg.TestNet3Params,
+	}, {
+		name:     "btcd simnet",
+		response: `{"result":{"chain":"simnet"},"error":null,"id":1}`,
+		want:     &chaincfg.SimNetParams,
+	}, {
+		name:     "unknown network",
+		response: `{"result":{"chain":"foo"},"error":null,"id":1}`,
+		want:     &chaincfg.SigNetParams,
+	}, {
+		name: "detection failure",
+		response: `{"result":null,"error":{"code":-32601,` +
+			`"message":"Method not found"},"id":1}`,
+		want: &chaincfg.SigNetParams,
+	}}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, test.response)
+				},
+			))
+			defer server.Close()
+
+			client, err := New(&ConnConfig{
+				Host: strings.TrimPrefix(
+					server.URL, "http://",
+				),
+				User:              "user",
+				Pass:              "pass",
+				DisableTLS:        true,
+				HTTPPostMode:      true,
+				Params:            chaincfg.SigNetParams.Name,
+				DetectChainParams: true,
+			}, nil)
+			require.NoError(t, err)
+			defer client.Shutdown()
+
+			require.Same(t, test.want, client.chainParams)
+		})
+	}
+}
+
+// TestVerifyNetwork ensures creating a client fails when the server is not
+// running the network of the configured chain parameters and VerifyNetwork is
+// set.
+func TestVerifyNetwork(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response string
+		wantErr  error
+		rpcErr   bool
+	}{{
+		name:     "bitcoind mainnet",
+		response: `{"result":{"chain":"main"},"error":null,"id":1}`,
+	}, {
+		name:     "bitcoind testnet",
+		response: `{"result":{"chain":"test"},"error":null,"id":1}`,
+		wantErr:  ErrWrongNetwork,
+	}, {
+		name:     "unknown network",
+		response: `{"result":{"chain":"foo"},"error":null,"id":1}`,
+		wantErr:  ErrWrongNetwork,
+	}, {
+		name: "verification failure",
+		response: `{"result":null,"error":{"code":-32601,` +
+			`"message":"Method not found"},"id":1}`,
+		rpcErr: true,
+	}}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, test.response)
+				},
+			))
+			defer server.Close()
+
+			client, err := New(&ConnConfig{
+				Host: strings.TrimPrefix(
+					server.URL, "http://",
+				),
+				User:          "user",
+				Pass:          "pass",
+				DisableTLS:    true,
+				HTTPPostMode:  true,
+				VerifyNetwork: true,
+			}, nil)
+			switch {
+			case test.wantErr != nil:
+				require.ErrorIs(t, err, test.wantErr)
+				require.Nil(t, client)
+				return
+
+			case test.rpcErr:
+				var rpcErr *btcjson.RPCError
+				require.ErrorAs(t, err, &rpcErr)
+				require.Nil(t, client)
+				return
+			}
+			require.NoError(t, err)
+			client.Shutdown()
+		})
+	}
+}
+
+// TestRequestDecorator ensures requests are rewritten by the RequestDecorator
+// function before they are sent, and are not sent when it fails.
+func TestRequestDecorator(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies <- body
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	errDecorate := errors.New("decorate")
+	var nonce uint64
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		RequestDecorator: func(method string, id uint64,
+			raw []byte) ([]byte, error) {
+
+			if method != "getblockcount" {
+				return nil, errDecorate
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(raw, &req); err != nil {
+				return nil, err
+			}
+			require.EqualValues(t, id, req["id"])
+			nonce++
+			req["nonce"] = nonce
+			return json.Marshal(req)
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	_, err = client.GetBlockCount()
+	require.NoError(t, err)
+	require.Contains(t, string(<-bodies), `"nonce":1`)
+
+	var buf bytes.Buffer
+	err = client.SendCmdStream(
+		context.Background(), btcjson.NewGetBlockCountCmd(), &buf,
+	)
+	require.NoError(t, err)
+	require.Contains(t, string(<-bodies), `"nonce":2`)
+
+	// A request which fails to be decorated is never sent.
+	_, err = client.GetBestBlockHash()
+	require.ErrorIs(t, err, errDecorate)
+	require.Empty(t, bodies)
+}
+
+// TestDuplicateResponse ensures responses to requests which were already
+// answered are passed to OnDuplicateResponse rather than treated as replies
+// with an unknown id, and disconnect the client when requested.
+func TestDuplicateResponse(t *testing.T) {
+	t.Parallel()
+
+	var duplicates []uint64
+	var malformed []string
+	client := &Client{
+		config: &ConnConfig{
+			OnDuplicateResponse: func(id uint64, raw []byte) {
+				duplicates = append(duplicates, id)
+			},
+			OnMalformedMessage: func(raw []byte, reason string) {
+				malformed = append(malformed, reason)
+			},
+		},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		ntfnState:   newNotificationState(),
+		shutdown:    make(chan struct{}),
+		answeredIDs: newAnsweredIDs(2),
+	}
+	reply := func(id uint64) {
+		client.handleMessage([]byte(fmt.Sprintf(
+			`{"result":1,"error":null,"id":%d}`, id,
+		)))
+	}
+	for id := uint64(1); id <= 3; id++ {
+		require.NoError(t, client.addRequest(&jsonRequest{
+			id:           id,
+			responseChan: make(chan *Response, 1),
+		}))
+		reply(id)
+	}
+
+	// Only the most recently answered ids are remembered, so a late reply
+	// to the first request is reported as having an unknown id.
+	reply(3)
+	reply(2)
+	reply(1)
+	require.Equal(t, []uint64{3, 2}, duplicates)
+	require.Equal(t, []string{"response with unknown id 1"}, malformed)
+
+	// A server which replies twice to each request causes a disconnect
+	// when requested.
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				for i := 0; i < 2; i++ {
+					err := conn.WriteJSON(map[string]interface{}{
+						"result": 1,
+						"error":  nil,
+						"id":     req.ID,
+					})
+					if err != nil {
+						return
+					}
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	wsClient, err := New(&ConnConfig{
+		Host:                          strings.TrimPrefix(server.URL, "http://"),
+		User:                          "user",
+		Pass:                          "pass",
+		DisableTLS:                    true,
+		DisableAutoReconnect:          true,
+		DisconnectOnDuplicateResponse: true,
+	}, nil)
+	require.NoError(t, err)
+	defer wsClient.Shutdown()
+
+	_, err = wsClient.GetBlockCount()
+	require.NoError(t, err)
+	require.Eventually(t, wsClient.Disconnected, time.Second,
+		10*time.Millisecond)
+}
+
+// TestReconnectBootstrap ensures OnReconnectBootstrap is invoked after each
+// automatic reconnect, may issue calls on the client, and causes another
+// reconnect when it fails.
+func TestReconnectBootstrap(t *testing.T) {
+	t.Parallel()
+
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&connections, 1)
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": 1,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	errBootstrap := errors.New("bootstrap failed")
+	bootstraps := make(chan error, 2)
+	var calls int32
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ReconnectBackoff: func(int64) time.Duration {
+			return 10 * time.Millisecond
+		},
+		OnReconnectBootstrap: func(c *Client) error {
+			_, err := c.GetBlockCount()
+			if err == nil && atomic.AddInt32(&calls, 1) == 1 {
+				err = errBootstrap
+			}
+			bootstraps <- err
+			return err
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	client.Disconnect()
+	for _, want := range []error{errBootstrap, nil} {
+		select {
+		case err := <-bootstraps:
+			require.Equal(t, want, err)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for bootstrap")
+		}
+	}
+
+	// The failed bootstrap caused another reconnect.
+	require.EqualValues(t, 3, atomic.LoadInt32(&connections))
+}
+
+// TestRPCErrorData ensures the structured data of error responses is
+// preserved in both HTTP POST and websocket mode.
+func TestRPCErrorData(t *testing.T) {
+	t.Parallel()
+
+	const (
+		data     = `{"reason":"fee too low","minfee":0.0001}`
+		response = `{"result":null,"error":{"code":-26,` +
+			`"message":"rejected","data":` + data + `},"id":1}`
+	)
+	requireData := func(err error) {
+		var rpcErr *btcjson.RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		require.Equal(t, "rejected", rpcErr.Message)
+		require.NotNil(t, rpcErr.Data)
+		require.JSONEq(t, data, string(*rpcErr.Data))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, response)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	_, err = client.GetBlockCount()
+	requireData(err)
+
+	wsClient := &Client{
+		config:      &ConnConfig{},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		ntfnState:   newNotificationState(),
+		shutdown:    make(chan struct{}),
+	}
+	responseChan := make(chan *Response, 1)
+	require.NoError(t, wsClient.addRequest(&jsonRequest{
+		id:           1,
+		responseChan: responseChan,
+	}))
+	wsClient.handleMessage([]byte(response))
+	_, err = ReceiveFuture(responseChan)
+	requireData(err)
+}
+
+// TestResolver ensures the host is resolved with the configured resolver in
+// both websocket and HTTP POST modes.
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	errResolve := errors.New("resolver unavailable")
+	for _, postMode := range []bool{false, true} {
+		postMode := postMode
+		t.Run(fmt.Sprintf("post=%v", postMode), func(t *testing.T) {
+			t.Parallel()
+
+			var lookups int32
+			resolver := &net.Resolver{
+				PreferGo: true,
+				Dial: func(context.Context, string,
+					string) (net.Conn, error) {
+
+					atomic.AddInt32(&lookups, 1)
+					return nil, errResolve
+				},
+			}
+
+			client, err := New(&ConnConfig{
+				Host:                 "node.rpcclient.test:8332",
+				User:                 "user",
+				Pass:                 "pass",
+				DisableTLS:           true,
+				HTTPPostMode:         postMode,
+				DisableAutoReconnect: true,
+				RetryableMethods:     map[string]bool{},
+				Resolver:             resolver,
+			}, nil)
+			if err == nil {
+				defer client.Shutdown()
+				_, err = client.GetBlockCount()
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), errResolve.Error())
+			require.NotZero(t, atomic.LoadInt32(&lookups))
+		})
+	}
+}
+
+// TestFallbackToHTTPPost ensures the client switches to HTTP POST mode when
+// the server does not support websockets and FallbackToHTTPPost is set.
+func TestFallbackToHTTPPost(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Answer websocket handshakes as a plain HTTP server
+			// would.
+			if r.Method != http.MethodPost {
+				return
+			}
+			_, _ = io.ReadAll(r.Body)
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	newConfig := func(fallback bool) *ConnConfig {
+		return &ConnConfig{
+			Host:               strings.TrimPrefix(server.URL, "http://"),
+			User:               "user",
+			Pass:               "pass",
+			DisableTLS:         true,
+			FallbackToHTTPPost: fallback,
+		}
+	}
+	ntfnHandlers := &NotificationHandlers{
+		OnClientConnected: func() {},
+	}
+
+	_, err := New(newConfig(false), ntfnHandlers)
+	require.ErrorIs(t, err, ErrInvalidEndpoint)
+
+	config := newConfig(true)
+	client, err := New(config, ntfnHandlers)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// The client runs in HTTP POST mode without modifying the passed
+	// configuration.
+	require.False(t, config.HTTPPostMode)
+	require.True(t, client.config.HTTPPostMode)
+	require.Nil(t, client.ntfnHandlers)
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+// TestHTTPPostFallbackProxy ensures the proxy is validated for HTTP POST mode
+// when falling back to it.
+func TestHTTPPostFallbackProxy(t *testing.T) {
+	t.Parallel()
+
+	config := &ConnConfig{
+		Host:               "localhost:8334",
+		User:               "user",
+		Pass:               "pass",
+		Proxy:              "127.0.0.1:9050",
+		FallbackToHTTPPost: true,
+	}
+	require.NoError(t, config.Validate())
+
+	// The proxy address accepted by the websocket dialer is not a valid
+	// URL for the HTTP client.
+	_, err := config.httpPostFallback()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid proxy URL")
+	require.False(t, config.HTTPPostMode)
+
+	config.Proxy = "socks5://127.0.0.1:9050"
+	postConfig, err := config.httpPostFallback()
+	require.NoError(t, err)
+	require.True(t, postConfig.HTTPPostMode)
+	require.False(t, config.HTTPPostMode)
+}
+
+// TestRequestTTL ensures requests which are never answered expire with
+// ErrRequestTimeout and are no longer tracked once the RequestTTL elapses.
+func TestRequestTTL(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// Never answer any request.
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ConnName:   "primary",
+		RequestTTL: 50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	future := client.GetBlockCountAsync()
+	require.Equal(t, 1, client.PendingRequestCount())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := future.Receive()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrRequestTimeout)
+		require.True(t, strings.HasPrefix(err.Error(), "primary: "))
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the request to expire")
+	}
+	require.Zero(t, client.PendingRequestCount())
+}
+
+// TestExpireRequestsClock ensures the age of pending requests is measured with
+// the configured clock.
+func TestExpireRequestsClock(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{now: time.Unix(1600000000, 0)}
+	client := &Client{
+		config: &ConnConfig{
+			RequestTTL: time.Minute,
+			Clock:      clock,
+		},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		shutdown:    make(chan struct{}),
+	}
+
+	jReq := &jsonRequest{
+		id:           1,
+		responseChan: make(chan *Response, 1),
+	}
+	require.NoError(t, client.addRequest(jReq))
+
+	clock.advance(time.Minute - time.Second)
+	client.expireRequests(clock.Now())
+	require.Equal(t, 1, client.PendingRequestCount())
+
+	clock.advance(time.Second)
+	client.expireRequests(clock.Now())
+	require.Zero(t, client.PendingRequestCount())
+	require.ErrorIs(t, (<-jReq.responseChan).err, ErrRequestTimeout)
+}
+
+// TestCancelAllRequests ensures every pending websocket request and every
+// queued batch request fails with ErrRequestCanceled, prefixed with the
+// connection name, while the connection stays usable.
+func TestCancelAllRequests(t *testing.T) {
+	t.Parallel()
+
+	// Only answer the requests sent once the pending ones are canceled.
+	var answer int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				if atomic.LoadInt32(&answer) == 0 {
+					continue
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": 1,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ConnName:   "primary",
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	batchClient, err := NewBatch(&ConnConfig{
+		Host:         "127.0.0.1:1",
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		ConnName:     "batch",
+	})
+	require.NoError(t, err)
+	defer batchClient.Shutdown()
+
+	futures := map[string][]FutureGetBlockCountResult{}
+	for i := 0; i < 3; i++ {
+		futures["primary"] = append(
+			futures["primary"], client.GetBlockCountAsync(),
+		)
+		futures["batch"] = append(
+			futures["batch"], batchClient.GetBlockCountAsync(),
+		)
+	}
+	require.Eventually(t, func() bool {
+		return client.PendingRequestCount() == 3
+	}, time.Second, time.Millisecond)
+
+	client.CancelAllRequests()
+	batchClient.CancelAllRequests()
+
+	for name, fs := range futures {
+		for _, future := range fs {
+			_, err := future.Receive()
+			require.ErrorIs(t, err, ErrRequestCanceled)
+			require.True(t, strings.HasPrefix(err.Error(), name+": "))
+		}
+	}
+	require.Zero(t, client.PendingRequestCount())
+
+	// The connection is still usable for new requests.
+	atomic.StoreInt32(&answer, 1)
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+// TestPeekSetID ensures PeekID reports the id of the last request without
+// consuming one and that requests continue from an id seeded with SetID.
+func TestPeekSetID(t *testing.T) {
+	t.Parallel()
+
+	var lastID uint64
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			atomic.StoreUint64(&lastID, uint64(req.ID.(float64)))
+			fmt.Fprintf(w, `{"result":1,"error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Zero(t, client.PeekID())
+	_, err = client.GetBlockCount()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, atomic.LoadUint64(&lastID))
+	require.EqualValues(t, 1, client.PeekID())
+	require.EqualValues(t, 1, client.PeekID())
+
+	client.SetID(41)
+	require.EqualValues(t, 41, client.PeekID())
+	_, err = client.GetBlockCount()
+	require.NoError(t, err)
+	require.EqualValues(t, 42, atomic.LoadUint64(&lastID))
+	require.EqualValues(t, 42, client.PeekID())
+	require.EqualValues(t, 43, client.NextID())
+}
+
+// TestDefaultReconnectBackoff ensures the default reconnect backoff grows
+// linearly with the number of failed attempts up to one minute.
+func TestDefaultReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempt int64
+		backoff time.Duration
+	}{
+		{attempt: 1, backoff: 5 * time.Second},
+		{attempt: 2, backoff: 10 * time.Second},
+		{attempt: 11, backoff: 55 * time.Second},
+		{attempt: 12, backoff: time.Minute},
+		{attempt: 13, backoff: time.Minute},
+		{attempt: 1000, backoff: time.Minute},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.backoff,
+			DefaultReconnectBackoff(test.attempt),
+			"attempt %d", test.attempt)
+	}
+}
+
+// TestReconnectBackoff ensures the ReconnectBackoff function is called with
+// the number of consecutive failed reconnect attempts and that the client
+// waits for the returned duration before the next attempt.
+func TestReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+			}
+		},
+	))
+
+	// Wait for one more attempt than checked, so that the client has
+	// waited after each of the checked attempts.
+	const numAttempts = 4
+	var (
+		mtx      sync.Mutex
+		attempts []int64
+	)
+	clock := &testClock{}
+	done := make(chan struct{})
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		Clock:      clock,
+		ReconnectBackoff: func(attempt int64) time.Duration {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			attempts = append(attempts, attempt)
+			if len(attempts) == numAttempts {
+				close(done)
+			}
+			return time.Duration(attempt) * time.Hour
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// Make every reconnect attempt fail.
+	server.Close()
+	client.Disconnect()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for reconnect attempts")
+	}
+	client.Shutdown()
+
+	mtx.Lock()
+	require.Equal(t, []int64{1, 2, 3}, attempts[:3])
+	mtx.Unlock()
+
+	// Only the reconnect backoffs are waited for in hours.
+	var waits []time.Duration
+	clock.mtx.Lock()
+	for _, wait := range clock.waits {
+		if wait >= time.Hour {
+			waits = append(waits, wait)
+		}
+	}
+	clock.mtx.Unlock()
+	require.Equal(t, []time.Duration{
+		time.Hour, 2 * time.Hour, 3 * time.Hour,
+	}, waits[:3])
+}
+
+// TestConnectedSince ensures ConnectedSince reports the time the current
+// connection was established, which is reset when the client reconnects.
+func TestConnectedSince(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	start := time.Unix(1600000000, 0)
+	clock := &testClock{now: start}
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		Clock:      clock,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	since, ok := client.ConnectedSince()
+	require.True(t, ok)
+	require.Equal(t, start, since)
+
+	// Reconnecting resets the time.
+	events := client.Events()
+	clock.advance(time.Hour)
+	client.Disconnect()
+	for ev := range events {
+		if ev.Type == EventReconnected {
+			break
+		}
+	}
+	since, ok = client.ConnectedSince()
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Hour), since)
+
+	client.Shutdown()
+	_, ok = client.ConnectedSince()
+	require.False(t, ok)
+}
+
+// TestHandshakeTimeout ensures a websocket dial attempt is abandoned once the
+// HandshakeTimeout elapses when the server accepts the connection but never
+// completes the handshake.
+func TestHandshakeTimeout(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// Accept connections without ever answering on them.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	_, err = New(&ConnConfig{
+		Host:             listener.Addr().String(),
+		User:             "user",
+		Pass:             "pass",
+		DisableTLS:       true,
+		HandshakeTimeout: 50 * time.Millisecond,
+	}, nil)
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+}
@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package rpcclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// tcpKeepAlive returns whether TCP keepalive is enabled on the passed
+// connection along with the idle time before the first probe is sent.
+func tcpKeepAlive(t *testing.T, conn net.Conn) (bool, time.Duration) {
+	t.Helper()
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	require.True(t, ok, "not a TCP connection")
+	rawConn, err := tcpConn.SyscallConn()
+	require.NoError(t, err)
+
+	var enabled, idle int
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		enabled, sockErr = syscall.GetsockoptInt(
+			int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE,
+		)
+		if sockErr != nil {
+			return
+		}
+		idle, sockErr = syscall.GetsockoptInt(
+			int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE,
+		)
+	})
+	require.NoError(t, err)
+	require.NoError(t, sockErr)
+
+	return enabled != 0, time.Duration(idle) * time.Second
+}
+
+// TestTCPKeepAlive ensures the TCPKeepAlive option enables keepalive with the
+// configured period on the websocket connection, and disables it when
+// negative.
+func TestTCPKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		keepAlive time.Duration
+		enabled   bool
+	}{{
+		name:      "period",
+		keepAlive: 37 * time.Second,
+		enabled:   true,
+	}, {
+		name:      "disabled",
+		keepAlive: -1,
+		enabled:   false,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(&ConnConfig{
+				Host: strings.TrimPrefix(
+					server.URL, "http://",
+				),
+				User:         "user",
+				Pass:         "pass",
+				DisableTLS:   true,
+				TCPKeepAlive: test.keepAlive,
+			}, nil)
+			require.NoError(t, err)
+			defer client.Shutdown()
+
+			client.mtx.Lock()
+			conn := client.wsConn.UnderlyingConn()
+			client.mtx.Unlock()
+
+			enabled, idle := tcpKeepAlive(t, conn)
+			require.Equal(t, test.enabled, enabled)
+			if test.enabled {
+				require.Equal(t, test.keepAlive, idle)
+			}
+		})
+	}
+}
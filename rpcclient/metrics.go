@@ -0,0 +1,130 @@
+package rpcclient
+
+import (
+	"sync"
+	"time"
+)
+
+// MetricsLatencyBuckets are the upper bounds, in seconds, of the buckets of the
+// request latency histogram reported in Metrics.  They match the default
+// buckets of Prometheus histograms.
+var MetricsLatencyBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// Metrics is a snapshot of the request metrics collected by a client created
+// with the CollectMetrics connection option.
+type Metrics struct {
+	// Requests is the number of requests which have completed.
+	Requests uint64
+
+	// Errors is the number of completed requests which returned an error,
+	// either from the server or the client.
+	Errors uint64
+
+	// InFlight is the number of requests awaiting their response.
+	InFlight int64
+
+	// Reconnects is the number of times the websocket connection has been
+	// re-established.
+	Reconnects uint64
+
+	// LatencyCounts holds the cumulative number of completed requests with
+	// a latency at or below the corresponding bound in
+	// MetricsLatencyBuckets.
+	LatencyCounts []uint64
+
+	// LatencySum is the total latency of all completed requests.
+	LatencySum time.Duration
+}
+
+// clientMetrics collects the request metrics of a client.
+type clientMetrics struct {
+	mtx           sync.Mutex
+	requests      uint64
+	errors        uint64
+	inFlight      int64
+	reconnects    uint64
+	latencyCounts []uint64
+	latencySum    time.Duration
+}
+
+// newClientMetrics returns a new metrics collector.
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		latencyCounts: make([]uint64, len(MetricsLatencyBuckets)),
+	}
+}
+
+// begin records a request being sent and returns the time it was sent.
+func (m *clientMetrics) begin() time.Time {
+	m.mtx.Lock()
+	m.inFlight++
+	m.mtx.Unlock()
+
+	return time.Now()
+}
+
+// end records the completion of a request sent at the passed time.
+func (m *clientMetrics) end(sent time.Time, err error) {
+	latency := time.Since(sent)
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.inFlight--
+	m.requests++
+	if err != nil {
+		m.errors++
+	}
+	m.latencySum += latency
+	for i, bound := range MetricsLatencyBuckets {
+		if latency.Seconds() <= bound {
+			m.latencyCounts[i]++
+		}
+	}
+}
+
+// reconnected records the websocket connection being re-established.
+func (m *clientMetrics) reconnected() {
+	m.mtx.Lock()
+	m.reconnects++
+	m.mtx.Unlock()
+}
+
+// observe records the request whose response will be delivered on the passed
+// channel, returning a channel on which the response is delivered once it has
+// been recorded.
+func (m *clientMetrics) observe(responseChan chan *Response) chan *Response {
+	sent := m.begin()
+	observed := make(chan *Response, 1)
+	go func() {
+		resp := <-responseChan
+		m.end(sent, resp.err)
+		observed <- resp
+	}()
+	return observed
+}
+
+// Metrics returns a snapshot of the request metrics collected by the client.
+// Requests made with SendCmd, which includes the async functions, and
+// CallContext are measured.  It returns nil unless the CollectMetrics
+// connection option is set.
+func (c *Client) Metrics() *Metrics {
+	m := c.metrics
+	if m == nil {
+		return nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return &Metrics{
+		Requests:      m.requests,
+		Errors:        m.errors,
+		InFlight:      m.inFlight,
+		Reconnects:    m.reconnects,
+		LatencyCounts: append([]uint64(nil), m.latencyCounts...),
+		LatencySum:    m.latencySum,
+	}
+}
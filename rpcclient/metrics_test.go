@@ -0,0 +1,63 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetrics ensures the request metrics are collected when enabled.
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			if req.Method == "getblockhash" {
+				fmt.Fprintf(w, `{"result":null,"error":{"code":-8,`+
+					`"message":"out of range"},"id":%v}`,
+					req.ID)
+				return
+			}
+			fmt.Fprintf(w, `{"result":100,"error":null,"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:           strings.TrimPrefix(server.URL, "http://"),
+		User:           "user",
+		Pass:           "pass",
+		DisableTLS:     true,
+		HTTPPostMode:   true,
+		CollectMetrics: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Nil(t, (&Client{config: &ConnConfig{}}).Metrics())
+
+	_, err = client.GetBlockCount()
+	require.NoError(t, err)
+	_, err = client.GetBlockHash(1000)
+	require.Error(t, err)
+
+	metrics := client.Metrics()
+	require.Equal(t, uint64(2), metrics.Requests)
+	require.Equal(t, uint64(1), metrics.Errors)
+	require.Zero(t, metrics.InFlight)
+	require.Len(t, metrics.LatencyCounts, len(MetricsLatencyBuckets))
+	require.Equal(t, uint64(2),
+		metrics.LatencyCounts[len(MetricsLatencyBuckets)-1])
+	require.Positive(t, metrics.LatencySum)
+}
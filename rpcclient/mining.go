@@ -5,6 +5,7 @@
 package rpcclient
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -497,3 +498,44 @@ func (c *Client) GetBlockTemplateAsync(req *btcjson.TemplateRequest) FutureGetBl
 func (c *Client) GetBlockTemplate(req *btcjson.TemplateRequest) (*btcjson.GetBlockTemplateResult, error) {
 	return c.GetBlockTemplateAsync(req).Receive()
 }
+
+// LongPollGetBlockTemplate repeatedly requests block templates using the
+// getblocktemplate long polling extension defined by BIP 22 and invokes the
+// handler with each new template.  The first template is requested
+// immediately, and each following request passes the longpollid of the
+// previous template so the server only replies once the template has changed.
+//
+// The passed request, which may be nil, is used as the basis of every request
+// with its LongPollID field replaced.  Polling continues until the context is
+// done, in which case the context error is returned, the handler returns an
+// error, which is then returned, or a request fails.  An error is also
+// returned if the server does not support long polling.
+func (c *Client) LongPollGetBlockTemplate(ctx context.Context,
+	req *btcjson.TemplateRequest,
+	handler func(*btcjson.GetBlockTemplateResult) error) error {
+
+	var templateReq btcjson.TemplateRequest
+	if req != nil {
+		templateReq = *req
+	}
+	templateReq.LongPollID = ""
+
+	for {
+		var template btcjson.GetBlockTemplateResult
+		cmd := btcjson.NewGetBlockTemplateCmd(&templateReq)
+		err := c.CallContext(ctx, cmd, &template)
+		if err != nil {
+			return err
+		}
+
+		if err := handler(&template); err != nil {
+			return err
+		}
+
+		if template.LongPollID == "" {
+			return errors.New("server does not support long " +
+				"polling for block templates")
+		}
+		templateReq.LongPollID = template.LongPollID
+	}
+}
@@ -0,0 +1,71 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLongPollGetBlockTemplate ensures each template request after the first
+// passes the longpollid of the previous template and that polling stops once
+// the context is canceled.
+func TestLongPollGetBlockTemplate(t *testing.T) {
+	t.Parallel()
+
+	longPollIDs := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ID     uint64                    `json:"id"`
+				Params []btcjson.TemplateRequest `json:"params"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+			require.Len(t, req.Params, 1)
+			longPollIDs <- req.Params[0].LongPollID
+
+			height := len(longPollIDs)
+			fmt.Fprintf(w, `{"result":{"height":%d,"longpollid":`+
+				`"lp%d"},"error":null,"id":%d}`, height, height,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var heights []int64
+	err = client.LongPollGetBlockTemplate(ctx, &btcjson.TemplateRequest{
+		Rules: []string{"segwit"},
+	}, func(template *btcjson.GetBlockTemplateResult) error {
+		heights = append(heights, template.Height)
+		if len(heights) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int64{1, 2, 3}, heights)
+
+	require.Equal(t, "", <-longPollIDs)
+	require.Equal(t, "lp1", <-longPollIDs)
+	require.Equal(t, "lp2", <-longPollIDs)
+}
@@ -7,10 +7,13 @@ package rpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
@@ -90,6 +93,13 @@ type NotificationHandlers struct {
 	// notification handlers, and is safe for blocking client requests.
 	OnClientConnected func()
 
+	// OnClientDisconnected is invoked when the websocket connection to the
+	// RPC server is lost with the close code and text sent by the server,
+	// such as 1013 (try again later), or 1006 (abnormal closure) and a
+	// description of the error when the connection was lost without a
+	// close message.  It is not invoked when the client is shut down.
+	OnClientDisconnected func(code int, text string)
+
 	// OnBlockConnected is invoked when a block is connected to the longest
 	// (best) chain.  It will only be invoked if a preceding call to
 	// NotifyBlocks has been made to register for the notification and the
@@ -98,6 +108,15 @@ type NotificationHandlers struct {
 	// Deprecated: Use OnFilteredBlockConnected instead.
 	OnBlockConnected func(hash *chainhash.Hash, height int32, t time.Time)
 
+	// OnBlockConnectedCoalesced is invoked in place of OnBlockConnected
+	// when the CoalesceBlockNotifications connection option is set.  It is
+	// passed the most recently connected block along with the number of
+	// block connected notifications which were skipped because they
+	// arrived while the previous invocation was still running.  When it is
+	// nil, OnBlockConnected is invoked with the most recent block instead.
+	OnBlockConnectedCoalesced func(hash *chainhash.Hash, height int32,
+		t time.Time, skipped int)
+
 	// OnFilteredBlockConnected is invoked when a block is connected to the
 	// longest (best) chain.  It will only be invoked if a preceding call to
 	// NotifyBlocks has been made to register for the notification and the
@@ -179,6 +198,16 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnTxRejected is invoked when a transaction submitted by this client
+	// with SendRawTransaction is rejected by the server's memory pool.  It
+	// receives the hash of the rejected transaction and the reason given by
+	// the server.  Since backends do not send notifications for rejected
+	// transactions, the client notifies the rejection itself when the
+	// reply is received, so no registration is required, and it is the
+	// only handler which may be set in HTTP POST mode.  Accepted
+	// transactions are notified via OnTxAccepted and OnTxAcceptedVerbose.
+	OnTxRejected func(hash *chainhash.Hash, reason string)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// btcd.
 	//
@@ -198,6 +227,11 @@ type NotificationHandlers struct {
 	// server such as btcwallet.
 	OnWalletLockState func(locked bool)
 
+	// OnNotificationError is invoked when the server pushes an error which
+	// is not associated with any request, in the form of a message with an
+	// error object and a null id, but no method.
+	OnNotificationError func(err *btcjson.RPCError)
+
 	// OnUnknownNotification is invoked when an unrecognized notification
 	// is received.  This typically means the notification handling code
 	// for this package needs to be updated for a new notification type or
@@ -206,6 +240,227 @@ type NotificationHandlers struct {
 	OnUnknownNotification func(method string, params []json.RawMessage)
 }
 
+// RecordedNotification is a notification recorded in the notification history
+// enabled with the NotificationHistorySize connection option.
+type RecordedNotification struct {
+	// Method is the method of the notification.
+	Method string
+
+	// Params are the raw parameters of the notification.
+	Params []json.RawMessage
+
+	// Received is the time the notification was received.
+	Received time.Time
+}
+
+// coalescedBlock is the most recent block connected notification awaiting
+// delivery when block connected notifications are coalesced.
+type coalescedBlock struct {
+	hash    *chainhash.Hash
+	height  int32
+	t       time.Time
+	skipped int
+}
+
+// coalesceBlockConnected records the passed block as the most recently
+// connected one, replacing any block which has not been delivered yet, and
+// signals the block connected handler.
+func (c *Client) coalesceBlockConnected(hash *chainhash.Hash, height int32,
+	t time.Time) {
+
+	c.coalescedBlockMtx.Lock()
+	var skipped int
+	if pending := c.coalescedBlock; pending != nil {
+		skipped = pending.skipped + 1
+	}
+	c.coalescedBlock = &coalescedBlock{
+		hash:    hash,
+		height:  height,
+		t:       t,
+		skipped: skipped,
+	}
+	c.coalescedBlockMtx.Unlock()
+
+	select {
+	case c.coalescedBlockSignal <- struct{}{}:
+	default:
+	}
+}
+
+// blockConnectedHandler delivers coalesced block connected notifications to
+// the notification handlers, always passing the most recently connected block.
+// It must be run as a goroutine.
+func (c *Client) blockConnectedHandler() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.coalescedBlockSignal:
+		case <-c.shutdown:
+			return
+		}
+
+		c.coalescedBlockMtx.Lock()
+		block := c.coalescedBlock
+		c.coalescedBlock = nil
+		c.coalescedBlockMtx.Unlock()
+		if block == nil {
+			continue
+		}
+
+		if c.ntfnHandlers.OnBlockConnectedCoalesced != nil {
+			c.ntfnHandlers.OnBlockConnectedCoalesced(block.hash,
+				block.height, block.t, block.skipped)
+		} else {
+			c.ntfnHandlers.OnBlockConnected(block.hash,
+				block.height, block.t)
+		}
+	}
+}
+
+// notificationHistory is a bounded ring buffer of the most recently received
+// notifications.
+type notificationHistory struct {
+	mtx    sync.Mutex
+	ntfns  []RecordedNotification
+	next   int
+	filled bool
+}
+
+// newNotificationHistory returns a notification history which records up to
+// size notifications.
+func newNotificationHistory(size int) *notificationHistory {
+	return &notificationHistory{
+		ntfns: make([]RecordedNotification, size),
+	}
+}
+
+// recordNotification adds the passed notification to the notification
+// history, replacing the oldest one when it is full.  It has no effect when the
+// history is not enabled.
+func (c *Client) recordNotification(ntfn *rawNotification) {
+	h := c.ntfnHistory
+	if h == nil {
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.ntfns[h.next] = RecordedNotification{
+		Method:   ntfn.Method,
+		Params:   ntfn.Params,
+		Received: time.Now(),
+	}
+	h.next = (h.next + 1) % len(h.ntfns)
+	if h.next == 0 {
+		h.filled = true
+	}
+}
+
+// RecentNotifications returns the most recently received notifications, oldest
+// first, up to the number set by the NotificationHistorySize connection option.
+// Notifications are recorded when they are received, so the history also
+// includes notifications which were dropped while paused.  It returns nil when
+// the history is not enabled.
+func (c *Client) RecentNotifications() []RecordedNotification {
+	h := c.ntfnHistory
+	if h == nil {
+		return nil
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if !h.filled {
+		return append([]RecordedNotification(nil), h.ntfns[:h.next]...)
+	}
+	ntfns := make([]RecordedNotification, 0, len(h.ntfns))
+	ntfns = append(ntfns, h.ntfns[h.next:]...)
+	return append(ntfns, h.ntfns[:h.next]...)
+}
+
+// PauseNotifications stops the delivery of notifications to the notification
+// handlers without unregistering them with the server, which avoids the cost
+// of re-registering for them later.  Notifications received while paused are
+// dropped unless the BufferPausedNotifications connection option is set, in
+// which case they are delivered once ResumeNotifications is called.
+//
+// This function is safe for concurrent access.
+func (c *Client) PauseNotifications() {
+	c.ntfnPauseMtx.Lock()
+	c.ntfnPaused = true
+	c.ntfnPauseMtx.Unlock()
+}
+
+// ResumeNotifications resumes the delivery of notifications paused with
+// PauseNotifications.  Any notifications buffered while paused are delivered,
+// in the order they were received, from the calling goroutine before it
+// returns and before any newly received notifications.
+func (c *Client) ResumeNotifications() {
+	for {
+		c.ntfnPauseMtx.Lock()
+		ntfns := c.pausedNtfns
+		c.pausedNtfns = nil
+		if len(ntfns) == 0 {
+			c.ntfnPaused = false
+			c.ntfnPauseMtx.Unlock()
+			return
+		}
+		c.ntfnPauseMtx.Unlock()
+
+		// Notifications received while delivering the buffered ones
+		// are buffered as well, so they are delivered on the next
+		// iteration.
+		for _, ntfn := range ntfns {
+			c.handleNotification(ntfn)
+		}
+	}
+}
+
+// dispatchNotification delivers the passed notification to the notification
+// handlers, unless notification delivery is paused, in which case the
+// notification is either buffered or dropped.
+func (c *Client) dispatchNotification(ntfn *rawNotification) {
+	c.ntfnPauseMtx.Lock()
+	if c.ntfnPaused {
+		if c.config.BufferPausedNotifications {
+			c.pausedNtfns = append(c.pausedNtfns, ntfn)
+		}
+		c.ntfnPauseMtx.Unlock()
+		return
+	}
+	c.ntfnPauseMtx.Unlock()
+
+	c.handleNotification(ntfn)
+}
+
+// deliverNotification dispatches the passed notification, handing it off to
+// the notification handler goroutine when asynchronous notifications are
+// enabled.
+func (c *Client) deliverNotification(ntfn *rawNotification) {
+	if c.ntfnQueue != nil {
+		select {
+		case c.ntfnQueue <- ntfn:
+		case <-c.shutdown:
+		}
+		return
+	}
+	c.dispatchNotification(ntfn)
+}
+
+// handleNotificationError delivers an error pushed by the server which is not
+// associated with any request to the OnNotificationError handler, or logs it
+// when there is no such handler.
+func (c *Client) handleNotificationError(err *btcjson.RPCError) {
+	if c.ntfnHandlers == nil || c.ntfnHandlers.OnNotificationError == nil {
+		log.Warnf("Received error notification: %v", err)
+		return
+	}
+
+	c.ntfnHandlers.OnNotificationError(err)
+}
+
 // handleNotification examines the passed notification type, performs
 // conversions to get the raw notification types into higher level types and
 // delivers the notification to the appropriate On<X> handler registered with
@@ -222,7 +477,10 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 	case btcjson.BlockConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnBlockConnected == nil {
+		coalesce := c.coalescedBlockSignal != nil
+		if c.ntfnHandlers.OnBlockConnected == nil && (!coalesce ||
+			c.ntfnHandlers.OnBlockConnectedCoalesced == nil) {
+
 			return
 		}
 
@@ -233,13 +491,20 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
+		if coalesce {
+			c.coalesceBlockConnected(blockHash, blockHeight, blockTime)
+			return
+		}
 		c.ntfnHandlers.OnBlockConnected(blockHash, blockHeight, blockTime)
 
 	// OnFilteredBlockConnected
 	case btcjson.FilteredBlockConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnFilteredBlockConnected == nil {
+		blockHandlers := c.blockHandlers()
+		if c.ntfnHandlers.OnFilteredBlockConnected == nil &&
+			len(blockHandlers) == 0 {
+
 			return
 		}
 
@@ -251,8 +516,13 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnFilteredBlockConnected(blockHeight,
-			blockHeader, transactions)
+		if c.ntfnHandlers.OnFilteredBlockConnected != nil {
+			c.ntfnHandlers.OnFilteredBlockConnected(blockHeight,
+				blockHeader, transactions)
+		}
+		for _, handler := range blockHandlers {
+			handler(blockHeight, blockHeader, transactions)
+		}
 
 	// OnBlockDisconnected
 	case btcjson.BlockDisconnectedNtfnMethod:
@@ -294,7 +564,8 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 	case btcjson.RecvTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRecvTx == nil {
+		watchers := c.addressWatchers()
+		if c.ntfnHandlers.OnRecvTx == nil && len(watchers) == 0 {
 			return
 		}
 
@@ -305,13 +576,17 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRecvTx(tx, block)
+		if c.ntfnHandlers.OnRecvTx != nil {
+			c.ntfnHandlers.OnRecvTx(tx, block)
+		}
+		c.notifyAddressWatchers(watchers, tx, block, true)
 
 	// OnRedeemingTx
 	case btcjson.RedeemingTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRedeemingTx == nil {
+		watchers := c.addressWatchers()
+		if c.ntfnHandlers.OnRedeemingTx == nil && len(watchers) == 0 {
 			return
 		}
 
@@ -322,7 +597,10 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRedeemingTx(tx, block)
+		if c.ntfnHandlers.OnRedeemingTx != nil {
+			c.ntfnHandlers.OnRedeemingTx(tx, block)
+		}
+		c.notifyAddressWatchers(watchers, tx, block, false)
 
 	// OnRelevantTxAccepted
 	case btcjson.RelevantTxAcceptedNtfnMethod:
@@ -343,12 +621,6 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 	// OnRescanFinished
 	case btcjson.RescanFinishedNtfnMethod:
-		// Ignore the notification if the client is not interested in
-		// it.
-		if c.ntfnHandlers.OnRescanFinished == nil {
-			return
-		}
-
 		hash, height, blkTime, err := parseRescanProgressParams(ntfn.Params)
 		if err != nil {
 			log.Warnf("Received invalid rescanfinished "+
@@ -356,16 +628,20 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRescanFinished(hash, height, blkTime)
+		// Track the rescan state regardless of whether the client is
+		// interested in the notification.
+		c.updateRescanProgress(hash, height, blkTime, true)
 
-	// OnRescanProgress
-	case btcjson.RescanProgressNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRescanProgress == nil {
+		if c.ntfnHandlers.OnRescanFinished == nil {
 			return
 		}
 
+		c.ntfnHandlers.OnRescanFinished(hash, height, blkTime)
+
+	// OnRescanProgress
+	case btcjson.RescanProgressNtfnMethod:
 		hash, height, blkTime, err := parseRescanProgressParams(ntfn.Params)
 		if err != nil {
 			log.Warnf("Received invalid rescanprogress "+
@@ -373,6 +649,17 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
+		// Track the rescan state regardless of whether the client is
+		// interested in the notification so an interrupted rescan can
+		// be resumed on reconnect.
+		c.updateRescanProgress(hash, height, blkTime, false)
+
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnRescanProgress == nil {
+			return
+		}
+
 		c.ntfnHandlers.OnRescanProgress(hash, height, blkTime)
 
 	// OnTxAccepted
@@ -392,6 +679,23 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAccepted(hash, amt)
 
+	// OnTxRejected
+	case txRejectedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnTxRejected == nil {
+			return
+		}
+
+		hash, reason, err := parseTxRejectedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid tx rejected "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnTxRejected(hash, reason)
+
 	// OnTxAcceptedVerbose
 	case btcjson.TxAcceptedVerboseNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -472,6 +776,123 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 	}
 }
 
+// txRejectedNtfnMethod is the method of the notifications generated by the
+// client itself for the transactions rejected by the server, which are
+// delivered to the OnTxRejected handler the same way as those sent by the
+// server.
+const txRejectedNtfnMethod = "rpcclient.txrejected"
+
+// notifyTxRejected delivers a notification to the OnTxRejected handler when
+// the passed request is a sendrawtransaction request whose transaction was
+// rejected by the memory pool of the server with the passed error.  Other
+// errors, such as a malformed transaction or a failure to reach the server,
+// are not rejections and are ignored.
+func (c *Client) notifyTxRejected(jReq *jsonRequest, rejectErr error) {
+	if c.ntfnHandlers == nil || c.ntfnHandlers.OnTxRejected == nil {
+		return
+	}
+
+	sendCmd, ok := jReq.cmd.(*btcjson.SendRawTransactionCmd)
+	if !ok {
+		return
+	}
+
+	var rpcErr *btcjson.RPCError
+	if !errors.As(rejectErr, &rpcErr) {
+		return
+	}
+	switch rpcErr.Code {
+	case btcjson.ErrRPCVerify, btcjson.ErrRPCVerifyRejected:
+	default:
+		return
+	}
+
+	serializedTx, err := hex.DecodeString(sendCmd.HexTx)
+	if err != nil {
+		log.Warnf("Unable to decode rejected transaction: %v", err)
+		return
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		log.Warnf("Unable to deserialize rejected transaction: %v", err)
+		return
+	}
+
+	params := make([]json.RawMessage, 0, 2)
+	for _, param := range []string{msgTx.TxHash().String(), rpcErr.Message} {
+		marshalled, err := json.Marshal(param)
+		if err != nil {
+			log.Warnf("Unable to marshal rejected transaction: %v",
+				err)
+			return
+		}
+		params = append(params, marshalled)
+	}
+	c.deliverNotification(&rawNotification{
+		Method: txRejectedNtfnMethod,
+		Params: params,
+	})
+}
+
+// BlockHandler is a callback invoked with the height, header, and relevant
+// transactions of a block connected to the longest (best) chain.
+type BlockHandler func(height int32, header *wire.BlockHeader, txs []*btcutil.Tx)
+
+// AddBlockHandler registers an additional handler to be invoked whenever a
+// filtered block connected notification is received, alongside the
+// OnFilteredBlockConnected notification handler, if any.  This allows several
+// independent subsystems to consume block notifications from a single client.
+// The returned function unregisters the handler and is safe to call more than
+// once.
+//
+// Handlers are invoked in the order they were registered.  As with the other
+// notification handlers, they must not directly call any blocking calls on the
+// client instance.
+//
+// NOTE: Notifications are only delivered when the client was created with
+// non-nil notification handlers and NotifyBlocks has been called to register
+// for them.
+func (c *Client) AddBlockHandler(handler BlockHandler) (remove func()) {
+	c.blockHandlersMtx.Lock()
+	defer c.blockHandlersMtx.Unlock()
+
+	if c.blockHandlerMap == nil {
+		c.blockHandlerMap = make(map[uint64]BlockHandler)
+	}
+	id := c.nextBlockHandlerID
+	c.nextBlockHandlerID++
+	c.blockHandlerMap[id] = handler
+
+	return func() {
+		c.blockHandlersMtx.Lock()
+		delete(c.blockHandlerMap, id)
+		c.blockHandlersMtx.Unlock()
+	}
+}
+
+// blockHandlers returns the currently registered block handlers in the order
+// they were registered.
+func (c *Client) blockHandlers() []BlockHandler {
+	c.blockHandlersMtx.Lock()
+	defer c.blockHandlersMtx.Unlock()
+
+	if len(c.blockHandlerMap) == 0 {
+		return nil
+	}
+
+	ids := make([]uint64, 0, len(c.blockHandlerMap))
+	for id := range c.blockHandlerMap {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	handlers := make([]BlockHandler, 0, len(ids))
+	for _, id := range ids {
+		handlers = append(handlers, c.blockHandlerMap[id])
+	}
+	return handlers
+}
+
 // wrongNumParams is an error type describing an unparsable JSON-RPC
 // notification due to an incorrect number of parameters for the
 // expected notification type.  The value is the number of parameters
@@ -753,6 +1174,32 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 	return txHash, amt, nil
 }
 
+// parseTxRejectedNtfnParams parses out the transaction hash and the reason
+// given by the server from the parameters of a notification generated by the
+// client for a rejected transaction.
+func parseTxRejectedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
+	string, error) {
+
+	if len(params) != 2 {
+		return nil, "", wrongNumParams(len(params))
+	}
+
+	var txHashStr, reason string
+	if err := json.Unmarshal(params[0], &txHashStr); err != nil {
+		return nil, "", err
+	}
+	if err := json.Unmarshal(params[1], &reason); err != nil {
+		return nil, "", err
+	}
+
+	txHash, err := chainhash.NewHashFromStr(txHashStr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return txHash, reason, nil
+}
+
 // parseTxAcceptedVerboseNtfnParams parses out details about a raw transaction
 // from the parameters of a txacceptedverbose notification.
 func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawResult,
@@ -902,6 +1349,168 @@ func (c *Client) NotifyBlocks() error {
 	return c.NotifyBlocksAsync().Receive()
 }
 
+// WaitForBlockHeight blocks until a block at or above the passed height has
+// been connected to the main chain or the context is done, in which case the
+// context's error is returned.  Block notifications are registered for if they
+// have not been already, and the current block count is checked once they are
+// so a height that has already been reached returns immediately.
+//
+// NOTE: This relies on the filteredblockconnected notification, which is a
+// btcd extension, and requires a websocket connection and non-nil notification
+// handlers.
+func (c *Client) WaitForBlockHeight(ctx context.Context, height int64) error {
+	if c.config.HTTPPostMode {
+		return ErrWebsocketsRequired
+	}
+	if c.ntfnHandlers == nil {
+		return errors.New("notification handlers are required to wait " +
+			"for a block height")
+	}
+
+	// Register the handler before checking the current block count so a
+	// block connected in between is not missed.
+	reached := make(chan struct{})
+	var once sync.Once
+	remove := c.AddBlockHandler(func(blockHeight int32, _ *wire.BlockHeader,
+		_ []*btcutil.Tx) {
+
+		if int64(blockHeight) >= height {
+			once.Do(func() { close(reached) })
+		}
+	})
+	defer remove()
+
+	c.ntfnStateLock.Lock()
+	notifyBlocks := c.ntfnState.notifyBlocks
+	c.ntfnStateLock.Unlock()
+	if !notifyBlocks {
+		if err := c.NotifyBlocks(); err != nil {
+			return err
+		}
+	}
+
+	count, err := c.GetBlockCount()
+	if err != nil {
+		return err
+	}
+	if count >= height {
+		return nil
+	}
+
+	select {
+	case <-reached:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BlockEvent describes a block connected to the main chain, as delivered by
+// the channels returned by BlockChannel.
+type BlockEvent struct {
+	// Height is the height of the block.
+	Height int32
+
+	// Header is the header of the block.
+	Header *wire.BlockHeader
+
+	// Txs holds the transactions of the block which are relevant to the
+	// transaction filter loaded by the client, if any.
+	Txs []*btcutil.Tx
+}
+
+// BlockChannel returns a channel on which the blocks connected to the main
+// chain are delivered, as an alternative to the notification handlers.  Block
+// notifications are registered for if they have not been already.  The
+// channel is closed once the context is done or the client is shut down.
+//
+// The channel buffers the number of events set by the BlockChannelSize
+// connection option.  When a slow consumer lets the buffer fill up, further
+// events are dropped and a warning is logged unless BlockChannelBlocking is
+// set, in which case the delivery of all notifications waits until the
+// consumer receives from the channel.
+//
+// NOTE: This relies on the filteredblockconnected notification, which is a
+// btcd extension, and requires a websocket connection and non-nil notification
+// handlers.
+func (c *Client) BlockChannel(ctx context.Context) (<-chan *BlockEvent, error) {
+	if c.config.HTTPPostMode {
+		return nil, ErrWebsocketsRequired
+	}
+	if c.ntfnHandlers == nil {
+		return nil, errors.New("notification handlers are required for " +
+			"a block channel")
+	}
+
+	size := c.config.BlockChannelSize
+	if size <= 0 {
+		size = defaultBlockChannelSize
+	}
+	blocks := make(chan *BlockEvent, size)
+
+	// The mutex ensures the handler, which may still be running after it
+	// has been removed, does not send on the channel once it is closed,
+	// while stop releases a handler waiting for the consumer.
+	var (
+		mtx    sync.Mutex
+		closed bool
+		stop   = make(chan struct{})
+	)
+	remove := c.AddBlockHandler(func(height int32, header *wire.BlockHeader,
+		txs []*btcutil.Tx) {
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if closed {
+			return
+		}
+		event := &BlockEvent{Height: height, Header: header, Txs: txs}
+		if c.config.BlockChannelBlocking {
+			select {
+			case blocks <- event:
+			case <-stop:
+			}
+			return
+		}
+		select {
+		case blocks <- event:
+		default:
+			log.Warnf("Block channel is full, dropping block at "+
+				"height %d", height)
+		}
+	})
+	closeBlocks := func() {
+		remove()
+		close(stop)
+
+		mtx.Lock()
+		closed = true
+		close(blocks)
+		mtx.Unlock()
+	}
+
+	c.ntfnStateLock.Lock()
+	notifyBlocks := c.ntfnState.notifyBlocks
+	c.ntfnStateLock.Unlock()
+	if !notifyBlocks {
+		if err := c.NotifyBlocks(); err != nil {
+			closeBlocks()
+			return nil, err
+		}
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.shutdown:
+		}
+		closeBlocks()
+	}()
+
+	return blocks, nil
+}
+
 // FutureNotifySpentResult is a future promise to deliver the result of a
 // NotifySpentAsync RPC invocation (or an applicable error).
 //
@@ -1142,11 +1751,10 @@ func (r FutureRescanResult) Receive() error {
 //
 // See Rescan for the blocking version and more details.
 //
-// NOTE: Rescan requests are not issued on client reconnect and must be
-// performed manually (ideally with a new start height based on the last
-// rescan progress notification).  See the OnClientConnected notification
-// callback for a good callsite to reissue rescan requests on connect and
-// reconnect.
+// NOTE: Rescan requests which have reported progress are automatically resumed
+// from the last rescanned block on client reconnect.  Rescans interrupted
+// before any progress was reported are not reissued and must be performed
+// manually.  See RescanProgress for the last progress reported by the server.
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 //
@@ -1185,6 +1793,7 @@ func (c *Client) RescanAsync(startBlock *chainhash.Hash,
 	}
 
 	cmd := btcjson.NewRescanCmd(startBlockHashStr, addrs, ops, nil)
+	c.resetRescanProgress()
 	return c.SendCmd(cmd)
 }
 
@@ -1207,11 +1816,10 @@ func (c *Client) RescanAsync(startBlock *chainhash.Hash,
 // See RescanEndBlock to also specify an ending block to finish the rescan
 // without continuing through the best block on the main chain.
 //
-// NOTE: Rescan requests are not issued on client reconnect and must be
-// performed manually (ideally with a new start height based on the last
-// rescan progress notification).  See the OnClientConnected notification
-// callback for a good callsite to reissue rescan requests on connect and
-// reconnect.
+// NOTE: Rescan requests which have reported progress are automatically resumed
+// from the last rescanned block on client reconnect.  Rescans interrupted
+// before any progress was reported are not reissued and must be performed
+// manually.  See RescanProgress for the last progress reported by the server.
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 //
@@ -1270,6 +1878,7 @@ func (c *Client) RescanEndBlockAsync(startBlock *chainhash.Hash,
 
 	cmd := btcjson.NewRescanCmd(startBlockHashStr, addrs, ops,
 		&endBlockHashStr)
+	c.resetRescanProgress()
 	return c.SendCmd(cmd)
 }
 
@@ -1302,6 +1911,117 @@ func (c *Client) RescanEndHeight(startBlock *chainhash.Hash,
 		endBlock).Receive()
 }
 
+// RescanProgress describes the most recent progress reported by the server for
+// a rescan started with Rescan or RescanEndHeight.
+type RescanProgress struct {
+	// Hash is the hash of the last block processed by the rescan.
+	Hash *chainhash.Hash
+
+	// Height is the height of the last block processed by the rescan.
+	Height int32
+
+	// Time is the timestamp of the last block processed by the rescan.
+	Time time.Time
+
+	// Finished indicates the server has signaled the rescan is complete.
+	Finished bool
+}
+
+// RescanProgress returns the most recent progress reported for the current
+// rescan, or nil if no progress has been reported since the last rescan was
+// started.
+//
+// NOTE: The server does not identify which rescan a progress notification
+// belongs to, so the reported progress is only meaningful when a single rescan
+// is running at a time.
+func (c *Client) RescanProgress() *RescanProgress {
+	c.ntfnStateLock.Lock()
+	defer c.ntfnStateLock.Unlock()
+
+	if c.rescanProgress == nil {
+		return nil
+	}
+	progress := *c.rescanProgress
+	return &progress
+}
+
+// updateRescanProgress records the progress reported by a rescanprogress or
+// rescanfinished notification.
+func (c *Client) updateRescanProgress(hash *chainhash.Hash, height int32,
+	blkTime time.Time, finished bool) {
+
+	c.ntfnStateLock.Lock()
+	c.rescanProgress = &RescanProgress{
+		Hash:     hash,
+		Height:   height,
+		Time:     blkTime,
+		Finished: finished,
+	}
+	c.ntfnStateLock.Unlock()
+}
+
+// resetRescanProgress clears the tracked rescan progress.  It is called when a
+// new rescan is started.
+func (c *Client) resetRescanProgress() {
+	c.ntfnStateLock.Lock()
+	c.rescanProgress = nil
+	c.ntfnStateLock.Unlock()
+}
+
+// rescanResumable returns whether the passed request is a rescan which has
+// reported progress without finishing, and so may be resumed on reconnect with
+// resumeRescanRequest rather than being dropped.
+func (c *Client) rescanResumable(jReq *jsonRequest) bool {
+	if _, ok := jReq.cmd.(*btcjson.RescanCmd); !ok {
+		return false
+	}
+
+	progress := c.RescanProgress()
+	return progress != nil && !progress.Finished
+}
+
+// resumeRescanRequest updates the passed resumable rescan request to begin at
+// the block following the last one reported by a rescan progress notification
+// so that it may be reissued on reconnect without starting over or processing
+// that block again.  The request is marshalled the same way as a new request
+// with its original id.
+//
+// It requests the header of the last processed block to find the next one, so
+// it must not be called with the request lock held.
+func (c *Client) resumeRescanRequest(jReq *jsonRequest) error {
+	cmd := jReq.cmd.(*btcjson.RescanCmd)
+	progress := c.RescanProgress()
+	if progress == nil || progress.Finished {
+		return errors.New("no rescan progress to resume from")
+	}
+
+	header, err := c.GetBlockHeaderVerbose(progress.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Begin at the last processed block itself when no block follows it
+	// yet, since the rescan may not be resumed past the best block.
+	resumeCmd := *cmd
+	resumeCmd.BeginBlock = progress.Hash.String()
+	if header.NextHash != "" {
+		resumeCmd.BeginBlock = header.NextHash
+	}
+
+	marshalledJSON, err := c.marshalCmdRequest(
+		btcjson.RpcVersion1, jReq.id, jReq.method, &resumeCmd,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Resuming rescan from block %v after block %v (height %d)",
+		resumeCmd.BeginBlock, progress.Hash, progress.Height)
+	jReq.cmd = &resumeCmd
+	jReq.marshalledJSON = marshalledJSON
+	return nil
+}
+
 // FutureLoadTxFilterResult is a future promise to deliver the result
 // of a LoadTxFilterAsync RPC invocation (or an applicable error).
 //
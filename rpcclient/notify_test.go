@@ -0,0 +1,931 @@
+package rpcclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRescanResumable ensures a pending rescan request is only considered
+// resumable once a rescan progress notification has been received and until
+// the rescan is finished.
+func TestRescanResumable(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:       &ConnConfig{},
+		ntfnHandlers: &NotificationHandlers{},
+		ntfnState:    newNotificationState(),
+	}
+
+	cmd := btcjson.NewRescanCmd("00", []string{"addr"}, nil, nil)
+	jReq := &jsonRequest{
+		id:     7,
+		method: "rescan",
+		cmd:    cmd,
+	}
+
+	// Without any progress the request must not be resumed.
+	require.False(t, client.rescanResumable(jReq))
+
+	// Deliver a progress notification through the notification handler.
+	hash := chainhash.Hash{0x01}
+	params := []json.RawMessage{
+		json.RawMessage(`"` + hash.String() + `"`),
+		json.RawMessage(`100`),
+		json.RawMessage(`1600000000`),
+	}
+	client.handleNotification(&rawNotification{
+		Method: btcjson.RescanProgressNtfnMethod,
+		Params: params,
+	})
+
+	progress := client.RescanProgress()
+	require.NotNil(t, progress)
+	require.Equal(t, int32(100), progress.Height)
+	require.False(t, progress.Finished)
+	require.True(t, client.rescanResumable(jReq))
+
+	// Other requests are never resumed.
+	require.False(t, client.rescanResumable(&jsonRequest{
+		method: "getblockcount",
+		cmd:    btcjson.NewGetBlockCountCmd(),
+	}))
+
+	// Once the rescan is finished it is no longer resumed.
+	client.handleNotification(&rawNotification{
+		Method: btcjson.RescanFinishedNtfnMethod,
+		Params: params,
+	})
+	require.True(t, client.RescanProgress().Finished)
+	require.False(t, client.rescanResumable(jReq))
+}
+
+// TestResumeRescanReconnect ensures a rescan interrupted by a lost connection
+// is resent on reconnect with its original id, beginning after the last block
+// it reported, and marshalled like a new request.
+func TestResumeRescanReconnect(t *testing.T) {
+	t.Parallel()
+
+	var (
+		lastHash = chainhash.Hash{0x01}
+		nextHash = chainhash.Hash{0x02}
+	)
+
+	type rescanReq struct {
+		ID         uint64
+		BeginBlock string
+		Decorated  bool
+	}
+	rescans := make(chan rescanReq, 2)
+
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			first := atomic.AddInt32(&connections, 1) == 1
+
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var req btcjson.Request
+				require.NoError(t, json.Unmarshal(msg, &req))
+				var decoration struct {
+					Decorated bool `json:"decorated"`
+				}
+				require.NoError(t, json.Unmarshal(msg, &decoration))
+
+				var result interface{}
+				switch req.Method {
+				case "rescan":
+					var begin string
+					err := json.Unmarshal(req.Params[0], &begin)
+					require.NoError(t, err)
+					rescans <- rescanReq{
+						ID:         uint64(req.ID.(float64)),
+						BeginBlock: begin,
+						Decorated:  decoration.Decorated,
+					}
+
+					// Report progress on the first connection
+					// and drop it before the rescan finishes.
+					if first {
+						ntfn := map[string]interface{}{
+							"method": btcjson.RescanProgressNtfnMethod,
+							"params": []interface{}{
+								lastHash.String(), 100,
+								1600000000,
+							},
+						}
+						_ = conn.WriteJSON(ntfn)
+						time.Sleep(10 * time.Millisecond)
+						return
+					}
+
+				case "getblockheader":
+					var hash string
+					err := json.Unmarshal(req.Params[0], &hash)
+					require.NoError(t, err)
+					require.Equal(t, lastHash.String(), hash)
+					result = map[string]interface{}{
+						"hash":          lastHash.String(),
+						"height":        100,
+						"nextblockhash": nextHash.String(),
+					}
+				}
+
+				err = conn.WriteJSON(map[string]interface{}{
+					"result": result,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	var validated int32
+	client, err := New(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+		ReconnectBackoff: func(int64) time.Duration {
+			return 10 * time.Millisecond
+		},
+		ValidateRequest: func(method string, _ []byte) error {
+			if method == "rescan" {
+				atomic.AddInt32(&validated, 1)
+			}
+			return nil
+		},
+		RequestDecorator: func(method string, _ uint64,
+			marshalled []byte) ([]byte, error) {
+
+			var req map[string]interface{}
+			if err := json.Unmarshal(marshalled, &req); err != nil {
+				return nil, err
+			}
+			req["decorated"] = true
+			return json.Marshal(req)
+		},
+	}, &NotificationHandlers{})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	future := client.RescanAsync(&chainhash.Hash{}, nil, nil)
+	require.NoError(t, future.Receive())
+
+	first, resumed := <-rescans, <-rescans
+	require.True(t, first.Decorated)
+	require.Equal(t, (&chainhash.Hash{}).String(), first.BeginBlock)
+
+	require.Equal(t, first.ID, resumed.ID)
+	require.True(t, resumed.Decorated)
+	require.Equal(t, nextHash.String(), resumed.BeginBlock)
+	require.EqualValues(t, 2, atomic.LoadInt32(&validated))
+	require.EqualValues(t, 2, atomic.LoadInt32(&connections))
+}
+
+// makeFilteredBlockConnectedNtfn returns a filtered block connected
+// notification for an empty block at the passed height.
+func makeFilteredBlockConnectedNtfn(t *testing.T, height int32) *rawNotification {
+	var header wire.BlockHeader
+	var buf bytes.Buffer
+	require.NoError(t, header.Serialize(&buf))
+
+	heightJSON, err := json.Marshal(height)
+	require.NoError(t, err)
+
+	return &rawNotification{
+		Method: btcjson.FilteredBlockConnectedNtfnMethod,
+		Params: []json.RawMessage{
+			heightJSON,
+			json.RawMessage(`"` + hex.EncodeToString(buf.Bytes()) + `"`),
+			json.RawMessage(`[]`),
+		},
+	}
+}
+
+// TestAddBlockHandler ensures block handlers registered with AddBlockHandler
+// are invoked alongside the configured notification handler and stop being
+// invoked once removed.
+func TestAddBlockHandler(t *testing.T) {
+	t.Parallel()
+
+	var configured, first, second []int32
+	client := &Client{
+		config: &ConnConfig{},
+		ntfnHandlers: &NotificationHandlers{
+			OnFilteredBlockConnected: func(height int32,
+				_ *wire.BlockHeader, _ []*btcutil.Tx) {
+
+				configured = append(configured, height)
+			},
+		},
+		ntfnState: newNotificationState(),
+	}
+
+	removeFirst := client.AddBlockHandler(func(height int32,
+		_ *wire.BlockHeader, _ []*btcutil.Tx) {
+
+		first = append(first, height)
+	})
+	client.AddBlockHandler(func(height int32, _ *wire.BlockHeader,
+		_ []*btcutil.Tx) {
+
+		second = append(second, height)
+	})
+
+	client.handleNotification(makeFilteredBlockConnectedNtfn(t, 1))
+	removeFirst()
+	removeFirst()
+	client.handleNotification(makeFilteredBlockConnectedNtfn(t, 2))
+
+	require.Equal(t, []int32{1, 2}, configured)
+	require.Equal(t, []int32{1}, first)
+	require.Equal(t, []int32{1, 2}, second)
+}
+
+// TestNotifyTxRejected ensures the OnTxRejected handler is invoked with the
+// hash and reason of a sendrawtransaction request rejected by the memory pool
+// of the server, and not for other errors.
+func TestNotifyTxRejected(t *testing.T) {
+	t.Parallel()
+
+	var (
+		rejectedHash   *chainhash.Hash
+		rejectedReason string
+	)
+	client := &Client{
+		config: &ConnConfig{},
+		ntfnHandlers: &NotificationHandlers{
+			OnTxRejected: func(hash *chainhash.Hash, reason string) {
+				rejectedHash = hash
+				rejectedReason = reason
+			},
+		},
+		ntfnState: newNotificationState(),
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	jReq := &jsonRequest{
+		method: "sendrawtransaction",
+		cmd: btcjson.NewSendRawTransactionCmd(
+			hex.EncodeToString(buf.Bytes()), nil,
+		),
+	}
+	client.notifyTxRejected(jReq, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCVerify,
+		Message: "bad-txns-inputs-missingorspent",
+	})
+
+	expectedHash := tx.TxHash()
+	require.Equal(t, &expectedHash, rejectedHash)
+	require.Equal(t, "bad-txns-inputs-missingorspent", rejectedReason)
+
+	// Errors which are not rejections by the memory pool must not invoke
+	// the handler.
+	rejectedHash = nil
+	client.notifyTxRejected(jReq, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCDeserialization,
+		Message: "TX decode failed",
+	})
+	client.notifyTxRejected(jReq, ErrClientDisconnect)
+	require.Nil(t, rejectedHash)
+
+	// Other commands must not invoke the handler.
+	client.notifyTxRejected(&jsonRequest{
+		method: "getblockcount",
+		cmd:    btcjson.NewGetBlockCountCmd(),
+	}, &btcjson.RPCError{Code: btcjson.ErrRPCVerifyRejected})
+	require.Nil(t, rejectedHash)
+}
+
+// TestTxRejectedHTTPPost ensures the OnTxRejected handler may be set in HTTP
+// POST mode, where it is invoked from the notification handler goroutine when
+// asynchronous notifications are enabled.
+func TestTxRejectedHTTPPost(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			fmt.Fprintf(w, `{"result":null,"error":{"code":-26,`+
+				`"message":"min relay fee not met"},"id":%v}`,
+				req.ID)
+		},
+	))
+	defer server.Close()
+
+	type rejection struct {
+		hash   chainhash.Hash
+		reason string
+	}
+	rejections := make(chan rejection, 1)
+	release := make(chan struct{})
+	client, err := New(&ConnConfig{
+		Host:               strings.TrimPrefix(server.URL, "http://"),
+		User:               "user",
+		Pass:               "pass",
+		DisableTLS:         true,
+		HTTPPostMode:       true,
+		AsyncNotifications: true,
+		RetryableMethods:   map[string]bool{},
+	}, &NotificationHandlers{
+		OnTxRejected: func(hash *chainhash.Hash, reason string) {
+			<-release
+			rejections <- rejection{*hash, reason}
+		},
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+	client.backendVersion = BtcdPost2401
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	// The error is returned while the handler is still blocked.
+	_, err = client.SendRawTransaction(tx, false)
+	var rpcErr *btcjson.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, btcjson.ErrRPCVerifyRejected, rpcErr.Code)
+
+	close(release)
+	select {
+	case r := <-rejections:
+		require.Equal(t, tx.TxHash(), r.hash)
+		require.Equal(t, "min relay fee not met", r.reason)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the rejection")
+	}
+
+	// Handlers for notifications sent by the server are still rejected
+	// in HTTP POST mode.
+	_, err = New(&ConnConfig{
+		Host:         "localhost:8334",
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+	}, &NotificationHandlers{
+		OnTxRejected: func(*chainhash.Hash, string) {},
+		OnTxAccepted: func(*chainhash.Hash, btcutil.Amount) {},
+	})
+	require.Error(t, err)
+}
+
+// TestAsyncNotifications ensures notifications are dispatched from the
+// notification handler goroutine when asynchronous notifications are enabled,
+// so a blocked handler does not delay the delivery of responses.
+func TestAsyncNotifications(t *testing.T) {
+	t.Parallel()
+
+	connected := make(chan int32)
+	release := make(chan struct{})
+	client := &Client{
+		config:      &ConnConfig{},
+		requestMap:  make(map[uint64]*list.Element),
+		requestList: list.New(),
+		ntfnHandlers: &NotificationHandlers{
+			OnBlockConnected: func(_ *chainhash.Hash, height int32,
+				_ time.Time) {
+
+				connected <- height
+				<-release
+			},
+		},
+		ntfnState: newNotificationState(),
+		ntfnQueue: make(chan *rawNotification, 1),
+		shutdown:  make(chan struct{}),
+	}
+	client.wg.Add(1)
+	go client.ntfnHandler()
+	defer func() {
+		close(release)
+		close(client.shutdown)
+		client.wg.Wait()
+	}()
+
+	// Block the handler with the first notification.
+	client.handleMessage([]byte(`{"jsonrpc":"1.0","method":` +
+		`"blockconnected","params":["00",1,0],"id":null}`))
+	require.Equal(t, int32(1), <-connected)
+
+	// A response must still be delivered while the handler is blocked.
+	responseChan := make(chan *Response, 1)
+	require.NoError(t, client.addRequest(&jsonRequest{
+		id:           1,
+		method:       "getblockcount",
+		responseChan: responseChan,
+	}))
+	client.handleMessage([]byte(`{"result":100,"error":null,"id":1}`))
+	resp := <-responseChan
+	require.NoError(t, resp.err)
+	require.Equal(t, []byte("100"), resp.result)
+
+	// Notifications are handled in the order they were received.
+	client.handleMessage([]byte(`{"jsonrpc":"1.0","method":` +
+		`"blockconnected","params":["00",2,0],"id":null}`))
+	release <- struct{}{}
+	require.Equal(t, int32(2), <-connected)
+}
+
+// TestAsyncNotificationsReconnect ensures asynchronous notifications are still
+// dispatched one at a time in the order they were received after the client
+// has reconnected.
+func TestAsyncNotificationsReconnect(t *testing.T) {
+	t.Parallel()
+
+	const numNtfns = 50
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			// Send the notifications once the client has
+			// reconnected twice.
+			if atomic.AddInt32(&connections, 1) == 3 {
+				for i := 1; i <= numNtfns; i++ {
+					ntfn := fmt.Sprintf(`{"jsonrpc":"1.0",`+
+						`"method":"blockconnected",`+
+						`"params":["00",%d,0],"id":null}`, i)
+					err := conn.WriteMessage(
+						websocket.TextMessage, []byte(ntfn),
+					)
+					if err != nil {
+						return
+					}
+				}
+			}
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	var active, maxActive int32
+	heights := make(chan int32, numNtfns)
+	client, err := New(&ConnConfig{
+		Host:               strings.TrimPrefix(server.URL, "http://"),
+		User:               "user",
+		Pass:               "pass",
+		DisableTLS:         true,
+		AsyncNotifications: true,
+		ReconnectBackoff: func(int64) time.Duration {
+			return 10 * time.Millisecond
+		},
+	}, &NotificationHandlers{
+		OnBlockConnected: func(_ *chainhash.Hash, height int32,
+			_ time.Time) {
+
+			n := atomic.AddInt32(&active, 1)
+			if n > atomic.LoadInt32(&maxActive) {
+				atomic.StoreInt32(&maxActive, n)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			heights <- height
+		},
+	})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// Force two reconnects.
+	events := client.Events()
+	for i := 0; i < 2; i++ {
+		client.Disconnect()
+		for reconnected := false; !reconnected; {
+			select {
+			case ev := <-events:
+				reconnected = ev.Type == EventReconnected
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for reconnect")
+			}
+		}
+	}
+
+	for i := int32(1); i <= numNtfns; i++ {
+		select {
+		case height := <-heights:
+			require.Equal(t, i, height)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for notification")
+		}
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&maxActive))
+}
+
+// TestDrainNotificationsOnShutdown ensures notifications which are still
+// queued at shutdown are dispatched when DrainNotificationsOnShutdown is set.
+func TestDrainNotificationsOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	var heights []int32
+	client := &Client{
+		config: &ConnConfig{DrainNotificationsOnShutdown: true},
+		ntfnHandlers: &NotificationHandlers{
+			OnBlockConnected: func(_ *chainhash.Hash, height int32,
+				_ time.Time) {
+
+				heights = append(heights, height)
+			},
+		},
+		ntfnState: newNotificationState(),
+		ntfnQueue: make(chan *rawNotification, 3),
+		shutdown:  make(chan struct{}),
+	}
+	for height := 1; height <= 3; height++ {
+		client.handleMessage([]byte(fmt.Sprintf(`{"jsonrpc":"1.0",`+
+			`"method":"blockconnected","params":["00",%d,0],`+
+			`"id":null}`, height)))
+	}
+
+	close(client.shutdown)
+	client.wg.Add(1)
+	go client.ntfnHandler()
+	client.wg.Wait()
+
+	require.Equal(t, []int32{1, 2, 3}, heights)
+}
+
+// TestPauseNotifications ensures notifications received while paused are
+// dropped or buffered depending on the configuration, and buffered ones are
+// delivered in order on resume.
+func TestPauseNotifications(t *testing.T) {
+	t.Parallel()
+
+	for _, buffer := range []bool{false, true} {
+		var heights []int32
+		client := &Client{
+			config: &ConnConfig{
+				BufferPausedNotifications: buffer,
+			},
+			ntfnHandlers: &NotificationHandlers{
+				OnBlockConnected: func(_ *chainhash.Hash,
+					height int32, _ time.Time) {
+
+					heights = append(heights, height)
+				},
+			},
+			ntfnState: newNotificationState(),
+		}
+
+		blockConnected := func(height int) {
+			client.handleMessage([]byte(fmt.Sprintf(`{"jsonrpc":`+
+				`"1.0","method":"blockconnected","params":`+
+				`["00",%d,0],"id":null}`, height)))
+		}
+
+		blockConnected(1)
+		client.PauseNotifications()
+		blockConnected(2)
+		blockConnected(3)
+		require.Equal(t, []int32{1}, heights)
+
+		client.ResumeNotifications()
+		blockConnected(4)
+		if buffer {
+			require.Equal(t, []int32{1, 2, 3, 4}, heights)
+		} else {
+			require.Equal(t, []int32{1, 4}, heights)
+		}
+	}
+}
+
+// TestNotificationError ensures an error pushed by the server without a method
+// is delivered to the OnNotificationError handler.
+func TestNotificationError(t *testing.T) {
+	t.Parallel()
+
+	var errs []*btcjson.RPCError
+	client := &Client{
+		config: &ConnConfig{},
+		ntfnHandlers: &NotificationHandlers{
+			OnNotificationError: func(err *btcjson.RPCError) {
+				errs = append(errs, err)
+			},
+		},
+		ntfnState: newNotificationState(),
+	}
+
+	client.handleMessage([]byte(`{"jsonrpc":"2.0","error":{"code":-32603,` +
+		`"message":"internal error"},"id":null}`))
+	require.Len(t, errs, 1)
+	require.Equal(t, btcjson.ErrRPCInternal.Code, errs[0].Code)
+	require.Equal(t, "internal error", errs[0].Message)
+
+	// Notifications without a method or error are still malformed.
+	client.handleMessage([]byte(`{"jsonrpc":"2.0","params":[],"id":null}`))
+	require.Len(t, errs, 1)
+}
+
+// TestRecentNotifications ensures the notification history records the most
+// recently received notifications, oldest first, up to its size.
+func TestRecentNotifications(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		config:      &ConnConfig{},
+		ntfnHistory: newNotificationHistory(3),
+	}
+	require.Nil(t, (&Client{}).RecentNotifications())
+	require.Empty(t, client.RecentNotifications())
+
+	methods := func() []string {
+		var methods []string
+		for _, ntfn := range client.RecentNotifications() {
+			methods = append(methods, ntfn.Method)
+		}
+		return methods
+	}
+
+	for i := 1; i <= 5; i++ {
+		client.handleMessage([]byte(fmt.Sprintf(`{"jsonrpc":"1.0",`+
+			`"method":"ntfn%d","params":[],"id":null}`, i)))
+
+		if i == 2 {
+			require.Equal(t, []string{"ntfn1", "ntfn2"}, methods())
+		}
+	}
+	require.Equal(t, []string{"ntfn3", "ntfn4", "ntfn5"}, methods())
+}
+
+// TestWaitForBlockHeight ensures WaitForBlockHeight registers for block
+// notifications, returns once a block at the target height is connected or
+// immediately when the height has already been reached, and honors the
+// context.
+func TestWaitForBlockHeight(t *testing.T) {
+	t.Parallel()
+
+	var header bytes.Buffer
+	require.NoError(t, (&wire.BlockHeader{}).Serialize(&header))
+	headerHex := hex.EncodeToString(header.Bytes())
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+
+				var result interface{}
+				if req.Method == "getblockcount" {
+					result = 5
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": result,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+				if req.Method != "getblockcount" {
+					continue
+				}
+
+				for height := 6; height <= 7; height++ {
+					err := conn.WriteJSON(map[string]interface{}{
+						"jsonrpc": "1.0",
+						"method":  "filteredblockconnected",
+						"params": []interface{}{
+							height, headerHex, []string{},
+						},
+						"id": nil,
+					})
+					if err != nil {
+						return
+					}
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, &NotificationHandlers{})
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitForBlockHeight(ctx, 7))
+
+	// Block notifications are now registered.
+	client.ntfnStateLock.Lock()
+	require.True(t, client.ntfnState.notifyBlocks)
+	client.ntfnStateLock.Unlock()
+
+	// A height that has already been reached returns immediately.
+	require.NoError(t, client.WaitForBlockHeight(ctx, 3))
+
+	// A height that is never reached returns the context error.
+	shortCtx, shortCancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer shortCancel()
+	err = client.WaitForBlockHeight(shortCtx, 100)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// HTTP POST clients are rejected.
+	postClient := &Client{config: &ConnConfig{HTTPPostMode: true}}
+	err = postClient.WaitForBlockHeight(ctx, 1)
+	require.ErrorIs(t, err, ErrWebsocketsRequired)
+}
+
+// TestCoalesceBlockNotifications ensures block connected notifications which
+// arrive while the handler is busy are collapsed into a single invocation with
+// the most recent block and the number of blocks skipped.
+func TestCoalesceBlockNotifications(t *testing.T) {
+	t.Parallel()
+
+	type coalesced struct {
+		height  int32
+		skipped int
+	}
+	calls := make(chan coalesced, 10)
+	release := make(chan struct{})
+	client := &Client{
+		config: &ConnConfig{CoalesceBlockNotifications: true},
+		ntfnHandlers: &NotificationHandlers{
+			OnBlockConnectedCoalesced: func(_ *chainhash.Hash,
+				height int32, _ time.Time, skipped int) {
+
+				calls <- coalesced{height, skipped}
+				<-release
+			},
+		},
+		ntfnState:            newNotificationState(),
+		shutdown:             make(chan struct{}),
+		coalescedBlockSignal: make(chan struct{}, 1),
+	}
+	client.wg.Add(1)
+	go client.blockConnectedHandler()
+	defer client.wg.Wait()
+	defer close(client.shutdown)
+
+	blockConnected := func(height int) {
+		client.handleMessage([]byte(fmt.Sprintf(`{"jsonrpc":"1.0",`+
+			`"method":"blockconnected","params":["00",%d,0],`+
+			`"id":null}`, height)))
+	}
+
+	// The first block is delivered while the following ones arrive.
+	blockConnected(1)
+	require.Equal(t, coalesced{1, 0}, <-calls)
+	for height := 2; height <= 5; height++ {
+		blockConnected(height)
+	}
+	release <- struct{}{}
+
+	// Only the most recent of them is delivered once the handler returns.
+	require.Equal(t, coalesced{5, 3}, <-calls)
+	release <- struct{}{}
+
+	select {
+	case call := <-calls:
+		t.Fatalf("unexpected call %v", call)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestBlockChannel ensures connected blocks are delivered on the channel
+// returned by BlockChannel, that events are dropped or block delivery when the
+// buffer is full depending on the configuration, and that the channel is
+// closed once the context is done or the client is shut down.
+func TestBlockChannel(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(blocking bool) *Client {
+		client := &Client{
+			config: &ConnConfig{
+				BlockChannelSize:     1,
+				BlockChannelBlocking: blocking,
+			},
+			ntfnHandlers: &NotificationHandlers{},
+			ntfnState:    newNotificationState(),
+			shutdown:     make(chan struct{}),
+		}
+		client.ntfnState.notifyBlocks = true
+		return client
+	}
+	connectBlock := func(client *Client, height int32) {
+		for _, handler := range client.blockHandlers() {
+			handler(height, &wire.BlockHeader{}, nil)
+		}
+	}
+	requireClosed := func(blocks <-chan *BlockEvent) {
+		select {
+		case _, ok := <-blocks:
+			require.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("block channel was not closed")
+		}
+	}
+
+	// Blocks which do not fit in the buffer are dropped by default.
+	client := newClient(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	blocks, err := client.BlockChannel(ctx)
+	require.NoError(t, err)
+	connectBlock(client, 1)
+	connectBlock(client, 2)
+	require.EqualValues(t, 1, (<-blocks).Height)
+	select {
+	case event := <-blocks:
+		t.Fatalf("unexpected block at height %d", event.Height)
+	default:
+	}
+
+	// The channel is closed once the context is done, after which the
+	// handler is removed.
+	cancel()
+	requireClosed(blocks)
+	require.Empty(t, client.blockHandlers())
+
+	// Delivery waits for the consumer when blocking is requested.
+	client = newClient(true)
+	blocks, err = client.BlockChannel(context.Background())
+	require.NoError(t, err)
+	go func() {
+		for height := int32(1); height <= 3; height++ {
+			connectBlock(client, height)
+		}
+	}()
+	for height := int32(1); height <= 3; height++ {
+		require.Equal(t, height, (<-blocks).Height)
+	}
+
+	// A handler waiting for the consumer is released on shutdown.
+	done := make(chan struct{})
+	go func() {
+		connectBlock(client, 4)
+		connectBlock(client, 5)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(client.shutdown)
+	<-done
+	require.EqualValues(t, 4, (<-blocks).Height)
+	requireClosed(blocks)
+
+	// HTTP POST clients are rejected.
+	postClient := &Client{config: &ConnConfig{HTTPPostMode: true}}
+	_, err = postClient.BlockChannel(context.Background())
+	require.ErrorIs(t, err, ErrWebsocketsRequired)
+}
@@ -0,0 +1,116 @@
+package rpcclient
+
+import (
+	"time"
+
+	"github.com/btcsuite/websocket"
+)
+
+const (
+	// pingWriteTimeout is the amount of time allowed to write a websocket
+	// ping frame before the write is abandoned.
+	pingWriteTimeout = 10 * time.Second
+)
+
+// pingHandler periodically sends a websocket ping frame on the current
+// connection at the passed interval so that the connection is kept active and
+// a dead connection is detected by the failed write.  It must be run as a
+// goroutine and exits once quit is closed or the connection is lost.
+func (c *Client) pingHandler(interval time.Duration, quit <-chan struct{}) {
+	defer c.wg.Done()
+
+	c.mtx.Lock()
+	conn, disconnect := c.wsConn, c.disconnect
+	connected := conn != nil && !c.disconnected
+	c.mtx.Unlock()
+	if !connected {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		case <-disconnect:
+			return
+		}
+
+		deadline := time.Now().Add(pingWriteTimeout)
+		err := conn.WriteControl(websocket.PingMessage, nil, deadline)
+		if err != nil {
+			// Disconnect unless the connection was already lost.
+			select {
+			case <-disconnect:
+			default:
+				log.Warnf("Unable to send ping to %s, "+
+					"disconnecting: %v", c.config.Host, err)
+				c.recordError(ErrorSourceTransport, err)
+				c.disconnectWithError(err)
+			}
+			return
+		}
+	}
+}
+
+// startPingHandler starts sending websocket pings on the current connection at
+// the current ping interval, stopping any pings already being sent.  Nothing
+// is sent when the interval is zero.  It must be called with the ping mutex
+// held.
+func (c *Client) startPingHandler() {
+	if c.pingQuit != nil {
+		close(c.pingQuit)
+		c.pingQuit = nil
+	}
+	if c.pingInterval <= 0 {
+		return
+	}
+
+	c.pingQuit = make(chan struct{})
+	c.wg.Add(1)
+	go c.pingHandler(c.pingInterval, c.pingQuit)
+}
+
+// PingInterval returns the interval at which websocket ping frames are sent to
+// keep the connection alive.  Zero means no pings are sent.
+func (c *Client) PingInterval() time.Duration {
+	c.pingMtx.Lock()
+	defer c.pingMtx.Unlock()
+
+	return c.pingInterval
+}
+
+// SetPingInterval changes the interval at which websocket ping frames are sent
+// to keep the connection alive, which is initially set by the PingInterval
+// connection option.  The new interval takes effect immediately without
+// reconnecting, and also applies to later connections.  A zero or negative
+// interval disables the pings.  This allows tuning the keepalive according to
+// the observed stability of the connection.
+//
+// This function has no effect when the client is running in HTTP POST mode or
+// has been shut down.
+//
+// This function is safe for concurrent access.
+func (c *Client) SetPingInterval(d time.Duration) {
+	if c.config.HTTPPostMode {
+		return
+	}
+	if d < 0 {
+		d = 0
+	}
+
+	c.pingMtx.Lock()
+	defer c.pingMtx.Unlock()
+
+	select {
+	case <-c.shutdown:
+		return
+	default:
+	}
+
+	c.pingInterval = d
+	c.startPingHandler()
+}
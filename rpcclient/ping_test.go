@@ -0,0 +1,82 @@
+package rpcclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetPingInterval ensures websocket pings are sent at the configured
+// interval and that changing the interval at runtime takes effect without
+// reconnecting.
+func TestSetPingInterval(t *testing.T) {
+	t.Parallel()
+
+	var pings, connections int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&connections, 1)
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			conn.SetPingHandler(func(string) error {
+				atomic.AddInt32(&pings, 1)
+				return nil
+			})
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				err := conn.WriteJSON(map[string]interface{}{
+					"result": 1,
+					"error":  nil,
+					"id":     req.ID,
+				})
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		PingInterval: 10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	require.Equal(t, 10*time.Millisecond, client.PingInterval())
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&pings) >= 2
+	}, time.Second, time.Millisecond)
+
+	// Disabling the pings stops them.
+	client.SetPingInterval(0)
+	require.Zero(t, client.PingInterval())
+	time.Sleep(20 * time.Millisecond)
+	stopped := atomic.LoadInt32(&pings)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stopped, atomic.LoadInt32(&pings))
+
+	// Enabling them again resumes them on the same connection.
+	client.SetPingInterval(5 * time.Millisecond)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&pings) >= stopped+2
+	}, time.Second, time.Millisecond)
+	require.EqualValues(t, 1, atomic.LoadInt32(&connections))
+}
@@ -0,0 +1,104 @@
+package rpcclient
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Pool maintains a fixed number of clients connected to the same RPC server
+// and spreads requests across them in a round-robin fashion.  This raises the
+// throughput ceiling of a single connection for workloads which issue many
+// concurrent requests and do not need notifications.
+//
+// Each client of the pool reconnects independently as configured, so a single
+// dropped connection only affects the requests which were sent through it.
+type Pool struct {
+	clients []*Client
+	next    uint32
+}
+
+// NewPool creates a pool of size clients based on the provided connection
+// configuration details.  Each client is created with its own copy of the
+// configuration and without notification handlers, and is connected before
+// NewPool returns unless DisableConnectOnNew is set.
+func NewPool(config *ConnConfig, size int) (*Pool, error) {
+	if size <= 0 {
+		return nil, errors.New("pool size must be positive")
+	}
+
+	pool := &Pool{clients: make([]*Client, 0, size)}
+	for i := 0; i < size; i++ {
+		clientConfig := *config
+		client, err := New(&clientConfig, nil)
+		if err != nil {
+			pool.Shutdown()
+			return nil, err
+		}
+		pool.clients = append(pool.clients, client)
+	}
+
+	return pool, nil
+}
+
+// Client returns the next client of the pool in round-robin order, preferring
+// connected clients, so any of the RPC methods may be called through the pool,
+// for example pool.Client().GetBlockCount().  A disconnected client is only
+// returned when none of the clients are connected.
+func (p *Pool) Client() *Client {
+	// The index is computed in unsigned arithmetic so it does not become
+	// negative once the counter overflows an int on 32-bit platforms.
+	start := atomic.AddUint32(&p.next, 1)
+	size := uint32(len(p.clients))
+	for i := uint32(0); i < size; i++ {
+		client := p.clients[(start+i)%size]
+		if client.IsConnected() {
+			return client
+		}
+	}
+	return p.clients[start%size]
+}
+
+// SendCmd sends the passed command through the next client of the pool and
+// returns a response channel on which the reply will be delivered, in the same
+// manner as Client.SendCmd.
+func (p *Pool) SendCmd(cmd interface{}) chan *Response {
+	return p.Client().SendCmd(cmd)
+}
+
+// Size returns the number of clients in the pool.
+func (p *Pool) Size() int {
+	return len(p.clients)
+}
+
+// Connected returns the number of clients of the pool which are currently
+// connected to the server.
+func (p *Pool) Connected() int {
+	var connected int
+	for _, client := range p.clients {
+		if client.IsConnected() {
+			connected++
+		}
+	}
+	return connected
+}
+
+// Healthy returns whether at least one client of the pool is currently
+// connected to the server, so requests can still be served.
+func (p *Pool) Healthy() bool {
+	return p.Connected() > 0
+}
+
+// Shutdown shuts down all of the clients of the pool.
+func (p *Pool) Shutdown() {
+	for _, client := range p.clients {
+		client.Shutdown()
+	}
+}
+
+// WaitForShutdown blocks until all of the clients of the pool have been shut
+// down.
+func (p *Pool) WaitForShutdown() {
+	for _, client := range p.clients {
+		client.WaitForShutdown()
+	}
+}
@@ -0,0 +1,117 @@
+package rpcclient
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPool ensures a pool connects the requested number of clients and spreads
+// requests across them.
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	var mtx sync.Mutex
+	var connections int
+	requests := make(map[int]int)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			mtx.Lock()
+			connections++
+			connID := connections
+			mtx.Unlock()
+
+			for {
+				var req btcjson.Request
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				mtx.Lock()
+				requests[connID]++
+				mtx.Unlock()
+
+				reply := fmt.Sprintf(`{"result":5,"error":null,`+
+					`"id":%v}`, req.ID)
+				err := conn.WriteMessage(
+					websocket.TextMessage, []byte(reply),
+				)
+				if err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	_, err := NewPool(&ConnConfig{}, 0)
+	require.Error(t, err)
+
+	pool, err := NewPool(&ConnConfig{
+		Host:                 strings.TrimPrefix(server.URL, "http://"),
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, 3)
+	require.NoError(t, err)
+	defer pool.Shutdown()
+
+	require.Equal(t, 3, pool.Size())
+	require.Equal(t, 3, pool.Connected())
+	require.True(t, pool.Healthy())
+
+	for i := 0; i < 6; i++ {
+		count, err := pool.Client().GetBlockCount()
+		require.NoError(t, err)
+		require.Equal(t, int64(5), count)
+	}
+
+	mtx.Lock()
+	require.Equal(t, 3, connections)
+	require.Equal(t, map[int]int{1: 2, 2: 2, 3: 2}, requests)
+	mtx.Unlock()
+
+	// Requests are only sent through connected clients.
+	pool.clients[0].Shutdown()
+	require.Equal(t, 2, pool.Connected())
+	for i := 0; i < 4; i++ {
+		res, err := ReceiveFuture(
+			pool.SendCmd(btcjson.NewGetBlockCountCmd()),
+		)
+		require.NoError(t, err)
+		require.Equal(t, "5", string(res))
+	}
+
+	pool.Shutdown()
+	pool.WaitForShutdown()
+	require.False(t, pool.Healthy())
+}
+
+// TestPoolClientOverflow ensures the clients are still picked in round-robin
+// order when the counter of the pool wraps around.
+func TestPoolClientOverflow(t *testing.T) {
+	t.Parallel()
+
+	pool := &Pool{
+		clients: []*Client{{}, {}, {}},
+		next:    math.MaxUint32 - 2,
+	}
+	starts := []uint32{math.MaxUint32 - 1, math.MaxUint32, 0, 1}
+	for _, start := range starts {
+		require.Same(t, pool.clients[start%3], pool.Client())
+	}
+}
@@ -0,0 +1,91 @@
+// Package promcollector exports the request metrics collected by an rpcclient
+// client to Prometheus.
+//
+// It is a separate module so that the Prometheus dependency is only required
+// by applications which use it.
+package promcollector
+
+import (
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collector exports the request metrics of a client to Prometheus.
+type collector struct {
+	client *rpcclient.Client
+
+	requests   *prometheus.Desc
+	errors     *prometheus.Desc
+	inFlight   *prometheus.Desc
+	reconnects *prometheus.Desc
+	latency    *prometheus.Desc
+}
+
+// Describe sends the descriptors of the exported metrics to the passed
+// channel.
+//
+// This is part of the prometheus.Collector interface.
+func (m *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.requests
+	ch <- m.errors
+	ch <- m.inFlight
+	ch <- m.reconnects
+	ch <- m.latency
+}
+
+// Collect sends the current values of the exported metrics to the passed
+// channel.
+//
+// This is part of the prometheus.Collector interface.
+func (m *collector) Collect(ch chan<- prometheus.Metric) {
+	metrics := m.client.Metrics()
+	if metrics == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(m.requests,
+		prometheus.CounterValue, float64(metrics.Requests))
+	ch <- prometheus.MustNewConstMetric(m.errors,
+		prometheus.CounterValue, float64(metrics.Errors))
+	ch <- prometheus.MustNewConstMetric(m.inFlight,
+		prometheus.GaugeValue, float64(metrics.InFlight))
+	ch <- prometheus.MustNewConstMetric(m.reconnects,
+		prometheus.CounterValue, float64(metrics.Reconnects))
+
+	buckets := make(map[float64]uint64, len(rpcclient.MetricsLatencyBuckets))
+	for i, bound := range rpcclient.MetricsLatencyBuckets {
+		buckets[bound] = metrics.LatencyCounts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(m.latency, metrics.Requests,
+		metrics.LatencySum.Seconds(), buckets)
+}
+
+// New returns a collector which exports the request metrics of the passed
+// client to Prometheus, with the passed constant labels, such as the host of
+// the RPC server, attached to each metric.  The client must be created with
+// the CollectMetrics connection option, otherwise nothing is collected.
+func New(client *rpcclient.Client,
+	labels prometheus.Labels) prometheus.Collector {
+
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName("rpcclient", "", name), help,
+			nil, labels,
+		)
+	}
+
+	return &collector{
+		client: client,
+		requests: newDesc("requests_total",
+			"Number of completed RPC requests."),
+		errors: newDesc("request_errors_total",
+			"Number of completed RPC requests which failed."),
+		inFlight: newDesc("requests_in_flight",
+			"Number of RPC requests awaiting their response."),
+		reconnects: newDesc("reconnects_total",
+			"Number of times the websocket connection was "+
+				"re-established."),
+		latency: newDesc("request_duration_seconds",
+			"Latency of completed RPC requests."),
+	}
+}
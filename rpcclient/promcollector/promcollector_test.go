@@ -0,0 +1,72 @@
+package promcollector
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCollector ensures the request metrics of a client are exported with the
+// passed labels.
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.ReadAll(r.Body)
+			fmt.Fprint(w, `{"result":100,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:           strings.TrimPrefix(server.URL, "http://"),
+		User:           "user",
+		Pass:           "pass",
+		DisableTLS:     true,
+		HTTPPostMode:   true,
+		CollectMetrics: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetBlockCount()
+		require.NoError(t, err)
+	}
+
+	registry := prometheus.NewRegistry()
+	err = registry.Register(New(client, prometheus.Labels{
+		"host": "node",
+	}))
+	require.NoError(t, err)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 5)
+	for _, family := range families {
+		require.Len(t, family.Metric, 1)
+		metric := family.Metric[0]
+		require.Equal(t, "host", metric.Label[0].GetName())
+		require.Equal(t, "node", metric.Label[0].GetValue())
+
+		switch family.GetName() {
+		case "rpcclient_requests_total":
+			require.EqualValues(t, 2, metric.Counter.GetValue())
+		case "rpcclient_request_errors_total":
+			require.Zero(t, metric.Counter.GetValue())
+		case "rpcclient_requests_in_flight":
+			require.Zero(t, metric.Gauge.GetValue())
+		case "rpcclient_request_duration_seconds":
+			require.EqualValues(t, 2,
+				metric.Histogram.GetSampleCount())
+		}
+	}
+}
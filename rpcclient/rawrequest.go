@@ -53,6 +53,10 @@ func (c *Client) RawRequestAsync(method string, params []json.RawMessage) Future
 	if err != nil {
 		return newFutureError(err)
 	}
+	marshalledJSON, err = c.decorateRequest(method, id, marshalledJSON)
+	if err != nil {
+		return newFutureError(err)
+	}
 
 	// Generate the request and send it along with a channel to respond on.
 	responseChan := make(chan *Response, 1)
@@ -76,3 +80,61 @@ func (c *Client) RawRequestAsync(method string, params []json.RawMessage) Future
 func (c *Client) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
 	return c.RawRequestAsync(method, params).Receive()
 }
+
+// namedRequest is a JSON-RPC request whose parameters are passed by name as a
+// JSON object rather than by position as an array.
+type namedRequest struct {
+	Jsonrpc btcjson.RPCVersion     `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params"`
+	ID      uint64                 `json:"id"`
+}
+
+// SendCmdNamed sends a request for the passed method with its parameters given
+// by name, as allowed by JSON-RPC 2.0, and returns a response channel on which
+// the reply will be delivered at some point in the future.  This is useful for
+// talking to gateways which require named parameters, as the commands sent by
+// SendCmd always use positional parameters.  It handles both websocket and
+// HTTP POST mode depending on the configuration of the client.
+func (c *Client) SendCmdNamed(method string,
+	params map[string]interface{}) chan *Response {
+
+	// Method may not be empty.
+	if method == "" {
+		return newFutureError(errors.New("no method"))
+	}
+
+	// Marshal parameters as "{}" instead of "null" when no parameters are
+	// passed.
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	id := c.NextID()
+	marshalledJSON, err := c.marshalJSON(&namedRequest{
+		Jsonrpc: btcjson.RpcVersion2,
+		Method:  method,
+		Params:  params,
+		ID:      id,
+	})
+	if err != nil {
+		return newFutureError(err)
+	}
+	marshalledJSON, err = c.decorateRequest(method, id, marshalledJSON)
+	if err != nil {
+		return newFutureError(err)
+	}
+
+	// Generate the request and send it along with a channel to respond on.
+	responseChan := make(chan *Response, 1)
+	jReq := &jsonRequest{
+		id:             id,
+		method:         method,
+		cmd:            nil,
+		marshalledJSON: marshalledJSON,
+		responseChan:   responseChan,
+	}
+	c.sendRequest(jReq)
+
+	return responseChan
+}
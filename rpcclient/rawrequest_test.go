@@ -0,0 +1,88 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSendCmdNamed ensures SendCmdNamed sends a JSON-RPC 2.0 request with its
+// parameters as an object and delivers the result of the reply.
+func TestSendCmdNamed(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Jsonrpc string          `json:"jsonrpc"`
+				Method  string          `json:"method"`
+				Params  json.RawMessage `json:"params"`
+				ID      uint64          `json:"id"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+			require.Equal(t, "2.0", req.Jsonrpc)
+
+			// Reply with the received method and parameters.
+			result := map[string]interface{}{
+				"method": req.Method,
+				"params": req.Params,
+			}
+			err = json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"result":  result,
+				"error":   nil,
+				"id":      req.ID,
+			})
+			require.NoError(t, err)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	type echo struct {
+		Method string                 `json:"method"`
+		Params map[string]interface{} `json:"params"`
+	}
+	receive := func(future chan *Response) echo {
+		t.Helper()
+
+		res, err := ReceiveFuture(future)
+		require.NoError(t, err)
+		var e echo
+		require.NoError(t, json.Unmarshal(res, &e))
+		return e
+	}
+
+	e := receive(client.SendCmdNamed("getblock", map[string]interface{}{
+		"blockhash": "00aa",
+		"verbosity": 2,
+	}))
+	require.Equal(t, "getblock", e.Method)
+	require.Equal(t, map[string]interface{}{
+		"blockhash": "00aa",
+		"verbosity": float64(2),
+	}, e.Params)
+
+	// Without parameters an empty object is sent.
+	e = receive(client.SendCmdNamed("getblockcount", nil))
+	require.Equal(t, "getblockcount", e.Method)
+	require.NotNil(t, e.Params)
+	require.Empty(t, e.Params)
+
+	_, err = ReceiveFuture(client.SendCmdNamed("", nil))
+	require.Error(t, err)
+}
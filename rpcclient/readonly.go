@@ -0,0 +1,89 @@
+package rpcclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnlyClient is returned for requests of state-changing methods made
+// through a client created with the ReadOnly connection option.
+var ErrReadOnlyClient = errors.New("method not allowed on a read-only client")
+
+// MutatingMethods is the set of methods which change the state of the node or
+// wallet, or broadcast data to the network, and are therefore rejected by
+// clients created with the ReadOnly connection option.  It may be modified to
+// adjust the methods which are rejected, but must not be modified while
+// requests are being made.
+var MutatingMethods = map[string]struct{}{
+	// Chain and mempool methods.
+	"generate":              {},
+	"generatetoaddress":     {},
+	"generatetodescriptor":  {},
+	"invalidateblock":       {},
+	"preciousblock":         {},
+	"prioritisetransaction": {},
+	"pruneblockchain":       {},
+	"reconsiderblock":       {},
+	"savemempool":           {},
+	"sendrawtransaction":    {},
+	"setgenerate":           {},
+	"submitblock":           {},
+	"submitpackage":         {},
+
+	// Network and node methods.
+	"addnode":        {},
+	"clearbanned":    {},
+	"disconnectnode": {},
+	"node":           {},
+	"setban":         {},
+	"stop":           {},
+
+	// Wallet methods.
+	"abandontransaction":     {},
+	"abortrescan":            {},
+	"addmultisigaddress":     {},
+	"backupwallet":           {},
+	"bumpfee":                {},
+	"createwallet":           {},
+	"dumpwallet":             {},
+	"encryptwallet":          {},
+	"getnewaddress":          {},
+	"getrawchangeaddress":    {},
+	"importaddress":          {},
+	"importdescriptors":      {},
+	"importmulti":            {},
+	"importprivkey":          {},
+	"importprunedfunds":      {},
+	"importpubkey":           {},
+	"importwallet":           {},
+	"keypoolrefill":          {},
+	"loadwallet":             {},
+	"lockunspent":            {},
+	"move":                   {},
+	"removeprunedfunds":      {},
+	"rescanblockchain":       {},
+	"sendfrom":               {},
+	"sendmany":               {},
+	"sendtoaddress":          {},
+	"setaccount":             {},
+	"sethdseed":              {},
+	"setlabel":               {},
+	"settxfee":               {},
+	"unloadwallet":           {},
+	"walletlock":             {},
+	"walletpassphrase":       {},
+	"walletpassphrasechange": {},
+}
+
+// checkReadOnly returns an error wrapping ErrReadOnlyClient when the client is
+// read-only and the passed method is one of the MutatingMethods.
+func (c *Client) checkReadOnly(method string) error {
+	if !c.config.ReadOnly {
+		return nil
+	}
+	if _, ok := MutatingMethods[method]; ok {
+		return c.connErr(fmt.Errorf("%w: %s", ErrReadOnlyClient,
+			method))
+	}
+	return nil
+}
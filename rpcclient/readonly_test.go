@@ -0,0 +1,61 @@
+package rpcclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadOnly ensures a read-only client rejects state-changing methods
+// without sending them while other methods are unaffected.
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			fmt.Fprint(w, `{"result":1,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		ReadOnly:     true,
+		ConnName:     "primary",
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	_, err = ReceiveFuture(client.SendCmd(
+		btcjson.NewSubmitBlockCmd("00", nil),
+	))
+	require.ErrorIs(t, err, ErrReadOnlyClient)
+	require.True(t, strings.HasPrefix(err.Error(), "primary: "))
+
+	_, err = client.RawRequest("sendrawtransaction", nil)
+	require.ErrorIs(t, err, ErrReadOnlyClient)
+
+	_, err = client.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+		btcjson.NewStopCmd(),
+	})
+	require.ErrorIs(t, err, ErrReadOnlyClient)
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,38 @@
+package rpcclient
+
+// ReconnectLimiter limits the number of clients which attempt to reconnect at
+// the same time.  A single limiter may be shared by the configurations of many
+// clients, typically connected to the same backend, so that they do not all
+// redial at once when it restarts.  Clients wait for their turn before each
+// reconnect attempt.
+type ReconnectLimiter struct {
+	sem chan struct{}
+}
+
+// NewReconnectLimiter returns a limiter which allows up to maxConcurrent
+// reconnect attempts at the same time.  At least one attempt is always
+// allowed.
+func NewReconnectLimiter(maxConcurrent int) *ReconnectLimiter {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &ReconnectLimiter{
+		sem: make(chan struct{}, maxConcurrent),
+	}
+}
+
+// acquire waits until a reconnect attempt is allowed, returning false without
+// waiting further once the passed quit channel is closed.
+func (l *ReconnectLimiter) acquire(quit <-chan struct{}) bool {
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// release ends a reconnect attempt allowed by acquire.
+func (l *ReconnectLimiter) release() {
+	<-l.sem
+}
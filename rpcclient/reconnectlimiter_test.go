@@ -0,0 +1,77 @@
+package rpcclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReconnectLimiter ensures clients sharing a reconnect limiter wait for
+// their turn before attempting to reconnect.
+func TestReconnectLimiter(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		},
+	))
+	defer server.Close()
+
+	// Take the only slot, so no client may reconnect.
+	limiter := NewReconnectLimiter(0)
+	require.True(t, limiter.acquire(nil))
+
+	attempts := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		client, err := New(&ConnConfig{
+			Host:             strings.TrimPrefix(server.URL, "http://"),
+			User:             "user",
+			Pass:             "pass",
+			DisableTLS:       true,
+			ReconnectLimiter: limiter,
+			OnReconnectAttempt: func(int64) {
+				attempts <- struct{}{}
+			},
+		}, nil)
+		require.NoError(t, err)
+		defer client.Shutdown()
+
+		client.Disconnect()
+	}
+
+	select {
+	case <-attempts:
+		t.Fatal("reconnect attempted while limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Both clients reconnect in turn once the slot is released.
+	limiter.release()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-attempts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for reconnect attempt")
+		}
+	}
+
+	// A client waiting for its turn stops waiting once it is shut down.
+	require.True(t, limiter.acquire(nil))
+	quit := make(chan struct{})
+	close(quit)
+	require.False(t, limiter.acquire(quit))
+}
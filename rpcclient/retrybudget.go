@@ -0,0 +1,82 @@
+package rpcclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExceeded is returned for HTTP POST requests which failed and
+// could not be retried because the client's retry budget has been exhausted.
+var ErrRetryBudgetExceeded = errors.New("retry budget exceeded")
+
+const (
+	// defaultRetryBudgetWindow is the window over which the retry budget
+	// refills when the window is not set in the connection configuration.
+	defaultRetryBudgetWindow = time.Minute
+)
+
+// retryBudget is a token bucket which limits the aggregate number of request
+// retries and reconnect attempts made by a client.  It holds up to max tokens,
+// one of which is consumed by each retry, and refills continuously at a rate
+// of max tokens per window.
+type retryBudget struct {
+	mtx    sync.Mutex
+	max    float64
+	tokens float64
+	rate   float64 // tokens per second
+	last   time.Time
+}
+
+// newRetryBudget returns a full retry budget allowing max retries per window.
+func newRetryBudget(max uint32, window time.Duration) *retryBudget {
+	if window <= 0 {
+		window = defaultRetryBudgetWindow
+	}
+	return &retryBudget{
+		max:    float64(max),
+		tokens: float64(max),
+		rate:   float64(max) / window.Seconds(),
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens accrued since the last refill.
+//
+// This function MUST be called with the budget mutex held.
+func (b *retryBudget) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+}
+
+// take consumes a token for a retry, returning false without consuming one
+// when the budget is exhausted.
+func (b *retryBudget) take() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reserve consumes a token for a retry, which may put the budget into debt,
+// and returns how long to wait before the retry is covered by the budget.
+func (b *retryBudget) reserve() time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,33 @@
+package rpcclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetryBudget ensures the retry budget allows the configured number of
+// retries, refills over its window, and reports how long reconnect attempts
+// must wait once exhausted.
+func TestRetryBudget(t *testing.T) {
+	t.Parallel()
+
+	b := newRetryBudget(2, 100*time.Millisecond)
+
+	require.True(t, b.take())
+	require.True(t, b.take())
+	require.False(t, b.take())
+
+	// Half the window refills a single token.
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, b.take())
+	require.False(t, b.take())
+
+	// Reserving from an exhausted budget reports the time until the
+	// reservation is covered, which is at most half the window per token.
+	wait := b.reserve()
+	require.Positive(t, wait)
+	require.LessOrEqual(t, wait, 50*time.Millisecond)
+	require.False(t, b.take())
+}
@@ -0,0 +1,140 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+const (
+	// scanTxOutSetPollInterval is the interval at which the progress of a
+	// scan started with ScanTxOutSet is polled.
+	scanTxOutSetPollInterval = time.Second
+)
+
+// ScanTxOutSet scans the UTXO set for outputs matching the passed output
+// descriptors and returns the result once the scan has completed.  Since a scan
+// can take minutes, the status of the scan is polled periodically while it is
+// running and the approximate percentage completed is passed to progress, which
+// may be nil.  The scan is aborted and the context's error returned when the
+// context is done before the scan has completed.
+//
+// Failed status polls are ignored.  Should the connection be lost while the
+// scan is running, the request which started it is resent once reconnected, and
+// if the original scan is still in progress on the server at that point, the
+// scan is started again once it has finished.  In HTTP POST mode, the status
+// polls and the abort are sent on their own connections so they are not queued
+// behind the request which started the scan.
+//
+// NOTE: This is a bitcoind extension.
+func (c *Client) ScanTxOutSet(ctx context.Context, descriptors []string,
+	progress func(float64)) (*btcjson.ScanTxOutSetResult, error) {
+
+	startCmd := btcjson.NewScanTxOutSetCmd("start", &descriptors)
+	start := c.SendCmd(startCmd)
+
+	ticker := time.NewTicker(scanTxOutSetPollInterval)
+	defer ticker.Stop()
+
+	// restart is set when another scan, presumably a resend of ours, was
+	// already in progress when the scan was started.
+	var restart bool
+	for {
+		select {
+		case resp := <-start:
+			if isScanInProgressErr(resp.err) {
+				log.Debugf("Scan already in progress on %s, "+
+					"waiting for it to finish", c.config.Host)
+				restart = true
+				start = nil
+				continue
+			}
+			if resp.err != nil {
+				return nil, resp.err
+			}
+
+			var result btcjson.ScanTxOutSetResult
+			if err := c.unmarshalJSON(resp.result, &result); err != nil {
+				return nil, err
+			}
+			return &result, nil
+
+		case <-ticker.C:
+			statusCmd := btcjson.NewScanTxOutSetCmd("status", nil)
+			var status *btcjson.ScanTxOutSetStatusResult
+			err := c.sendCmdUnqueued(ctx, statusCmd, &status)
+			if err != nil {
+				log.Debugf("Unable to get scan status from %s: %v",
+					c.config.Host, err)
+				continue
+			}
+
+			switch {
+			case status != nil && progress != nil:
+				progress(status.Progress)
+
+			// Start the scan again once the scan which was in
+			// progress has finished.
+			case status == nil && restart:
+				restart = false
+				start = c.SendCmd(startCmd)
+			}
+
+		case <-ctx.Done():
+			// The context is done, so use a new one to abort the
+			// scan.
+			abortCtx, cancel := context.WithTimeout(
+				context.Background(), scanTxOutSetPollInterval,
+			)
+			abortCmd := btcjson.NewScanTxOutSetCmd("abort", nil)
+			var aborted bool
+			err := c.sendCmdUnqueued(abortCtx, abortCmd, &aborted)
+			cancel()
+			if err != nil {
+				log.Warnf("Unable to abort scan on %s: %v",
+					c.config.Host, err)
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// isScanInProgressErr returns whether the passed error is the error returned
+// by bitcoind when starting a scan while another scan is in progress.
+func isScanInProgressErr(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) &&
+		rpcErr.Code == btcjson.ErrRPCInvalidParameter &&
+		strings.Contains(rpcErr.Message, "in progress")
+}
+
+// sendCmdUnqueued sends the passed command and decodes its result into result.
+// In HTTP POST mode the request is sent on the calling goroutine rather than
+// queued behind any requests which are still awaiting their response, which
+// allows querying the server while a long-running request is in progress.
+func (c *Client) sendCmdUnqueued(ctx context.Context, cmd interface{},
+	result interface{}) error {
+
+	if c.config.HTTPPostMode {
+		var buf bytes.Buffer
+		if err := c.SendCmdStream(ctx, cmd, &buf); err != nil {
+			return err
+		}
+		return c.unmarshalJSON(buf.Bytes(), result)
+	}
+
+	select {
+	case resp := <-c.SendCmd(cmd):
+		if resp.err != nil {
+			return resp.err
+		}
+		return c.unmarshalJSON(resp.result, result)
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,145 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// newScanServer returns a server emulating the scantxoutset RPC in which a
+// started scan runs until done is closed, along with a function returning the
+// actions it has received.
+func newScanServer(t *testing.T, done chan struct{}) (*httptest.Server,
+	func() []string) {
+
+	var mtx sync.Mutex
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var action string
+			require.NoError(t, json.Unmarshal(req.Params[0], &action))
+			mtx.Lock()
+			actions = append(actions, action)
+			mtx.Unlock()
+
+			var result string
+			switch action {
+			case "start":
+				var descriptors []string
+				err := json.Unmarshal(req.Params[1], &descriptors)
+				require.NoError(t, err)
+				require.Equal(t, []string{"addr(x)"}, descriptors)
+
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+				}
+				result = `{"success":true,"txouts":10,` +
+					`"height":100,"unspents":[{"txid":"00",` +
+					`"vout":1,"amount":0.5}],"total_amount":0.5}`
+
+			case "status":
+				result = `{"progress":42}`
+
+			case "abort":
+				close(done)
+				result = "true"
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+
+	return server, func() []string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]string(nil), actions...)
+	}
+}
+
+// TestScanTxOutSet ensures ScanTxOutSet reports the progress of a running scan
+// while waiting for its result in HTTP POST mode, and aborts the scan when the
+// context is done.
+func TestScanTxOutSet(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan struct{})
+	server, actions := newScanServer(t, done)
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	// Finish the scan once its progress has been reported.
+	var once sync.Once
+	result, err := client.ScanTxOutSet(context.Background(),
+		[]string{"addr(x)"}, func(progress float64) {
+			require.Equal(t, 42.0, progress)
+			once.Do(func() { close(done) })
+		})
+	require.NoError(t, err)
+	require.True(t, result.Success)
+	require.Equal(t, int64(100), result.Height)
+	require.Len(t, result.Unspents, 1)
+	require.Equal(t, 0.5, result.TotalAmount)
+	require.Equal(t, "start", actions()[0])
+	require.Contains(t, actions(), "status")
+
+	// A scan which is still running when the context is done is aborted.
+	done = make(chan struct{})
+	server, actions = newScanServer(t, done)
+	defer server.Close()
+
+	client, err = New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 100*time.Millisecond,
+	)
+	defer cancel()
+	_, err = client.ScanTxOutSet(ctx, []string{"addr(x)"}, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, actions(), "abort")
+}
+
+// TestIsScanInProgressErr ensures the error returned when starting a scan
+// while another is in progress is recognized.
+func TestIsScanInProgressErr(t *testing.T) {
+	t.Parallel()
+
+	inProgress := btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+		`Scan already in progress, use action "abort" or "status"`)
+	require.True(t, isScanInProgressErr(inProgress))
+
+	other := btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+		"Invalid action")
+	require.False(t, isScanInProgressErr(other))
+	require.False(t, isScanInProgressErr(nil))
+}
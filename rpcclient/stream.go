@@ -0,0 +1,294 @@
+package rpcclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// errMalformedStream is returned when a streamed response is not a valid
+// JSON-RPC response object.
+var errMalformedStream = errors.New("malformed JSON-RPC response")
+
+// SendCmdStream sends the passed command to the server and copies the raw JSON
+// of the result to w as it is read from the connection, rather than buffering
+// the entire response in memory first.  This allows very large responses, such
+// as a verbose getrawmempool, to be processed incrementally, for example by
+// reading from the other end of an io.Pipe with a json.Decoder.
+//
+// The result written to w is null when the server returned an error, in which
+// case the error is returned once the rest of the response has been read.
+//
+// When the ValidateResponse hook is set in the connection configuration, the
+// result is buffered so it can be checked before being written to w, and
+// nothing is written when it is rejected.
+//
+// Unlike SendCmd, the request is performed on the calling goroutine and is not
+// retried.  It may only be used in HTTP POST mode, since each websocket message
+// is read in its entirety.
+func (c *Client) SendCmdStream(ctx context.Context, cmd interface{},
+	w io.Writer) error {
+
+	if !c.config.HTTPPostMode {
+		return ErrNotPostClient
+	}
+	method, err := btcjson.CmdMethod(cmd)
+	if err != nil {
+		return err
+	}
+	if err := c.checkReadOnly(method); err != nil {
+		return err
+	}
+
+	id := c.NextID()
+	marshalledJSON, err := c.marshalCmdRequest(
+		btcjson.RpcVersion1, id, method, cmd,
+	)
+	if err != nil {
+		return err
+	}
+
+	httpURL, err := c.config.httpURL()
+	if err != nil {
+		return fmt.Errorf("failed to parse address %v", err)
+	}
+	httpReq, err := c.newPostRequest(ctx, httpURL, marshalledJSON)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	c.notifyHTTPResponse(method, httpResponse)
+	body, err := responseBody(httpResponse)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	err = c.streamResult(bufio.NewReader(body), method, w)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if errors.Is(err, errMalformedStream) {
+		return fmt.Errorf("status code: %d, %v",
+			httpResponse.StatusCode, err)
+	}
+	return err
+}
+
+// streamResult reads a JSON-RPC response object to the passed method from r,
+// copying the raw JSON of its result to w, and returns the error in the
+// response, if any.  The result is buffered and checked with the
+// ValidateResponse hook first when it is set.
+func (c *Client) streamResult(r *bufio.Reader, method string,
+	w io.Writer) error {
+
+	b, err := readNonSpace(r)
+	if err != nil {
+		return err
+	}
+	if b != '{' {
+		return errMalformedStream
+	}
+
+	bw := bufio.NewWriter(w)
+	var buffered *bytes.Buffer
+	if c.config.ValidateResponse != nil {
+		buffered = new(bytes.Buffer)
+	}
+	var rpcErr *btcjson.RPCError
+	for first := true; ; first = false {
+		b, err := readNonSpace(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case b == '}':
+			if buffered != nil {
+				if rpcErr == nil {
+					err := c.validateResponse(
+						method, buffered.Bytes(),
+					)
+					if err != nil {
+						return err
+					}
+				}
+				_, err := w.Write(buffered.Bytes())
+				if err != nil {
+					return err
+				}
+			}
+			if rpcErr != nil {
+				return rpcErr
+			}
+			return nil
+
+		case first:
+			if err := r.UnreadByte(); err != nil {
+				return err
+			}
+
+		case b != ',':
+			return errMalformedStream
+		}
+
+		// Read the name of the member.
+		var rawName bytes.Buffer
+		if err := copyJSONValue(r, &rawName); err != nil {
+			return err
+		}
+		var name string
+		if err := json.Unmarshal(rawName.Bytes(), &name); err != nil {
+			return errMalformedStream
+		}
+		b, err = readNonSpace(r)
+		if err != nil {
+			return err
+		}
+		if b != ':' {
+			return errMalformedStream
+		}
+
+		// Stream the result to the writer and buffer the other members,
+		// which are small.
+		if name == "result" && buffered != nil {
+			if err := copyJSONValue(r, buffered); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "result" {
+			if err := copyJSONValue(r, bw); err != nil {
+				return err
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		var value bytes.Buffer
+		if err := copyJSONValue(r, &value); err != nil {
+			return err
+		}
+		if name == "error" {
+			err := c.unmarshalJSON(value.Bytes(), &rpcErr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readNonSpace returns the next byte from r which is not JSON whitespace.
+func readNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return b, nil
+		}
+	}
+}
+
+// copyJSONValue copies the next JSON value from r to w byte by byte without
+// decoding it.  The value is not validated beyond what is needed to find where
+// it ends.
+func copyJSONValue(r *bufio.Reader, w io.ByteWriter) error {
+	b, err := readNonSpace(r)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteByte(b); err != nil {
+		return err
+	}
+
+	switch b {
+	case '"':
+		return copyJSONString(r, w)
+
+	case '{', '[':
+		for depth := 1; depth > 0; {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if err := w.WriteByte(b); err != nil {
+				return err
+			}
+
+			switch b {
+			case '"':
+				if err := copyJSONString(r, w); err != nil {
+					return err
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return nil
+
+	default:
+		// Numbers, booleans, and null end at the next delimiter or
+		// whitespace.
+		for {
+			b, err := r.ReadByte()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if strings.IndexByte(",:}] \t\r\n", b) != -1 {
+				return r.UnreadByte()
+			}
+			if err := w.WriteByte(b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// copyJSONString copies the remainder of a JSON string, whose opening quote has
+// already been copied, from r to w up to and including its closing quote.
+func copyJSONString(r *bufio.Reader, w io.ByteWriter) error {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if err := w.WriteByte(b); err != nil {
+			return err
+		}
+
+		switch b {
+		case '\\':
+			// Copy the escaped character so an escaped quote does
+			// not end the string.
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if err := w.WriteByte(b); err != nil {
+				return err
+			}
+
+		case '"':
+			return nil
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rpcclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCopyJSONValue ensures JSON values are copied up to their end without
+// being confused by delimiters within strings.
+func TestCopyJSONValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+		rest string
+	}{
+		{in: `123,"id":1}`, want: `123`, rest: `,"id":1}`},
+		{in: ` null}`, want: `null`, rest: `}`},
+		{in: `"a\"},{b",1`, want: `"a\"},{b"`, rest: `,1`},
+		{
+			in:   `{"a":[1,{"b":"}]"}],"c":"\\"} ,`,
+			want: `{"a":[1,{"b":"}]"}],"c":"\\"}`,
+			rest: ` ,`,
+		},
+		{in: `true`, want: `true`, rest: ``},
+	}
+
+	for _, test := range tests {
+		r := bufio.NewReader(strings.NewReader(test.in))
+		var out bytes.Buffer
+		require.NoError(t, copyJSONValue(r, &out), test.in)
+		require.Equal(t, test.want, out.String(), test.in)
+
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, test.rest, string(rest), test.in)
+	}
+}
+
+// TestSendCmdStream ensures the result of a call is streamed to the writer and
+// errors returned by the server are reported.
+func TestSendCmdStream(t *testing.T) {
+	t.Parallel()
+
+	const mempool = `{"aa":{"vsize":100,"depends":[]},` +
+		`"bb":{"vsize":200,"depends":["aa"]}}`
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			if bytes.Contains(body, []byte("getrawtransaction")) {
+				fmt.Fprint(w, `{"result":null,"error":{"code":-5,`+
+					`"message":"not found"},"id":1}`)
+				return
+			}
+			fmt.Fprintf(w, `{"result": %s, "error": null, "id": 1}`,
+				mempool)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	var out bytes.Buffer
+	cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(true))
+	err = client.SendCmdStream(context.Background(), cmd, &out)
+	require.NoError(t, err)
+	require.Equal(t, mempool, out.String())
+
+	out.Reset()
+	txCmd := btcjson.NewGetRawTransactionCmd("aa", nil)
+	err = client.SendCmdStream(context.Background(), txCmd, &out)
+	var rpcErr *btcjson.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, btcjson.RPCErrorCode(-5), rpcErr.Code)
+	require.Equal(t, "null", out.String())
+}
+
+// TestSendCmdStreamValidation ensures a streamed result rejected by the
+// ValidateResponse hook is not written to the writer.
+func TestSendCmdStreamValidation(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result":7,"error":null,"id":1}`)
+		},
+	))
+	defer server.Close()
+
+	errInvalid := errors.New("invalid")
+	var reject bool
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		ValidateResponse: func(method string, result []byte) error {
+			require.Equal(t, "getblockcount", method)
+			require.Equal(t, "7", string(result))
+			if reject {
+				return errInvalid
+			}
+			return nil
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	var out bytes.Buffer
+	cmd := btcjson.NewGetBlockCountCmd()
+	err = client.SendCmdStream(context.Background(), cmd, &out)
+	require.NoError(t, err)
+	require.Equal(t, "7", out.String())
+
+	out.Reset()
+	reject = true
+	err = client.SendCmdStream(context.Background(), cmd, &out)
+	require.ErrorIs(t, err, errInvalid)
+	require.Zero(t, out.Len())
+}
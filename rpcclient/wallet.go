@@ -6,6 +6,7 @@ package rpcclient
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 
 	"github.com/btcsuite/btcd/btcjson"
@@ -183,6 +184,40 @@ func (c *Client) ListTransactionsCountFromWatchOnly(account string, count, from
 	return c.ListTransactionsCountFromWatchOnlyAsync(account, count, from, watchOnly).Receive()
 }
 
+// ListAllTransactions pages through all of the transactions of the passed
+// account, pageSize transactions at a time, invoking fn with each page until a
+// page with fewer than pageSize transactions is returned.  The transactions of
+// all accounts are listed when the account is empty, and pageSize must be
+// positive.  Paging stops early and the error is returned when fn returns one.
+//
+// Since pages are requested by offset, transactions which are added to the
+// wallet while paging may cause a transaction to be passed to fn twice.
+func (c *Client) ListAllTransactions(account string, pageSize int,
+	fn func([]btcjson.ListTransactionsResult) error) error {
+
+	if pageSize <= 0 {
+		return fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if account == "" {
+		account = "*"
+	}
+
+	for from := 0; ; from += pageSize {
+		page, err := c.ListTransactionsCountFrom(account, pageSize, from)
+		if err != nil {
+			return err
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+	}
+}
+
 // FutureListUnspentResult is a future promise to deliver the result of a
 // ListUnspentAsync, ListUnspentMinAsync, ListUnspentMinMaxAsync, or
 // ListUnspentMinMaxAddressesAsync RPC invocation (or an applicable error).
@@ -2323,6 +2358,50 @@ func (c *Client) VerifyMessage(address btcutil.Address, signature, message strin
 	return c.VerifyMessageAsync(address, signature, message).Receive()
 }
 
+// SignMessageWithPrivKeyAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See SignMessageWithPrivKey for the blocking version and more details.
+func (c *Client) SignMessageWithPrivKeyAsync(privKeyWIF, message string) FutureSignMessageResult {
+	cmd := btcjson.NewSignMessageWithPrivKey(privKeyWIF, message)
+	return c.SendCmd(cmd)
+}
+
+// SignMessageWithPrivKey signs a message with the passed private key, which
+// must be in Wallet Import Format.  Unlike SignMessage, it does not require a
+// wallet.
+func (c *Client) SignMessageWithPrivKey(privKeyWIF, message string) (string, error) {
+	return c.SignMessageWithPrivKeyAsync(privKeyWIF, message).Receive()
+}
+
+// SignAndVerifyMessage signs a message with the passed private key, which must
+// be in Wallet Import Format, and then verifies the signature against the
+// pay-to-pubkey-hash address of the key.  It returns whether the signature was
+// successfully verified, which allows checking that signing works end to end
+// without deriving the address separately.
+func (c *Client) SignAndVerifyMessage(privKeyWIF, message string) (bool, error) {
+	wif, err := btcutil.DecodeWIF(privKeyWIF)
+	if err != nil {
+		return false, err
+	}
+	if !wif.IsForNet(c.chainParams) {
+		return false, fmt.Errorf("private key is not for the %s network",
+			c.chainParams.Name)
+	}
+	pubKeyHash := btcutil.Hash160(wif.SerializePubKey())
+	address, err := btcutil.NewAddressPubKeyHash(pubKeyHash, c.chainParams)
+	if err != nil {
+		return false, err
+	}
+
+	signature, err := c.SignMessageWithPrivKey(privKeyWIF, message)
+	if err != nil {
+		return false, err
+	}
+	return c.VerifyMessage(address, signature, message)
+}
+
 // *********************
 // Dump/Import Functions
 // *********************
@@ -0,0 +1,132 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignAndVerifyMessage ensures SignAndVerifyMessage verifies the signature
+// against the address derived from the private key.
+func TestSignAndVerifyMessage(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	wif, err := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true)
+	require.NoError(t, err)
+	address, err := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(wif.SerializePubKey()), &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			result := `"c2lnbmF0dXJl"`
+			if req.Method == "verifymessage" {
+				var addr string
+				err := json.Unmarshal(req.Params[0], &addr)
+				require.NoError(t, err)
+				require.Equal(t, address.EncodeAddress(), addr)
+				result = "true"
+			}
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	verified, err := client.SignAndVerifyMessage(wif.String(), "message")
+	require.NoError(t, err)
+	require.True(t, verified)
+
+	// Keys for another network are rejected.
+	testnetWIF, err := btcutil.NewWIF(privKey, &chaincfg.TestNet3Params, true)
+	require.NoError(t, err)
+	_, err = client.SignAndVerifyMessage(testnetWIF.String(), "message")
+	require.Error(t, err)
+}
+
+// TestListAllTransactions ensures ListAllTransactions pages through all of the
+// transactions until a partial page is returned.
+func TestListAllTransactions(t *testing.T) {
+	t.Parallel()
+
+	const numTxns = 5
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req btcjson.Request
+			err := json.NewDecoder(r.Body).Decode(&req)
+			require.NoError(t, err)
+
+			var account string
+			var count, from int
+			require.NoError(t, json.Unmarshal(req.Params[0], &account))
+			require.NoError(t, json.Unmarshal(req.Params[1], &count))
+			require.NoError(t, json.Unmarshal(req.Params[2], &from))
+			require.Equal(t, "*", account)
+
+			page := make([]btcjson.ListTransactionsResult, 0, count)
+			for i := from; i < from+count && i < numTxns; i++ {
+				page = append(page, btcjson.ListTransactionsResult{
+					TxID: fmt.Sprint(i),
+				})
+			}
+			result, err := json.Marshal(page)
+			require.NoError(t, err)
+			fmt.Fprintf(w, `{"result":%s,"error":null,"id":%v}`,
+				result, req.ID)
+		},
+	))
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	var pages [][]string
+	err = client.ListAllTransactions("", 2,
+		func(page []btcjson.ListTransactionsResult) error {
+			var txids []string
+			for _, tx := range page {
+				txids = append(txids, tx.TxID)
+			}
+			pages = append(pages, txids)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"0", "1"}, {"2", "3"}, {"4"}}, pages)
+
+	err = client.ListAllTransactions("", 0, nil)
+	require.Error(t, err)
+}